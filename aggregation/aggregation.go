@@ -0,0 +1,275 @@
+package aggregation
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/bosh-prometheus/firehose_exporter/utils"
+)
+
+type Operation string
+
+const (
+	Sum   Operation = "sum"
+	Avg   Operation = "avg"
+	Min   Operation = "min"
+	Max   Operation = "max"
+	Count Operation = "count"
+)
+
+type Config struct {
+	AggregationRules []Rule `yaml:"aggregation_rules"`
+}
+
+// Rule aggregates the series of a metric family by a set of labels, the
+// way a Prometheus recording rule `<operation> by (<by>) (<metric>)` would.
+type Rule struct {
+	Metric    string    `yaml:"metric"`
+	Operation Operation `yaml:"operation"`
+	By        []string  `yaml:"by,flow"`
+	Target    string    `yaml:"target"`
+	DropRaw   bool      `yaml:"drop_raw"`
+}
+
+// Aggregator evaluates aggregation rules over gathered metric families.
+type Aggregator struct {
+	rules []Rule
+}
+
+func LoadConfig(path string) (*Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := ParseConfig(content)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing aggregation config `%s`: %v", path, err)
+	}
+
+	return config, nil
+}
+
+func ParseConfig(content []byte) (*Config, error) {
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(content, config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func NewAggregator(rules []Rule) (*Aggregator, error) {
+	aggregator := &Aggregator{}
+	targets := map[string]bool{}
+
+	for i, rule := range rules {
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("Aggregation rule %d: %v", i, err)
+		}
+		if targets[rule.Target] {
+			return nil, fmt.Errorf("Aggregation rule %d: target `%s` is already used by another rule", i, rule.Target)
+		}
+		targets[rule.Target] = true
+		aggregator.rules = append(aggregator.rules, rule)
+	}
+
+	for _, rule := range aggregator.rules {
+		if targets[rule.Metric] {
+			return nil, fmt.Errorf("Aggregation rule for metric `%s`: metric is the target of another rule", rule.Metric)
+		}
+	}
+
+	return aggregator, nil
+}
+
+func (r *Rule) compile() error {
+	if !model.IsValidMetricName(model.LabelValue(r.Metric)) {
+		return fmt.Errorf("`metric` `%s` is not a valid metric name", r.Metric)
+	}
+
+	switch r.Operation {
+	case Sum, Avg, Min, Max, Count:
+	default:
+		return fmt.Errorf("operation `%s` is not supported", r.Operation)
+	}
+
+	by := make([]string, 0, len(r.By))
+	for _, label := range r.By {
+		if !model.LabelName(label).IsValid() {
+			return fmt.Errorf("`by` label `%s` is not a valid label name", label)
+		}
+		if !utils.ContainsString(by, label) {
+			by = append(by, label)
+		}
+	}
+	sort.Strings(by)
+	r.By = by
+
+	if r.Target == "" {
+		r.Target = defaultTarget(r.Metric, r.Operation, r.By)
+	}
+	if !model.IsValidMetricName(model.LabelValue(r.Target)) {
+		return fmt.Errorf("`target` `%s` is not a valid metric name", r.Target)
+	}
+	if r.Target == r.Metric {
+		return fmt.Errorf("`target` must differ from `metric`")
+	}
+
+	return nil
+}
+
+// defaultTarget names an aggregate following the Prometheus recording rules
+// convention `level:metric:operations`.
+func defaultTarget(metric string, operation Operation, by []string) string {
+	level := strings.Join(by, "_")
+	if level == "" {
+		level = "all"
+	}
+
+	return level + ":" + metric + ":" + string(operation)
+}
+
+// Process returns the metric families with the aggregates of the rules
+// appended, and without the families of the rules dropping raw series. Rules
+// apply to gauge, counter and untyped families only; aggregating other types,
+// or to the name of an existing family, is reported as an error.
+func (a *Aggregator) Process(metricFamilies []*dto.MetricFamily) ([]*dto.MetricFamily, []error) {
+	if a == nil {
+		return metricFamilies, nil
+	}
+
+	var errs []error
+	families := make(map[string]*dto.MetricFamily, len(metricFamilies))
+	for _, metricFamily := range metricFamilies {
+		families[metricFamily.GetName()] = metricFamily
+	}
+
+	dropped := map[string]bool{}
+	var aggregates []*dto.MetricFamily
+	for _, rule := range a.rules {
+		metricFamily, ok := families[rule.Metric]
+		if !ok {
+			continue
+		}
+		if rule.DropRaw {
+			dropped[rule.Metric] = true
+		}
+
+		if _, ok := families[rule.Target]; ok {
+			errs = append(errs, fmt.Errorf("aggregate %q of metric %q conflicts with an already gathered metric", rule.Target, rule.Metric))
+			continue
+		}
+
+		aggregate, err := rule.aggregate(metricFamily)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		aggregates = append(aggregates, aggregate)
+	}
+
+	result := make([]*dto.MetricFamily, 0, len(metricFamilies)+len(aggregates))
+	for _, metricFamily := range metricFamilies {
+		if !dropped[metricFamily.GetName()] {
+			result = append(result, metricFamily)
+		}
+	}
+	result = append(result, aggregates...)
+	sort.Slice(result, func(i, j int) bool { return result[i].GetName() < result[j].GetName() })
+
+	return result, errs
+}
+
+type group struct {
+	labels []*dto.LabelPair
+	value  float64
+	count  int
+}
+
+// aggregate returns the aggregates of a metric family, always as gauges: a sum
+// of counters goes down whenever one of its series disappears, which would
+// be seen as a counter reset.
+func (r Rule) aggregate(metricFamily *dto.MetricFamily) (*dto.MetricFamily, error) {
+	switch metricFamily.GetType() {
+	case dto.MetricType_COUNTER, dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+	default:
+		return nil, fmt.Errorf("metric %q of type %s can not be aggregated", metricFamily.GetName(), metricFamily.GetType())
+	}
+
+	groups := map[string]*group{}
+	var keys []string
+	for _, metric := range metricFamily.Metric {
+		labels := make([]*dto.LabelPair, 0, len(r.By))
+		values := make([]string, 0, len(r.By))
+		for _, labelPair := range metric.Label {
+			if utils.ContainsString(r.By, labelPair.GetName()) && labelPair.GetValue() != "" {
+				labels = append(labels, &dto.LabelPair{Name: proto.String(labelPair.GetName()), Value: proto.String(labelPair.GetValue())})
+				values = append(values, fmt.Sprintf("%s=%q", labelPair.GetName(), labelPair.GetValue()))
+			}
+		}
+		key := strings.Join(values, ",")
+
+		value := metricValue(metricFamily.GetType(), metric)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{labels: labels, value: value}
+			groups[key] = g
+			keys = append(keys, key)
+		} else {
+			switch r.Operation {
+			case Sum, Avg:
+				g.value += value
+			case Min:
+				g.value = math.Min(g.value, value)
+			case Max:
+				g.value = math.Max(g.value, value)
+			}
+		}
+		g.count++
+	}
+	sort.Strings(keys)
+
+	aggregate := &dto.MetricFamily{
+		Name: proto.String(r.Target),
+		Help: proto.String(fmt.Sprintf("%s by (%s) of %s: %s", r.Operation, strings.Join(r.By, ", "), metricFamily.GetName(), metricFamily.GetHelp())),
+		Type: dto.MetricType_GAUGE.Enum(),
+	}
+	for _, key := range keys {
+		g := groups[key]
+		value := g.value
+		switch r.Operation {
+		case Avg:
+			value = g.value / float64(g.count)
+		case Count:
+			value = float64(g.count)
+		}
+
+		aggregate.Metric = append(aggregate.Metric, &dto.Metric{
+			Label: g.labels,
+			Gauge: &dto.Gauge{Value: proto.Float64(value)},
+		})
+	}
+
+	return aggregate, nil
+}
+
+func metricValue(metricType dto.MetricType, metric *dto.Metric) float64 {
+	switch metricType {
+	case dto.MetricType_COUNTER:
+		return metric.GetCounter().GetValue()
+	case dto.MetricType_GAUGE:
+		return metric.GetGauge().GetValue()
+	default:
+		return metric.GetUntyped().GetValue()
+	}
+}
@@ -0,0 +1,81 @@
+package aggregation_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/firehose_exporter/aggregation"
+)
+
+var _ = Describe("Aggregation", func() {
+	Describe("ParseConfig", func() {
+		It("parses aggregation rules", func() {
+			config, err := ParseConfig([]byte(`
+aggregation_rules:
+- metric: firehose_container_metric_cpu_percentage
+  operation: sum
+  by: [application_id]
+  drop_raw: true
+`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.AggregationRules).To(Equal([]Rule{
+				{
+					Metric:    "firehose_container_metric_cpu_percentage",
+					Operation: Sum,
+					By:        []string{"application_id"},
+					DropRaw:   true,
+				},
+			}))
+		})
+
+		It("rejects unknown fields", func() {
+			_, err := ParseConfig([]byte(`
+aggregation_rules:
+- metric: firehose_container_metric_cpu_percentage
+  without: [instance_index]
+`))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("NewAggregator", func() {
+		It("accepts valid rules", func() {
+			_, err := NewAggregator([]Rule{
+				{Metric: "firehose_metric", Operation: Avg, By: []string{"origin"}},
+				{Metric: "firehose_metric", Operation: Count},
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("rejects unsupported operations", func() {
+			_, err := NewAggregator([]Rule{{Metric: "firehose_metric", Operation: "stddev"}})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects invalid label names", func() {
+			_, err := NewAggregator([]Rule{{Metric: "firehose_metric", Operation: Sum, By: []string{"bosh-job"}}})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects invalid metric names", func() {
+			_, err := NewAggregator([]Rule{{Metric: "firehose-metric", Operation: Sum}})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects rules with the same target", func() {
+			_, err := NewAggregator([]Rule{
+				{Metric: "firehose_metric", Operation: Sum, By: []string{"origin", "bosh_job_name"}},
+				{Metric: "firehose_metric", Operation: Sum, By: []string{"bosh_job_name", "origin"}},
+			})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects rules aggregating the target of another rule", func() {
+			_, err := NewAggregator([]Rule{
+				{Metric: "firehose_metric", Operation: Sum, Target: "firehose_metric_sum"},
+				{Metric: "firehose_metric_sum", Operation: Max},
+			})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,38 @@
+package aggregation
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Gatherer evaluates aggregation rules over the metric families gathered by
+// another gatherer.
+type Gatherer struct {
+	gatherer   prometheus.Gatherer
+	aggregator *Aggregator
+}
+
+func NewGatherer(gatherer prometheus.Gatherer, aggregator *Aggregator) *Gatherer {
+	return &Gatherer{
+		gatherer:   gatherer,
+		aggregator: aggregator,
+	}
+}
+
+func (g *Gatherer) Gather() ([]*dto.MetricFamily, error) {
+	metricFamilies, err := g.gatherer.Gather()
+	errs := prometheus.MultiError{}
+	if err != nil {
+		if multiErr, ok := err.(prometheus.MultiError); ok {
+			errs = append(errs, multiErr...)
+		} else {
+			errs = append(errs, err)
+		}
+	}
+
+	metricFamilies, aggregationErrs := g.aggregator.Process(metricFamilies)
+	errs = append(errs, aggregationErrs...)
+
+	return metricFamilies, errs.MaybeUnwrap()
+}
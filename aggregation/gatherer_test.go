@@ -0,0 +1,167 @@
+package aggregation_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	dto "github.com/prometheus/client_model/go"
+
+	. "github.com/bosh-prometheus/firehose_exporter/aggregation"
+)
+
+var _ = Describe("Gatherer", func() {
+	var (
+		registry   *prometheus.Registry
+		rules      []Rule
+		aggregator *Aggregator
+		gatherer   *Gatherer
+	)
+
+	BeforeEach(func() {
+		registry = prometheus.NewRegistry()
+
+		cpu := prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        "firehose_container_metric_cpu_percentage",
+				Help:        "Fake CPU.",
+				ConstLabels: prometheus.Labels{"environment": "test_environment"},
+			},
+			[]string{"application_id", "instance_index"},
+		)
+		cpu.WithLabelValues("app-1", "0").Set(10)
+		cpu.WithLabelValues("app-1", "1").Set(30)
+		cpu.WithLabelValues("app-2", "0").Set(5)
+		registry.MustRegister(cpu)
+
+		requests := prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "firehose_requests_total",
+				Help: "Fake requests.",
+			},
+			[]string{"application_id", "instance_index"},
+		)
+		requests.WithLabelValues("app-1", "0").Add(1)
+		requests.WithLabelValues("app-1", "1").Add(2)
+		registry.MustRegister(requests)
+
+		rules = nil
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		aggregator, err = NewAggregator(rules)
+		Expect(err).ToNot(HaveOccurred())
+		gatherer = NewGatherer(registry, aggregator)
+	})
+
+	labels := func(metric *dto.Metric) map[string]string {
+		result := map[string]string{}
+		for _, labelPair := range metric.Label {
+			result[labelPair.GetName()] = labelPair.GetValue()
+		}
+		return result
+	}
+
+	Context("without rules", func() {
+		It("returns the gathered metric families", func() {
+			metricFamilies, err := gatherer.Gather()
+			Expect(err).ToNot(HaveOccurred())
+
+			expectedFamilies, err := registry.Gather()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metricFamilies).To(Equal(expectedFamilies))
+		})
+	})
+
+	Context("when series are aggregated", func() {
+		BeforeEach(func() {
+			rules = []Rule{
+				{Metric: "firehose_container_metric_cpu_percentage", Operation: Sum, By: []string{"environment", "application_id"}},
+				{Metric: "firehose_container_metric_cpu_percentage", Operation: Max, Target: "firehose_container_metric_cpu_percentage_max"},
+			}
+		})
+
+		It("returns the aggregates along with the raw series", func() {
+			metricFamilies, err := gatherer.Gather()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metricFamilies).To(HaveLen(4))
+
+			Expect(metricFamilies[0].GetName()).To(Equal("application_id_environment:firehose_container_metric_cpu_percentage:sum"))
+			Expect(metricFamilies[0].GetType()).To(Equal(dto.MetricType_GAUGE))
+			Expect(metricFamilies[0].Metric).To(HaveLen(2))
+			Expect(labels(metricFamilies[0].Metric[0])).To(Equal(map[string]string{"application_id": "app-1", "environment": "test_environment"}))
+			Expect(metricFamilies[0].Metric[0].GetGauge().GetValue()).To(Equal(float64(40)))
+			Expect(labels(metricFamilies[0].Metric[1])).To(Equal(map[string]string{"application_id": "app-2", "environment": "test_environment"}))
+			Expect(metricFamilies[0].Metric[1].GetGauge().GetValue()).To(Equal(float64(5)))
+
+			Expect(metricFamilies[1].GetName()).To(Equal("firehose_container_metric_cpu_percentage"))
+			Expect(metricFamilies[1].Metric).To(HaveLen(3))
+
+			Expect(metricFamilies[2].GetName()).To(Equal("firehose_container_metric_cpu_percentage_max"))
+			Expect(metricFamilies[2].Metric).To(HaveLen(1))
+			Expect(labels(metricFamilies[2].Metric[0])).To(BeEmpty())
+			Expect(metricFamilies[2].Metric[0].GetGauge().GetValue()).To(Equal(float64(30)))
+		})
+	})
+
+	Context("when counters are aggregated", func() {
+		BeforeEach(func() {
+			rules = []Rule{
+				{Metric: "firehose_requests_total", Operation: Sum, By: []string{"application_id"}},
+				{Metric: "firehose_requests_total", Operation: Avg, By: []string{"application_id"}},
+				{Metric: "firehose_requests_total", Operation: Count, By: []string{"application_id"}},
+			}
+		})
+
+		It("returns all the aggregates as gauges", func() {
+			metricFamilies, err := gatherer.Gather()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metricFamilies).To(HaveLen(5))
+
+			Expect(metricFamilies[0].GetName()).To(Equal("application_id:firehose_requests_total:avg"))
+			Expect(metricFamilies[0].GetType()).To(Equal(dto.MetricType_GAUGE))
+			Expect(metricFamilies[0].Metric[0].GetGauge().GetValue()).To(Equal(float64(1.5)))
+
+			Expect(metricFamilies[1].GetName()).To(Equal("application_id:firehose_requests_total:count"))
+			Expect(metricFamilies[1].Metric[0].GetGauge().GetValue()).To(Equal(float64(2)))
+
+			Expect(metricFamilies[2].GetName()).To(Equal("application_id:firehose_requests_total:sum"))
+			Expect(metricFamilies[2].GetType()).To(Equal(dto.MetricType_GAUGE))
+			Expect(metricFamilies[2].Metric[0].GetGauge().GetValue()).To(Equal(float64(3)))
+		})
+	})
+
+	Context("when raw series are dropped", func() {
+		BeforeEach(func() {
+			rules = []Rule{
+				{Metric: "firehose_container_metric_cpu_percentage", Operation: Min, By: []string{"application_id"}, DropRaw: true},
+			}
+		})
+
+		It("only returns the aggregates", func() {
+			metricFamilies, err := gatherer.Gather()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metricFamilies).To(HaveLen(2))
+
+			Expect(metricFamilies[0].GetName()).To(Equal("application_id:firehose_container_metric_cpu_percentage:min"))
+			Expect(metricFamilies[0].Metric[0].GetGauge().GetValue()).To(Equal(float64(10)))
+			Expect(metricFamilies[1].GetName()).To(Equal("firehose_requests_total"))
+		})
+	})
+
+	Context("when an aggregate conflicts with a gathered metric", func() {
+		BeforeEach(func() {
+			rules = []Rule{
+				{Metric: "firehose_container_metric_cpu_percentage", Operation: Sum, Target: "firehose_requests_total"},
+			}
+		})
+
+		It("discards the aggregate and returns an error", func() {
+			metricFamilies, err := gatherer.Gather()
+			Expect(err).To(HaveOccurred())
+			Expect(metricFamilies).To(HaveLen(2))
+		})
+	})
+})
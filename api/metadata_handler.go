@@ -0,0 +1,69 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/bosh-prometheus/firehose_exporter/metadata"
+	"github.com/bosh-prometheus/firehose_exporter/utils"
+)
+
+type metadataData struct {
+	Families []metadata.Family `json:"families"`
+}
+
+type MetadataHandler struct {
+	catalog *metadata.Catalog
+}
+
+func NewMetadataHandler(catalog *metadata.Catalog) *MetadataHandler {
+	return &MetadataHandler{catalog: catalog}
+}
+
+func (h *MetadataHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method `%s` is not allowed", r.Method))
+		return
+	}
+
+	params := r.URL.Query()
+
+	var nameRE *regexp.Regexp
+	if name := params.Get("name"); name != "" {
+		var err error
+		nameRE, err = regexp.Compile(name)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("Invalid name expression `%s`: %s", name, err))
+			return
+		}
+	}
+
+	metricType := params.Get("type")
+	if metricType != "" && metricType != metadata.TypeCounter && metricType != metadata.TypeGauge {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("Metric type `%s` is not supported", metricType))
+		return
+	}
+
+	source := params.Get("source")
+	origin := params.Get("origin")
+
+	data := metadataData{Families: []metadata.Family{}}
+	for _, family := range h.catalog.Families() {
+		if nameRE != nil && !nameRE.MatchString(family.Name) {
+			continue
+		}
+		if metricType != "" && family.Type != metricType {
+			continue
+		}
+		if source != "" && family.Source != source {
+			continue
+		}
+		if origin != "" && !utils.ContainsString(family.Origins, origin) {
+			continue
+		}
+		data.Families = append(data.Families, family)
+	}
+
+	writeResponse(w, http.StatusOK, response{Status: "success", Data: data})
+}
@@ -0,0 +1,129 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bosh-prometheus/firehose_exporter/metadata"
+	"github.com/prometheus/client_golang/prometheus"
+
+	. "github.com/bosh-prometheus/firehose_exporter/api"
+)
+
+var _ = Describe("MetadataHandler", func() {
+	type metadataResponse struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			Families []struct {
+				Name       string   `json:"name"`
+				Source     string   `json:"source"`
+				Type       string   `json:"type"`
+				Help       string   `json:"help"`
+				Unit       string   `json:"unit"`
+				LabelNames []string `json:"label_names"`
+				Origins    []string `json:"origins"`
+				FirstSeen  string   `json:"first_seen"`
+				LastSeen   string   `json:"last_seen"`
+			} `json:"families"`
+		} `json:"data"`
+	}
+
+	var (
+		catalog         *metadata.Catalog
+		metadataHandler *MetadataHandler
+		method          string
+		path            string
+		recorder        *httptest.ResponseRecorder
+		resp            metadataResponse
+	)
+
+	BeforeEach(func() {
+		catalog = metadata.NewCatalog()
+
+		observations := []metadata.Observation{
+			{Source: "value_metric", Name: "firehose_value_metric_fake_origin_fake_value_metric", Type: metadata.TypeGauge, Unit: "ms", Origin: "fake-origin", LabelNames: []string{"origin"}},
+			{Source: "counter_event", Name: "firehose_counter_event_fake_origin_fake_counter_event_total", Type: metadata.TypeCounter, Origin: "fake-origin", LabelNames: []string{"origin"}},
+			{Source: "counter_event", Name: "firehose_counter_event_other_origin_fake_counter_event_total", Type: metadata.TypeCounter, Origin: "other-origin", LabelNames: []string{"origin"}},
+		}
+		for _, observation := range observations {
+			_, err := catalog.Desc(observation, prometheus.Labels{"environment": "test_environment"}, func() string { return "Fake help." })
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		method = "GET"
+		path = "/api/v1/metadata"
+		resp = metadataResponse{}
+	})
+
+	JustBeforeEach(func() {
+		metadataHandler = NewMetadataHandler(catalog)
+		recorder = httptest.NewRecorder()
+		metadataHandler.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+	})
+
+	It("returns the observed metric families as JSON", func() {
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(resp.Status).To(Equal("success"))
+		Expect(resp.Data.Families).To(HaveLen(3))
+		Expect(resp.Data.Families[2].Name).To(Equal("firehose_value_metric_fake_origin_fake_value_metric"))
+		Expect(resp.Data.Families[2].Source).To(Equal("value_metric"))
+		Expect(resp.Data.Families[2].Type).To(Equal("gauge"))
+		Expect(resp.Data.Families[2].Help).To(Equal("Fake help."))
+		Expect(resp.Data.Families[2].Unit).To(Equal("ms"))
+		Expect(resp.Data.Families[2].LabelNames).To(Equal([]string{"origin"}))
+		Expect(resp.Data.Families[2].Origins).To(Equal([]string{"fake-origin"}))
+		Expect(resp.Data.Families[2].FirstSeen).ToNot(BeEmpty())
+		Expect(resp.Data.Families[2].LastSeen).ToNot(BeEmpty())
+	})
+
+	Context("when filtering by type and origin", func() {
+		BeforeEach(func() {
+			path = "/api/v1/metadata?type=counter&origin=other-origin"
+		})
+
+		It("returns the matching families", func() {
+			Expect(resp.Data.Families).To(HaveLen(1))
+			Expect(resp.Data.Families[0].Name).To(Equal("firehose_counter_event_other_origin_fake_counter_event_total"))
+		})
+	})
+
+	Context("when filtering by name and source", func() {
+		BeforeEach(func() {
+			path = "/api/v1/metadata?name=fake_origin&source=value_metric"
+		})
+
+		It("returns the matching families", func() {
+			Expect(resp.Data.Families).To(HaveLen(1))
+			Expect(resp.Data.Families[0].Name).To(Equal("firehose_value_metric_fake_origin_fake_value_metric"))
+		})
+	})
+
+	Context("when the type is not supported", func() {
+		BeforeEach(func() {
+			path = "/api/v1/metadata?type=histogram"
+		})
+
+		It("returns a bad request error", func() {
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(resp.Status).To(Equal("error"))
+			Expect(resp.Error).To(ContainSubstring("histogram"))
+		})
+	})
+
+	Context("when the method is not GET", func() {
+		BeforeEach(func() {
+			method = "POST"
+		})
+
+		It("returns a method not allowed error", func() {
+			Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+})
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/prometheus/common/log"
+
+	"github.com/bosh-prometheus/firehose_exporter/metrics"
+)
+
+const (
+	defaultSeriesLimit = 100
+	maxSeriesLimit     = 10000
+)
+
+type response struct {
+	Status string      `json:"status"`
+	Data   interface{} `json:"data,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+type seriesData struct {
+	Total  int              `json:"total"`
+	Offset int              `json:"offset"`
+	Limit  int              `json:"limit"`
+	Series []metrics.Series `json:"series"`
+}
+
+type SeriesHandler struct {
+	metricsStore *metrics.Store
+}
+
+func NewSeriesHandler(metricsStore *metrics.Store) *SeriesHandler {
+	return &SeriesHandler{metricsStore: metricsStore}
+}
+
+func (h *SeriesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method `%s` is not allowed", r.Method))
+		return
+	}
+
+	query, err := parseSeriesQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	offset, err := parseIntParam(r, "offset", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limit, err := parseIntParam(r, "limit", defaultSeriesLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if limit > maxSeriesLimit {
+		limit = maxSeriesLimit
+	}
+
+	series := h.metricsStore.QuerySeries(query)
+
+	data := seriesData{
+		Total:  len(series),
+		Offset: offset,
+		Limit:  limit,
+		Series: []metrics.Series{},
+	}
+	if offset < len(series) {
+		end := offset + limit
+		if end > len(series) {
+			end = len(series)
+		}
+		data.Series = series[offset:end]
+	}
+
+	writeResponse(w, http.StatusOK, response{Status: "success", Data: data})
+}
+
+func parseSeriesQuery(r *http.Request) (metrics.SeriesQuery, error) {
+	params := r.URL.Query()
+
+	query := metrics.SeriesQuery{
+		EventType:  params.Get("event_type"),
+		Origin:     params.Get("origin"),
+		Deployment: params.Get("deployment"),
+	}
+
+	if query.EventType != "" {
+		if _, ok := events.Envelope_EventType_value[query.EventType]; !ok {
+			return query, fmt.Errorf("Event type `%s` is not supported", query.EventType)
+		}
+	}
+
+	if name := params.Get("name"); name != "" {
+		nameRE, err := regexp.Compile(name)
+		if err != nil {
+			return query, fmt.Errorf("Invalid name expression `%s`: %s", name, err)
+		}
+		query.Name = nameRE
+	}
+
+	for _, tag := range params["tag"] {
+		parts := strings.SplitN(tag, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return query, fmt.Errorf("Invalid tag `%s`, expected `key:value`", tag)
+		}
+		if query.Tags == nil {
+			query.Tags = map[string]string{}
+		}
+		query.Tags[parts[0]] = parts[1]
+	}
+
+	return query, nil
+}
+
+func parseIntParam(r *http.Request, name string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	intValue, err := strconv.Atoi(value)
+	if err != nil || intValue < 0 {
+		return 0, fmt.Errorf("Invalid `%s` parameter `%s`", name, value)
+	}
+
+	return intValue, nil
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeResponse(w, status, response{Status: "error", Error: err.Error()})
+}
+
+func writeResponse(w http.ResponseWriter, status int, resp response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Errorf("Error encoding API response: %s", err)
+	}
+}
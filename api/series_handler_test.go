@@ -0,0 +1,157 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bosh-prometheus/firehose_exporter/filters"
+	"github.com/bosh-prometheus/firehose_exporter/metrics"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/bosh-prometheus/firehose_exporter/api"
+)
+
+var _ = Describe("SeriesHandler", func() {
+	type seriesResponse struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			Total  int `json:"total"`
+			Offset int `json:"offset"`
+			Limit  int `json:"limit"`
+			Series []struct {
+				EventType  string                 `json:"event_type"`
+				ReceivedAt string                 `json:"received_at"`
+				TTLSeconds float64                `json:"ttl_seconds"`
+				Metric     map[string]interface{} `json:"metric"`
+			} `json:"series"`
+		} `json:"data"`
+	}
+
+	var (
+		metricsStore  *metrics.Store
+		seriesHandler *SeriesHandler
+		path          string
+		recorder      *httptest.ResponseRecorder
+		resp          seriesResponse
+	)
+
+	BeforeEach(func() {
+		deploymentFilter := filters.NewDeploymentFilter([]string{})
+		eventFilter, _ := filters.NewEventFilter([]string{})
+		metricsStore = metrics.NewStore(time.Minute, time.Minute, deploymentFilter, eventFilter)
+
+		for _, name := range []string{"FakeValueMetric1", "FakeValueMetric2", "FakeValueMetric3"} {
+			metricsStore.AddMetric(
+				&events.Envelope{
+					Origin:     proto.String("fake-origin"),
+					EventType:  events.Envelope_ValueMetric.Enum(),
+					Timestamp:  proto.Int64(time.Now().UnixNano()),
+					Deployment: proto.String("fake-deployment-name"),
+					Tags:       map[string]string{"source_id": name},
+					ValueMetric: &events.ValueMetric{
+						Name:  proto.String(name),
+						Value: proto.Float64(1),
+						Unit:  proto.String("count"),
+					},
+				},
+			)
+		}
+
+		path = "/api/v1/series"
+		resp = seriesResponse{}
+	})
+
+	JustBeforeEach(func() {
+		seriesHandler = NewSeriesHandler(metricsStore)
+		recorder = httptest.NewRecorder()
+		seriesHandler.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+	})
+
+	It("returns the cached series as JSON", func() {
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(resp.Status).To(Equal("success"))
+		Expect(resp.Data.Total).To(Equal(3))
+		Expect(resp.Data.Series).To(HaveLen(3))
+		Expect(resp.Data.Series[0].EventType).To(Equal("ValueMetric"))
+		Expect(resp.Data.Series[0].ReceivedAt).ToNot(BeEmpty())
+		Expect(resp.Data.Series[0].TTLSeconds).To(BeNumerically(">", 0))
+		Expect(resp.Data.Series[0].Metric).To(HaveKeyWithValue("Origin", "fake-origin"))
+	})
+
+	Context("when paginating", func() {
+		BeforeEach(func() {
+			path = "/api/v1/series?offset=1&limit=1"
+		})
+
+		It("returns the requested page", func() {
+			Expect(resp.Data.Total).To(Equal(3))
+			Expect(resp.Data.Offset).To(Equal(1))
+			Expect(resp.Data.Limit).To(Equal(1))
+			Expect(resp.Data.Series).To(HaveLen(1))
+		})
+	})
+
+	Context("when the offset is past the last series", func() {
+		BeforeEach(func() {
+			path = "/api/v1/series?offset=10"
+		})
+
+		It("returns an empty page", func() {
+			Expect(resp.Data.Total).To(Equal(3))
+			Expect(resp.Data.Series).To(BeEmpty())
+		})
+	})
+
+	Context("when filtering by name and tags", func() {
+		BeforeEach(func() {
+			path = "/api/v1/series?event_type=ValueMetric&name=Metric[12]&tag=source_id:FakeValueMetric2"
+		})
+
+		It("returns the matching series", func() {
+			Expect(resp.Data.Total).To(Equal(1))
+			Expect(resp.Data.Series[0].Metric).To(HaveKeyWithValue("Name", "FakeValueMetric2"))
+		})
+	})
+
+	Context("when the name expression is invalid", func() {
+		BeforeEach(func() {
+			path = "/api/v1/series?name=("
+		})
+
+		It("returns a bad request error", func() {
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(resp.Status).To(Equal("error"))
+			Expect(resp.Error).To(ContainSubstring("Invalid name expression"))
+		})
+	})
+
+	Context("when the event type is not supported", func() {
+		BeforeEach(func() {
+			path = "/api/v1/series?event_type=FakeEvent"
+		})
+
+		It("returns a bad request error", func() {
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(resp.Error).To(ContainSubstring("FakeEvent"))
+		})
+	})
+
+	Context("when a tag is invalid", func() {
+		BeforeEach(func() {
+			path = "/api/v1/series?tag=source_id"
+		})
+
+		It("returns a bad request error", func() {
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package boshdirector_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestBoshDirector(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "BOSH Director Suite")
+}
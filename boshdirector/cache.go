@@ -0,0 +1,113 @@
+package boshdirector
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+// Cache keeps the BOSH director instances in memory, refreshing them
+// periodically so metrics can be enriched without querying the director on
+// the hot path.
+type Cache struct {
+	client          *Client
+	refreshInterval time.Duration
+
+	mutex     sync.RWMutex
+	instances map[string]Instance
+	stop      chan struct{}
+}
+
+func NewCache(client *Client, refreshInterval time.Duration) *Cache {
+	return &Cache{
+		client:          client,
+		refreshInterval: refreshInterval,
+		instances:       map[string]Instance{},
+		stop:            make(chan struct{}),
+	}
+}
+
+// Refresh replaces the cached instances with the ones listed by the BOSH
+// director. On error the previous instances are kept.
+func (c *Cache) Refresh() error {
+	instances, err := c.client.ListInstances()
+	if err != nil {
+		return err
+	}
+
+	c.Set(instances)
+
+	return nil
+}
+
+// Set replaces the cached instances, indexed by their deployment and ID (see
+// InstanceKey).
+func (c *Cache) Set(instances map[string]Instance) {
+	c.mutex.Lock()
+	c.instances = instances
+	c.mutex.Unlock()
+}
+
+// Start refreshes the cache every refresh interval in the background, until
+// Stop is called.
+func (c *Cache) Start() {
+	go func() {
+		ticker := time.NewTicker(c.refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := c.Refresh(); err != nil {
+				log.Errorf("Error refreshing BOSH director instances: %v", err)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (c *Cache) Stop() {
+	close(c.stop)
+}
+
+// Lookup returns the cached instance of a deployment with the given ID. A
+// nil cache never finds any instance.
+func (c *Cache) Lookup(deployment string, instanceID string) (Instance, bool) {
+	if c == nil {
+		return Instance{}, false
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	instance, ok := c.instances[InstanceKey(deployment, instanceID)]
+	return instance, ok
+}
+
+// Instances returns the cached instances, sorted by deployment and ID.
+func (c *Cache) Instances() []Instance {
+	if c == nil {
+		return nil
+	}
+
+	c.mutex.RLock()
+	instances := make([]Instance, 0, len(c.instances))
+	for _, instance := range c.instances {
+		instances = append(instances, instance)
+	}
+	c.mutex.RUnlock()
+
+	sort.Slice(instances, func(i, j int) bool {
+		if instances[i].Deployment != instances[j].Deployment {
+			return instances[i].Deployment < instances[j].Deployment
+		}
+		return instances[i].ID < instances[j].ID
+	})
+
+	return instances
+}
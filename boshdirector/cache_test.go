@@ -0,0 +1,101 @@
+package boshdirector_test
+
+import (
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/firehose_exporter/boshdirector"
+)
+
+var _ = Describe("Cache", func() {
+	var (
+		fakeBoshDirector *fakeDirector
+		cache            *Cache
+	)
+
+	BeforeEach(func() {
+		fakeBoshDirector = newFakeDirector()
+		cache = NewCache(NewClient(fakeBoshDirector.URL, fakeDoer{}), 10*time.Millisecond)
+	})
+
+	AfterEach(func() {
+		fakeBoshDirector.Close()
+	})
+
+	Describe("Lookup", func() {
+		It("does not find instances before the cache is refreshed", func() {
+			_, ok := cache.Lookup("cf", "fake-instance-id-1")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("finds set instances", func() {
+			cache.Set(map[string]Instance{InstanceKey("cf", "fake-instance-id-9"): {Deployment: "cf", Job: "router", ID: "fake-instance-id-9", Index: 9}})
+
+			instance, ok := cache.Lookup("cf", "fake-instance-id-9")
+			Expect(ok).To(BeTrue())
+			Expect(instance.Name()).To(Equal("router/9"))
+		})
+
+		It("finds refreshed instances by deployment and ID", func() {
+			Expect(cache.Refresh()).To(Succeed())
+
+			instance, ok := cache.Lookup("cf", "fake-instance-id-1")
+			Expect(ok).To(BeTrue())
+			Expect(instance.Name()).To(Equal("router/0"))
+			Expect(instance.VMCID).To(Equal("fake-vm-cid-1"))
+
+			_, ok = cache.Lookup("fake-deployment", "fake-instance-id-1")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("keeps the previous instances when a refresh fails", func() {
+			Expect(cache.Refresh()).To(Succeed())
+
+			fakeBoshDirector.setStatusCode(http.StatusInternalServerError)
+			Expect(cache.Refresh()).ToNot(Succeed())
+
+			_, ok := cache.Lookup("cf", "fake-instance-id-1")
+			Expect(ok).To(BeTrue())
+		})
+
+		It("never finds instances on a nil cache", func() {
+			var nilCache *Cache
+			_, ok := nilCache.Lookup("cf", "fake-instance-id-1")
+			Expect(ok).To(BeFalse())
+			Expect(nilCache.Instances()).To(BeEmpty())
+		})
+	})
+
+	Describe("Instances", func() {
+		It("returns the instances sorted by deployment and ID", func() {
+			Expect(cache.Refresh()).To(Succeed())
+
+			instances := cache.Instances()
+			Expect(instances).To(HaveLen(3))
+			Expect(instances[0].ID).To(Equal("fake-instance-id-1"))
+			Expect(instances[1].ID).To(Equal("fake-instance-id-2"))
+			Expect(instances[2].Deployment).To(Equal("fake-deployment"))
+		})
+	})
+
+	Describe("Start", func() {
+		It("refreshes the instances periodically", func() {
+			cache.Start()
+			defer cache.Stop()
+
+			Eventually(func() string {
+				instance, _ := cache.Lookup("cf", "fake-instance-id-1")
+				return instance.AZ
+			}).Should(Equal("z1"))
+
+			fakeBoshDirector.setRouterAZ("z3")
+			Eventually(func() string {
+				instance, _ := cache.Lookup("cf", "fake-instance-id-1")
+				return instance.AZ
+			}).Should(Equal("z3"))
+		})
+	})
+})
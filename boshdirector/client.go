@@ -0,0 +1,195 @@
+package boshdirector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Doer performs authenticated requests, such as authclient.AuthClient.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type Instance struct {
+	Deployment string
+	Job        string
+	ID         string
+	Index      int
+	AZ         string
+	VMCID      string
+	Stemcell   string
+}
+
+// Name returns the human readable name of the instance, `<job>/<index>`.
+func (i Instance) Name() string {
+	return fmt.Sprintf("%s/%d", i.Job, i.Index)
+}
+
+type Client struct {
+	directorURL      string
+	doer             Doer
+	taskPollInterval time.Duration
+	taskTimeout      time.Duration
+}
+
+type deployment struct {
+	Name string `json:"name"`
+}
+
+type task struct {
+	ID    int    `json:"id"`
+	State string `json:"state"`
+}
+
+type instance struct {
+	ID       string `json:"id"`
+	JobName  string `json:"job_name"`
+	Index    *int   `json:"index"`
+	AZ       string `json:"az"`
+	VMCID    string `json:"vm_cid"`
+	Stemcell struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"stemcell"`
+}
+
+func NewClient(directorURL string, doer Doer) *Client {
+	return &Client{
+		directorURL:      strings.TrimSuffix(directorURL, "/"),
+		doer:             doer,
+		taskPollInterval: time.Second,
+		taskTimeout:      5 * time.Minute,
+	}
+}
+
+// ListInstances returns the instances of all the deployments visible to the
+// client, indexed by their deployment and ID (see InstanceKey). The stemcell
+// of an instance is the one of its VM, as `<name>/<version>`, and is empty
+// for instances without VM.
+func (c *Client) ListInstances() (map[string]Instance, error) {
+	var deployments []deployment
+	if err := c.get("/deployments", &deployments); err != nil {
+		return nil, err
+	}
+
+	instances := map[string]Instance{}
+	for _, d := range deployments {
+		deploymentInstances, err := c.listDeploymentInstances(d.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, i := range deploymentInstances {
+			if i.ID == "" {
+				continue
+			}
+			index := 0
+			if i.Index != nil {
+				index = *i.Index
+			}
+			var stemcell string
+			if i.Stemcell.Name != "" {
+				stemcell = i.Stemcell.Name + "/" + i.Stemcell.Version
+			}
+			instances[InstanceKey(d.Name, i.ID)] = Instance{
+				Deployment: d.Name,
+				Job:        i.JobName,
+				ID:         i.ID,
+				Index:      index,
+				AZ:         i.AZ,
+				VMCID:      i.VMCID,
+				Stemcell:   stemcell,
+			}
+		}
+	}
+
+	return instances, nil
+}
+
+// listDeploymentInstances returns the full details of the instances of a
+// deployment. The BOSH director only reports the stemcell of each instance in
+// the full details, which it gathers in a task.
+func (c *Client) listDeploymentInstances(deploymentName string) ([]instance, error) {
+	var t task
+	if err := c.get("/deployments/"+url.PathEscape(deploymentName)+"/instances?format=full", &t); err != nil {
+		return nil, err
+	}
+
+	taskPath := fmt.Sprintf("/tasks/%d", t.ID)
+	deadline := time.Now().Add(c.taskTimeout)
+	for t.State == "queued" || t.State == "processing" {
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("BOSH director task `%d` listing the instances of deployment `%s` did not finish in %s", t.ID, deploymentName, c.taskTimeout)
+		}
+		time.Sleep(c.taskPollInterval)
+		if err := c.get(taskPath, &t); err != nil {
+			return nil, err
+		}
+	}
+	if t.State != "done" {
+		return nil, fmt.Errorf("BOSH director task `%d` listing the instances of deployment `%s` ended in state `%s`", t.ID, deploymentName, t.State)
+	}
+
+	body, err := c.do(taskPath + "/output?type=result")
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var instances []instance
+	decoder := json.NewDecoder(body)
+	for decoder.More() {
+		var i instance
+		if err := decoder.Decode(&i); err != nil {
+			return nil, fmt.Errorf("Error decoding BOSH director task `%d` output: %v", t.ID, err)
+		}
+		instances = append(instances, i)
+	}
+
+	return instances, nil
+}
+
+// InstanceKey returns the key of an instance of a deployment.
+func InstanceKey(deployment string, instanceID string) string {
+	return deployment + "/" + instanceID
+}
+
+func (c *Client) get(path string, v interface{}) error {
+	body, err := c.do(path)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		return fmt.Errorf("Error decoding BOSH director `%s`: %v", path, err)
+	}
+
+	return nil
+}
+
+// do gets a BOSH director path, following the redirections to the tasks it
+// starts, and returns the response body.
+func (c *Client) do(path string) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", c.directorURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doer.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Unexpected status code `%d` getting BOSH director `%s`", resp.StatusCode, path)
+	}
+
+	return resp.Body, nil
+}
@@ -0,0 +1,66 @@
+package boshdirector_test
+
+import (
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/firehose_exporter/boshdirector"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		fakeBoshDirector *fakeDirector
+		client           *Client
+	)
+
+	BeforeEach(func() {
+		fakeBoshDirector = newFakeDirector()
+		client = NewClient(fakeBoshDirector.URL+"/", fakeDoer{})
+	})
+
+	AfterEach(func() {
+		fakeBoshDirector.Close()
+	})
+
+	Describe("ListInstances", func() {
+		It("returns the instances of all deployments with their AZ, VM CID and VM stemcell", func() {
+			instances, err := client.ListInstances()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instances).To(Equal(map[string]Instance{
+				"cf/fake-instance-id-1":              {Deployment: "cf", Job: "router", ID: "fake-instance-id-1", Index: 0, AZ: "z1", VMCID: "fake-vm-cid-1", Stemcell: "bosh-warden-boshlite-ubuntu-bionic-go_agent/1.10"},
+				"cf/fake-instance-id-2":              {Deployment: "cf", Job: "router", ID: "fake-instance-id-2", Index: 1, AZ: "z2", VMCID: "fake-vm-cid-2", Stemcell: "bosh-warden-boshlite-ubuntu-jammy-go_agent/1.20"},
+				"fake-deployment/fake-instance-id-3": {Deployment: "fake-deployment", Job: "errand", ID: "fake-instance-id-3", Index: 0},
+			}))
+		})
+
+		Context("when the BOSH director fails", func() {
+			BeforeEach(func() {
+				fakeBoshDirector.setStatusCode(http.StatusInternalServerError)
+			})
+
+			It("returns an error", func() {
+				_, err := client.ListInstances()
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Context("when the BOSH director task fails", func() {
+			BeforeEach(func() {
+				fakeBoshDirector.setTaskState("error")
+			})
+
+			It("returns an error", func() {
+				_, err := client.ListInstances()
+				Expect(err).To(MatchError(ContainSubstring("ended in state `error`")))
+			})
+		})
+	})
+
+	Describe("Instance", func() {
+		It("is named after its job and index", func() {
+			Expect(Instance{Job: "router", ID: "fake-instance-id-2", Index: 1}.Name()).To(Equal("router/1"))
+		})
+	})
+})
@@ -0,0 +1,97 @@
+package boshdirector_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// fakeDirector serves two deployments with their instances, listed in tasks
+// as the BOSH director does for the full instance details.
+type fakeDirector struct {
+	*httptest.Server
+
+	mutex      sync.Mutex
+	statusCode int
+	taskState  string
+	routerAZ   string
+}
+
+func newFakeDirector() *fakeDirector {
+	fake := &fakeDirector{statusCode: http.StatusOK, taskState: "done", routerAZ: "z1"}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	return fake
+}
+
+func (f *fakeDirector) setStatusCode(statusCode int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.statusCode = statusCode
+}
+
+func (f *fakeDirector) setTaskState(taskState string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.taskState = taskState
+}
+
+func (f *fakeDirector) setRouterAZ(routerAZ string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.routerAZ = routerAZ
+}
+
+func (f *fakeDirector) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if r.Header.Get("Authorization") != "bearer fake-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if f.statusCode != http.StatusOK {
+		w.WriteHeader(f.statusCode)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/deployments":
+		fmt.Fprint(w, `[
+  {"name": "cf", "releases": [{"name": "routing", "version": "0.200.0"}], "stemcells": [{"name": "bosh-warden-boshlite-ubuntu-bionic-go_agent", "version": "1.10"}, {"name": "bosh-warden-boshlite-ubuntu-jammy-go_agent", "version": "1.20"}]},
+  {"name": "fake-deployment", "releases": [], "stemcells": []}
+]`)
+	case "/deployments/cf/instances":
+		f.redirectToTask(w, r, 1)
+	case "/deployments/fake-deployment/instances":
+		f.redirectToTask(w, r, 2)
+	case "/tasks/1", "/tasks/2":
+		fmt.Fprintf(w, `{"id": %s, "state": "%s", "description": "retrieve vm-stats"}`, strings.TrimPrefix(r.URL.Path, "/tasks/"), f.taskState)
+	case "/tasks/1/output":
+		fmt.Fprintf(w, `{"agent_id": "fake-agent-id-1", "vm_cid": "fake-vm-cid-1", "job_name": "router", "index": 0, "id": "fake-instance-id-1", "az": "%s", "ips": ["10.0.0.1"], "stemcell": {"name": "bosh-warden-boshlite-ubuntu-bionic-go_agent", "version": "1.10", "api_version": 3}}
+{"agent_id": "fake-agent-id-2", "vm_cid": "fake-vm-cid-2", "job_name": "router", "index": 1, "id": "fake-instance-id-2", "az": "z2", "ips": ["10.0.0.2"], "stemcell": {"name": "bosh-warden-boshlite-ubuntu-jammy-go_agent", "version": "1.20", "api_version": 3}}
+`, f.routerAZ)
+	case "/tasks/2/output":
+		fmt.Fprint(w, `{"agent_id": null, "vm_cid": null, "job_name": "errand", "index": 0, "id": "fake-instance-id-3", "az": null, "ips": [], "stemcell": {}}
+`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeDirector) redirectToTask(w http.ResponseWriter, r *http.Request, taskID int) {
+	if r.URL.Query().Get("format") != "full" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/tasks/%d", taskID), http.StatusFound)
+}
+
+type fakeDoer struct{}
+
+func (d fakeDoer) Do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Authorization", "bearer fake-token")
+	return http.DefaultClient.Do(req)
+}
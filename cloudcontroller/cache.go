@@ -0,0 +1,88 @@
+package cloudcontroller
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+// Cache keeps the Cloud Controller applications in memory, refreshing them
+// periodically so metrics can be enriched without querying the API on the
+// hot path.
+type Cache struct {
+	client          *Client
+	refreshInterval time.Duration
+
+	mutex        sync.RWMutex
+	applications map[string]Application
+	stop         chan struct{}
+}
+
+func NewCache(client *Client, refreshInterval time.Duration) *Cache {
+	return &Cache{
+		client:          client,
+		refreshInterval: refreshInterval,
+		applications:    map[string]Application{},
+		stop:            make(chan struct{}),
+	}
+}
+
+// Refresh replaces the cached applications with the ones listed by the
+// Cloud Controller. On error the previous applications are kept.
+func (c *Cache) Refresh() error {
+	applications, err := c.client.ListApplications()
+	if err != nil {
+		return err
+	}
+
+	c.Set(applications)
+
+	return nil
+}
+
+// Set replaces the cached applications, indexed by GUID.
+func (c *Cache) Set(applications map[string]Application) {
+	c.mutex.Lock()
+	c.applications = applications
+	c.mutex.Unlock()
+}
+
+// Start refreshes the cache every refresh interval in the background, until
+// Stop is called.
+func (c *Cache) Start() {
+	go func() {
+		ticker := time.NewTicker(c.refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := c.Refresh(); err != nil {
+				log.Errorf("Error refreshing Cloud Controller applications: %v", err)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (c *Cache) Stop() {
+	close(c.stop)
+}
+
+// Lookup returns the cached application with the given GUID. A nil cache
+// never finds any application.
+func (c *Cache) Lookup(applicationID string) (Application, bool) {
+	if c == nil {
+		return Application{}, false
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	application, ok := c.applications[applicationID]
+	return application, ok
+}
@@ -0,0 +1,82 @@
+package cloudcontroller_test
+
+import (
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/firehose_exporter/cloudcontroller"
+)
+
+var _ = Describe("Cache", func() {
+	var (
+		fakeCC *fakeCloudController
+		cache  *Cache
+	)
+
+	BeforeEach(func() {
+		fakeCC = newFakeCloudController()
+		cache = NewCache(NewClient(fakeCC.URL, fakeDoer{}), 10*time.Millisecond)
+	})
+
+	AfterEach(func() {
+		fakeCC.Close()
+	})
+
+	Describe("Lookup", func() {
+		It("does not find applications before the cache is refreshed", func() {
+			_, ok := cache.Lookup("fake-app-guid-1")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("finds refreshed applications", func() {
+			Expect(cache.Refresh()).To(Succeed())
+
+			application, ok := cache.Lookup("fake-app-guid-1")
+			Expect(ok).To(BeTrue())
+			Expect(application).To(Equal(Application{Name: "fake-app-1", SpaceName: "fake-space-1", OrganizationName: "fake-org"}))
+		})
+
+		It("keeps the previous applications when a refresh fails", func() {
+			Expect(cache.Refresh()).To(Succeed())
+			fakeCC.setStatusCode(http.StatusInternalServerError)
+			Expect(cache.Refresh()).ToNot(Succeed())
+
+			_, ok := cache.Lookup("fake-app-guid-1")
+			Expect(ok).To(BeTrue())
+		})
+
+		It("finds set applications", func() {
+			cache.Set(map[string]Application{"fake-app-guid-2": {Name: "fake-app-2"}})
+
+			application, ok := cache.Lookup("fake-app-guid-2")
+			Expect(ok).To(BeTrue())
+			Expect(application.Name).To(Equal("fake-app-2"))
+		})
+
+		It("never finds applications on a nil cache", func() {
+			var nilCache *Cache
+			_, ok := nilCache.Lookup("fake-app-guid-1")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("Start", func() {
+		It("refreshes the applications periodically", func() {
+			cache.Start()
+			defer cache.Stop()
+			Eventually(func() string {
+				application, _ := cache.Lookup("fake-app-guid-1")
+				return application.Name
+			}).Should(Equal("fake-app-1"))
+
+			fakeCC.setAppName("fake-app-renamed")
+			Eventually(func() string {
+				application, _ := cache.Lookup("fake-app-guid-1")
+				return application.Name
+			}).Should(Equal("fake-app-renamed"))
+		})
+	})
+})
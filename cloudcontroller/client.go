@@ -0,0 +1,131 @@
+package cloudcontroller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Doer performs authenticated requests, such as authclient.AuthClient.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type Application struct {
+	Name             string
+	SpaceName        string
+	OrganizationName string
+}
+
+type Client struct {
+	apiURL string
+	doer   Doer
+}
+
+type relationship struct {
+	Data struct {
+		GUID string `json:"guid"`
+	} `json:"data"`
+}
+
+type appsPage struct {
+	Pagination struct {
+		Next *struct {
+			Href string `json:"href"`
+		} `json:"next"`
+	} `json:"pagination"`
+	Resources []struct {
+		GUID          string `json:"guid"`
+		Name          string `json:"name"`
+		Relationships struct {
+			Space relationship `json:"space"`
+		} `json:"relationships"`
+	} `json:"resources"`
+	Included struct {
+		Spaces []struct {
+			GUID          string `json:"guid"`
+			Name          string `json:"name"`
+			Relationships struct {
+				Organization relationship `json:"organization"`
+			} `json:"relationships"`
+		} `json:"spaces"`
+		Organizations []struct {
+			GUID string `json:"guid"`
+			Name string `json:"name"`
+		} `json:"organizations"`
+	} `json:"included"`
+}
+
+func NewClient(apiURL string, doer Doer) *Client {
+	return &Client{
+		apiURL: strings.TrimSuffix(apiURL, "/"),
+		doer:   doer,
+	}
+}
+
+// ListApplications returns all the applications visible to the client,
+// indexed by their GUID, using the Cloud Controller v3 API.
+func (c *Client) ListApplications() (map[string]Application, error) {
+	applications := map[string]Application{}
+
+	url := c.apiURL + "/v3/apps?per_page=5000&include=space.organization"
+	for url != "" {
+		page, err := c.getAppsPage(url)
+		if err != nil {
+			return nil, err
+		}
+
+		spaces := map[string]string{}
+		spaceOrganizations := map[string]string{}
+		for _, space := range page.Included.Spaces {
+			spaces[space.GUID] = space.Name
+			spaceOrganizations[space.GUID] = space.Relationships.Organization.Data.GUID
+		}
+
+		organizations := map[string]string{}
+		for _, organization := range page.Included.Organizations {
+			organizations[organization.GUID] = organization.Name
+		}
+
+		for _, app := range page.Resources {
+			spaceGUID := app.Relationships.Space.Data.GUID
+			applications[app.GUID] = Application{
+				Name:             app.Name,
+				SpaceName:        spaces[spaceGUID],
+				OrganizationName: organizations[spaceOrganizations[spaceGUID]],
+			}
+		}
+
+		url = ""
+		if page.Pagination.Next != nil {
+			url = page.Pagination.Next.Href
+		}
+	}
+
+	return applications, nil
+}
+
+func (c *Client) getAppsPage(url string) (*appsPage, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doer.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unexpected status code `%d` listing Cloud Controller apps", resp.StatusCode)
+	}
+
+	page := &appsPage{}
+	if err := json.NewDecoder(resp.Body).Decode(page); err != nil {
+		return nil, fmt.Errorf("Error decoding Cloud Controller apps: %v", err)
+	}
+
+	return page, nil
+}
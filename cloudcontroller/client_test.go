@@ -0,0 +1,48 @@
+package cloudcontroller_test
+
+import (
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/firehose_exporter/cloudcontroller"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		fakeCC *fakeCloudController
+		client *Client
+	)
+
+	BeforeEach(func() {
+		fakeCC = newFakeCloudController()
+		client = NewClient(fakeCC.URL+"/", fakeDoer{})
+	})
+
+	AfterEach(func() {
+		fakeCC.Close()
+	})
+
+	Describe("ListApplications", func() {
+		It("returns the applications of all pages with their space and organization", func() {
+			applications, err := client.ListApplications()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(applications).To(Equal(map[string]Application{
+				"fake-app-guid-1": {Name: "fake-app-1", SpaceName: "fake-space-1", OrganizationName: "fake-org"},
+				"fake-app-guid-2": {Name: "fake-app-2", SpaceName: "fake-space-2", OrganizationName: "fake-org"},
+			}))
+		})
+
+		Context("when the Cloud Controller fails", func() {
+			BeforeEach(func() {
+				fakeCC.setStatusCode(http.StatusInternalServerError)
+			})
+
+			It("returns an error", func() {
+				_, err := client.ListApplications()
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package cloudcontroller_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestCloudController(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Cloud Controller Suite")
+}
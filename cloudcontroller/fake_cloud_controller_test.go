@@ -0,0 +1,91 @@
+package cloudcontroller_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// fakeCloudController serves two pages of CC v3 apps.
+type fakeCloudController struct {
+	*httptest.Server
+
+	mutex      sync.Mutex
+	requests   int
+	statusCode int
+	appName    string
+}
+
+func newFakeCloudController() *fakeCloudController {
+	fake := &fakeCloudController{statusCode: http.StatusOK, appName: "fake-app-1"}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	return fake
+}
+
+func (f *fakeCloudController) setStatusCode(statusCode int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.statusCode = statusCode
+}
+
+func (f *fakeCloudController) setAppName(appName string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.appName = appName
+}
+
+func (f *fakeCloudController) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.requests++
+
+	if r.URL.Path != "/v3/apps" || r.Header.Get("Authorization") != "bearer fake-token" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if f.statusCode != http.StatusOK {
+		w.WriteHeader(f.statusCode)
+		return
+	}
+
+	if r.URL.Query().Get("page") == "2" {
+		fmt.Fprint(w, `{
+  "pagination": {"next": null},
+  "resources": [
+    {"guid": "fake-app-guid-2", "name": "fake-app-2", "relationships": {"space": {"data": {"guid": "fake-space-guid-2"}}}}
+  ],
+  "included": {
+    "spaces": [
+      {"guid": "fake-space-guid-2", "name": "fake-space-2", "relationships": {"organization": {"data": {"guid": "fake-org-guid"}}}}
+    ],
+    "organizations": [
+      {"guid": "fake-org-guid", "name": "fake-org"}
+    ]
+  }
+}`)
+		return
+	}
+
+	fmt.Fprintf(w, `{
+  "pagination": {"next": {"href": "%s/v3/apps?page=2&per_page=5000&include=space.organization"}},
+  "resources": [
+    {"guid": "fake-app-guid-1", "name": "%s", "relationships": {"space": {"data": {"guid": "fake-space-guid-1"}}}}
+  ],
+  "included": {
+    "spaces": [
+      {"guid": "fake-space-guid-1", "name": "fake-space-1", "relationships": {"organization": {"data": {"guid": "fake-org-guid"}}}}
+    ],
+    "organizations": [
+      {"guid": "fake-org-guid", "name": "fake-org"}
+    ]
+  }
+}`, f.URL, f.appName)
+}
+
+type fakeDoer struct{}
+
+func (d fakeDoer) Do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Authorization", "bearer fake-token")
+	return http.DefaultClient.Do(req)
+}
@@ -0,0 +1,80 @@
+package collectors
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/bosh-prometheus/firehose_exporter/boshdirector"
+)
+
+// boshInstanceLabelNames are the labels added to the value metrics and
+// counter events when they are enriched with their BOSH instance.
+var boshInstanceLabelNames = []string{"bosh_instance_name", "bosh_az", "bosh_vm_cid", "bosh_stemcell"}
+
+// boshInstanceLabelValues returns the values of the boshInstanceLabelNames of
+// an envelope instance, empty if it is unknown.
+func boshInstanceLabelValues(boshInstances *boshdirector.Cache, deployment string, instanceID string) []string {
+	instance, ok := boshInstances.Lookup(deployment, instanceID)
+	if !ok {
+		return []string{"", "", "", ""}
+	}
+
+	return []string{instance.Name(), instance.AZ, instance.VMCID, instance.Stemcell}
+}
+
+type BoshInstancesCollector struct {
+	namespace                  string
+	environment                string
+	boshInstances              *boshdirector.Cache
+	boshInstanceInfoDesc       *prometheus.Desc
+	boshInstancesCollectorDesc *prometheus.Desc
+}
+
+func NewBoshInstancesCollector(
+	namespace string,
+	environment string,
+	boshInstances *boshdirector.Cache,
+) *BoshInstancesCollector {
+	boshInstanceInfoDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, bosh_instances_subsystem, "info"),
+		"Labeled BOSH instance info with a constant '1' value, to join with the metrics of its 'bosh_deployment' and 'bosh_job_id'.",
+		[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_instance_name", "bosh_az", "bosh_vm_cid", "bosh_stemcell"},
+		prometheus.Labels{"environment": environment},
+	)
+
+	boshInstancesCollectorDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, bosh_instances_subsystem, "collector"),
+		"BOSH instances collector.",
+		nil,
+		prometheus.Labels{"environment": environment},
+	)
+
+	return &BoshInstancesCollector{
+		namespace:                  namespace,
+		environment:                environment,
+		boshInstances:              boshInstances,
+		boshInstanceInfoDesc:       boshInstanceInfoDesc,
+		boshInstancesCollectorDesc: boshInstancesCollectorDesc,
+	}
+}
+
+func (c BoshInstancesCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, instance := range c.boshInstances.Instances() {
+		ch <- prometheus.MustNewConstMetric(
+			c.boshInstanceInfoDesc,
+			prometheus.GaugeValue,
+			1,
+			instance.Deployment,
+			instance.Job,
+			instance.ID,
+			instance.Name(),
+			instance.AZ,
+			instance.VMCID,
+			instance.Stemcell,
+		)
+	}
+}
+
+func (c BoshInstancesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.boshInstancesCollectorDesc
+	ch <- c.boshInstanceInfoDesc
+}
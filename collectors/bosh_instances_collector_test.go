@@ -0,0 +1,114 @@
+package collectors_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bosh-prometheus/firehose_exporter/boshdirector"
+	"github.com/prometheus/client_golang/prometheus"
+
+	. "github.com/bosh-prometheus/firehose_exporter/collectors"
+	. "github.com/bosh-prometheus/firehose_exporter/utils/test_matchers"
+)
+
+var _ = Describe("BoshInstancesCollector", func() {
+	var (
+		namespace              string
+		environment            string
+		boshInstances          *boshdirector.Cache
+		boshInstancesCollector *BoshInstancesCollector
+
+		boshInstancesCollectorDesc *prometheus.Desc
+		boshInstanceInfoDesc       *prometheus.Desc
+	)
+
+	BeforeEach(func() {
+		namespace = "test_exporter"
+		environment = "test_environment"
+		boshInstances = boshdirector.NewCache(nil, time.Minute)
+		boshInstances.Set(map[string]boshdirector.Instance{
+			boshdirector.InstanceKey("fake-deployment-name", "fake-instance-id"): {Deployment: "fake-deployment-name", Job: "fake-job-name", ID: "fake-instance-id", Index: 1, AZ: "z1", VMCID: "fake-vm-cid", Stemcell: "fake-stemcell/1.0"},
+		})
+
+		boshInstancesCollectorDesc = prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "bosh_instance", "collector"),
+			"BOSH instances collector.",
+			nil,
+			prometheus.Labels{"environment": environment},
+		)
+
+		boshInstanceInfoDesc = prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "bosh_instance", "info"),
+			"Labeled BOSH instance info with a constant '1' value, to join with the metrics of its 'bosh_deployment' and 'bosh_job_id'.",
+			[]string{"bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_instance_name", "bosh_az", "bosh_vm_cid", "bosh_stemcell"},
+			prometheus.Labels{"environment": environment},
+		)
+	})
+
+	JustBeforeEach(func() {
+		boshInstancesCollector = NewBoshInstancesCollector(namespace, environment, boshInstances)
+	})
+
+	Describe("Describe", func() {
+		var (
+			descriptions chan *prometheus.Desc
+		)
+
+		BeforeEach(func() {
+			descriptions = make(chan *prometheus.Desc)
+		})
+
+		JustBeforeEach(func() {
+			go boshInstancesCollector.Describe(descriptions)
+		})
+
+		It("returns a bosh_instance_collector metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(boshInstancesCollectorDesc)))
+		})
+
+		It("returns a bosh_instance_info metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(boshInstanceInfoDesc)))
+		})
+	})
+
+	Describe("Collect", func() {
+		var (
+			boshInstancesChan chan prometheus.Metric
+		)
+
+		BeforeEach(func() {
+			boshInstancesChan = make(chan prometheus.Metric)
+		})
+
+		JustBeforeEach(func() {
+			go boshInstancesCollector.Collect(boshInstancesChan)
+		})
+
+		It("returns a bosh_instance_info metric", func() {
+			Eventually(boshInstancesChan).Should(Receive(PrometheusMetric(prometheus.MustNewConstMetric(
+				boshInstanceInfoDesc,
+				prometheus.GaugeValue,
+				1,
+				"fake-deployment-name",
+				"fake-job-name",
+				"fake-instance-id",
+				"fake-job-name/1",
+				"z1",
+				"fake-vm-cid",
+				"fake-stemcell/1.0",
+			))))
+		})
+
+		Context("when there is no BOSH director", func() {
+			BeforeEach(func() {
+				boshInstances = nil
+			})
+
+			It("does not return any metric", func() {
+				Consistently(boshInstancesChan).ShouldNot(Receive())
+			})
+		})
+	})
+})
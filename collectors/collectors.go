@@ -2,6 +2,9 @@ package collectors
 
 // Metric name parts.
 const (
+	// BOSH Instances Subsystem.
+	bosh_instances_subsystem = "bosh_instance"
+
 	// Container Metrics Subsystem.
 	container_metrics_subsystem = "container_metric"
 
@@ -11,6 +14,15 @@ const (
 	// HttpStartStop Subsystem.
 	http_start_stop_subsystem = "http_start_stop"
 
+	// Mappings Subsystem.
+	mappings_subsystem = "mappings"
+
 	// Value Metrics Subsystem.
 	value_metrics_subsystem = "value_metric"
 )
+
+// builtinLabels returns the labels tags must not collide with: the variable
+// labels of a metric plus the environment const label.
+func builtinLabels(labelNames []string) []string {
+	return append(labelNames[:len(labelNames):len(labelNames)], "environment")
+}
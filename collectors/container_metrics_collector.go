@@ -1,29 +1,55 @@
 package collectors
 
 import (
+	"fmt"
 	"strconv"
 
 	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/bosh-prometheus/firehose_exporter/cloudcontroller"
 	"github.com/bosh-prometheus/firehose_exporter/metrics"
+	"github.com/bosh-prometheus/firehose_exporter/tagpolicy"
+	"github.com/bosh-prometheus/firehose_exporter/utils"
 )
 
 type ContainerMetricsCollector struct {
-	namespace              string
-	environment            string
-	metricsStore           *metrics.Store
-	cpuPercentageMetric    *prometheus.GaugeVec
-	memoryBytesMetric      *prometheus.GaugeVec
-	diskBytesMetric        *prometheus.GaugeVec
-	memoryBytesQuotaMetric *prometheus.GaugeVec
-	diskBytesQuotaMetric   *prometheus.GaugeVec
+	namespace                        string
+	environment                      string
+	metricsStore                     *metrics.Store
+	applications                     *cloudcontroller.Cache
+	tagPolicy                        *tagpolicy.Policy
+	cpuPercentageMetric              *prometheus.GaugeVec
+	memoryBytesMetric                *prometheus.GaugeVec
+	diskBytesMetric                  *prometheus.GaugeVec
+	memoryBytesQuotaMetric           *prometheus.GaugeVec
+	diskBytesQuotaMetric             *prometheus.GaugeVec
+	cpuEntitlementPercentageMetric   *prometheus.GaugeVec
+	absoluteUsageSecondsDesc         *prometheus.Desc
+	absoluteEntitlementSecondsDesc   *prometheus.Desc
+	containerAgeNanosecondsMetric    *prometheus.GaugeVec
+	logRateBytesPerSecondMetric      *prometheus.GaugeVec
+	logRateLimitBytesPerSecondMetric *prometheus.GaugeVec
+	memoryUtilizationRatioMetric     *prometheus.GaugeVec
+	diskUtilizationRatioMetric       *prometheus.GaugeVec
+	cpuEntitlementRatioMetric        *prometheus.GaugeVec
+	logRateLimitRatioMetric          *prometheus.GaugeVec
 }
 
 func NewContainerMetricsCollector(
 	namespace string,
 	environment string,
 	metricsStore *metrics.Store,
-) *ContainerMetricsCollector {
+	applications *cloudcontroller.Cache,
+	tagPolicy *tagpolicy.Policy,
+) (*ContainerMetricsCollector, error) {
+	labelNames := []string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "application_id", "application_name", "space_name", "organization_name", "instance_index"}
+	for _, label := range tagPolicy.FixedLabelNames() {
+		if label == "environment" || utils.ContainsString(labelNames, label) {
+			return nil, fmt.Errorf("Tag label `%s` collides with a container metrics label", label)
+		}
+	}
+	labelNames = append(labelNames, tagPolicy.FixedLabelNames()...)
+
 	cpuPercentageMetric := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace:   namespace,
@@ -32,7 +58,7 @@ func NewContainerMetricsCollector(
 			Help:        "Cloud Foundry Firehose container metric: CPU used, on a scale of 0 to 100.",
 			ConstLabels: prometheus.Labels{"environment": environment},
 		},
-		[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "application_id", "instance_index"},
+		labelNames,
 	)
 
 	memoryBytesMetric := prometheus.NewGaugeVec(
@@ -43,7 +69,7 @@ func NewContainerMetricsCollector(
 			Help:        "Cloud Foundry Firehose container metric: bytes of memory used.",
 			ConstLabels: prometheus.Labels{"environment": environment},
 		},
-		[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "application_id", "instance_index"},
+		labelNames,
 	)
 
 	diskBytesMetric := prometheus.NewGaugeVec(
@@ -54,7 +80,7 @@ func NewContainerMetricsCollector(
 			Help:        "Cloud Foundry Firehose container metric: bytes of disk used.",
 			ConstLabels: prometheus.Labels{"environment": environment},
 		},
-		[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "application_id", "instance_index"},
+		labelNames,
 	)
 
 	memoryBytesQuotaMetric := prometheus.NewGaugeVec(
@@ -65,7 +91,7 @@ func NewContainerMetricsCollector(
 			Help:        "Cloud Foundry Firehose container metric: maximum bytes of memory allocated to container.",
 			ConstLabels: prometheus.Labels{"environment": environment},
 		},
-		[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "application_id", "instance_index"},
+		labelNames,
 	)
 
 	diskBytesQuotaMetric := prometheus.NewGaugeVec(
@@ -76,19 +102,133 @@ func NewContainerMetricsCollector(
 			Help:        "Cloud Foundry Firehose container metric: maximum bytes of disk allocated to container.",
 			ConstLabels: prometheus.Labels{"environment": environment},
 		},
-		[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "application_id", "instance_index"},
+		labelNames,
+	)
+
+	cpuEntitlementPercentageMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   container_metrics_subsystem,
+			Name:        "cpu_entitlement_percentage",
+			Help:        "Cloud Foundry Firehose container metric: CPU used relative to the CPU entitlement of the container, on a scale of 0 to 100.",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+		labelNames,
+	)
+
+	absoluteUsageSecondsDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, container_metrics_subsystem, "absolute_usage_seconds_total"),
+		"Cloud Foundry Firehose container metric: total CPU time used by the container, in seconds.",
+		labelNames,
+		prometheus.Labels{"environment": environment},
+	)
+
+	absoluteEntitlementSecondsDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, container_metrics_subsystem, "absolute_entitlement_seconds_total"),
+		"Cloud Foundry Firehose container metric: total CPU time the container is entitled to, in seconds.",
+		labelNames,
+		prometheus.Labels{"environment": environment},
+	)
+
+	containerAgeNanosecondsMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   container_metrics_subsystem,
+			Name:        "container_age_nanoseconds",
+			Help:        "Cloud Foundry Firehose container metric: age of the container, in nanoseconds.",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+		labelNames,
+	)
+
+	logRateBytesPerSecondMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   container_metrics_subsystem,
+			Name:        "log_rate_bytes_per_second",
+			Help:        "Cloud Foundry Firehose container metric: bytes per second of logs emitted.",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+		labelNames,
+	)
+
+	logRateLimitBytesPerSecondMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   container_metrics_subsystem,
+			Name:        "log_rate_limit_bytes_per_second",
+			Help:        "Cloud Foundry Firehose container metric: maximum bytes per second of logs the container can emit (-1 means unlimited).",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+		labelNames,
+	)
+
+	memoryUtilizationRatioMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   container_metrics_subsystem,
+			Name:        "memory_utilization_ratio",
+			Help:        "Cloud Foundry Firehose container metric: ratio of the memory quota used.",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+		labelNames,
+	)
+
+	diskUtilizationRatioMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   container_metrics_subsystem,
+			Name:        "disk_utilization_ratio",
+			Help:        "Cloud Foundry Firehose container metric: ratio of the disk quota used.",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+		labelNames,
+	)
+
+	cpuEntitlementRatioMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   container_metrics_subsystem,
+			Name:        "cpu_entitlement_ratio",
+			Help:        "Cloud Foundry Firehose container metric: ratio of the CPU entitlement used since the container started (absolute usage over absolute entitlement).",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+		labelNames,
+	)
+
+	logRateLimitRatioMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   container_metrics_subsystem,
+			Name:        "log_rate_limit_ratio",
+			Help:        "Cloud Foundry Firehose container metric: ratio of the log rate limit used.",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+		labelNames,
 	)
 
 	return &ContainerMetricsCollector{
-		namespace:              namespace,
-		environment:            environment,
-		metricsStore:           metricsStore,
-		cpuPercentageMetric:    cpuPercentageMetric,
-		memoryBytesMetric:      memoryBytesMetric,
-		diskBytesMetric:        diskBytesMetric,
-		memoryBytesQuotaMetric: memoryBytesQuotaMetric,
-		diskBytesQuotaMetric:   diskBytesQuotaMetric,
-	}
+		namespace:                        namespace,
+		environment:                      environment,
+		metricsStore:                     metricsStore,
+		applications:                     applications,
+		tagPolicy:                        tagPolicy,
+		cpuPercentageMetric:              cpuPercentageMetric,
+		memoryBytesMetric:                memoryBytesMetric,
+		diskBytesMetric:                  diskBytesMetric,
+		memoryBytesQuotaMetric:           memoryBytesQuotaMetric,
+		diskBytesQuotaMetric:             diskBytesQuotaMetric,
+		cpuEntitlementPercentageMetric:   cpuEntitlementPercentageMetric,
+		absoluteUsageSecondsDesc:         absoluteUsageSecondsDesc,
+		absoluteEntitlementSecondsDesc:   absoluteEntitlementSecondsDesc,
+		containerAgeNanosecondsMetric:    containerAgeNanosecondsMetric,
+		logRateBytesPerSecondMetric:      logRateBytesPerSecondMetric,
+		logRateLimitBytesPerSecondMetric: logRateLimitBytesPerSecondMetric,
+		memoryUtilizationRatioMetric:     memoryUtilizationRatioMetric,
+		diskUtilizationRatioMetric:       diskUtilizationRatioMetric,
+		cpuEntitlementRatioMetric:        cpuEntitlementRatioMetric,
+		logRateLimitRatioMetric:          logRateLimitRatioMetric,
+	}, nil
 }
 
 func (c ContainerMetricsCollector) Collect(ch chan<- prometheus.Metric) {
@@ -97,57 +237,81 @@ func (c ContainerMetricsCollector) Collect(ch chan<- prometheus.Metric) {
 	c.diskBytesMetric.Reset()
 	c.memoryBytesQuotaMetric.Reset()
 	c.diskBytesQuotaMetric.Reset()
+	c.cpuEntitlementPercentageMetric.Reset()
+	c.containerAgeNanosecondsMetric.Reset()
+	c.logRateBytesPerSecondMetric.Reset()
+	c.logRateLimitBytesPerSecondMetric.Reset()
+	c.memoryUtilizationRatioMetric.Reset()
+	c.diskUtilizationRatioMetric.Reset()
+	c.cpuEntitlementRatioMetric.Reset()
+	c.logRateLimitRatioMetric.Reset()
 
 	for _, containerMetric := range c.metricsStore.GetContainerMetrics() {
-		c.cpuPercentageMetric.WithLabelValues(
-			containerMetric.Origin,
-			containerMetric.Deployment,
-			containerMetric.Job,
-			containerMetric.Index,
-			containerMetric.IP,
-			containerMetric.ApplicationId,
-			strconv.Itoa(int(containerMetric.InstanceIndex)),
-		).Set(containerMetric.CpuPercentage)
+		application, _ := c.applications.Lookup(containerMetric.ApplicationId)
 
-		c.memoryBytesMetric.WithLabelValues(
+		labelValues := []string{
 			containerMetric.Origin,
 			containerMetric.Deployment,
 			containerMetric.Job,
 			containerMetric.Index,
 			containerMetric.IP,
 			containerMetric.ApplicationId,
+			application.Name,
+			application.SpaceName,
+			application.OrganizationName,
 			strconv.Itoa(int(containerMetric.InstanceIndex)),
-		).Set(float64(containerMetric.MemoryBytes))
+		}
+		labelValues = append(labelValues, c.tagPolicy.FixedLabelValues(containerMetric.Tags)...)
 
-		c.diskBytesMetric.WithLabelValues(
-			containerMetric.Origin,
-			containerMetric.Deployment,
-			containerMetric.Job,
-			containerMetric.Index,
-			containerMetric.IP,
-			containerMetric.ApplicationId,
-			strconv.Itoa(int(containerMetric.InstanceIndex)),
-		).Set(float64(containerMetric.DiskBytes))
+		c.cpuPercentageMetric.WithLabelValues(labelValues...).Set(containerMetric.CpuPercentage)
 
-		c.memoryBytesQuotaMetric.WithLabelValues(
-			containerMetric.Origin,
-			containerMetric.Deployment,
-			containerMetric.Job,
-			containerMetric.Index,
-			containerMetric.IP,
-			containerMetric.ApplicationId,
-			strconv.Itoa(int(containerMetric.InstanceIndex)),
-		).Set(float64(containerMetric.MemoryBytesQuota))
+		c.memoryBytesMetric.WithLabelValues(labelValues...).Set(float64(containerMetric.MemoryBytes))
 
-		c.diskBytesQuotaMetric.WithLabelValues(
-			containerMetric.Origin,
-			containerMetric.Deployment,
-			containerMetric.Job,
-			containerMetric.Index,
-			containerMetric.IP,
-			containerMetric.ApplicationId,
-			strconv.Itoa(int(containerMetric.InstanceIndex)),
-		).Set(float64(containerMetric.DiskBytesQuota))
+		c.diskBytesMetric.WithLabelValues(labelValues...).Set(float64(containerMetric.DiskBytes))
+
+		c.memoryBytesQuotaMetric.WithLabelValues(labelValues...).Set(float64(containerMetric.MemoryBytesQuota))
+
+		c.diskBytesQuotaMetric.WithLabelValues(labelValues...).Set(float64(containerMetric.DiskBytesQuota))
+
+		if containerMetric.CpuEntitlement != nil {
+			c.cpuEntitlementPercentageMetric.WithLabelValues(labelValues...).Set(*containerMetric.CpuEntitlement)
+		}
+
+		if containerMetric.AbsoluteUsage != nil {
+			ch <- prometheus.MustNewConstMetric(c.absoluteUsageSecondsDesc, prometheus.CounterValue, *containerMetric.AbsoluteUsage/1e9, labelValues...)
+		}
+
+		if containerMetric.AbsoluteEntitlement != nil {
+			ch <- prometheus.MustNewConstMetric(c.absoluteEntitlementSecondsDesc, prometheus.CounterValue, *containerMetric.AbsoluteEntitlement/1e9, labelValues...)
+		}
+
+		if containerMetric.ContainerAge != nil {
+			c.containerAgeNanosecondsMetric.WithLabelValues(labelValues...).Set(*containerMetric.ContainerAge)
+		}
+
+		if containerMetric.LogRate != nil {
+			c.logRateBytesPerSecondMetric.WithLabelValues(labelValues...).Set(*containerMetric.LogRate)
+		}
+
+		if containerMetric.LogRateLimit != nil {
+			c.logRateLimitBytesPerSecondMetric.WithLabelValues(labelValues...).Set(*containerMetric.LogRateLimit)
+		}
+
+		if containerMetric.MemoryBytesQuota > 0 {
+			c.memoryUtilizationRatioMetric.WithLabelValues(labelValues...).Set(float64(containerMetric.MemoryBytes) / float64(containerMetric.MemoryBytesQuota))
+		}
+
+		if containerMetric.DiskBytesQuota > 0 {
+			c.diskUtilizationRatioMetric.WithLabelValues(labelValues...).Set(float64(containerMetric.DiskBytes) / float64(containerMetric.DiskBytesQuota))
+		}
+
+		if containerMetric.AbsoluteUsage != nil && containerMetric.AbsoluteEntitlement != nil && *containerMetric.AbsoluteEntitlement > 0 {
+			c.cpuEntitlementRatioMetric.WithLabelValues(labelValues...).Set(*containerMetric.AbsoluteUsage / *containerMetric.AbsoluteEntitlement)
+		}
+
+		if containerMetric.LogRate != nil && containerMetric.LogRateLimit != nil && *containerMetric.LogRateLimit > 0 {
+			c.logRateLimitRatioMetric.WithLabelValues(labelValues...).Set(*containerMetric.LogRate / *containerMetric.LogRateLimit)
+		}
 	}
 
 	c.cpuPercentageMetric.Collect(ch)
@@ -155,6 +319,14 @@ func (c ContainerMetricsCollector) Collect(ch chan<- prometheus.Metric) {
 	c.diskBytesMetric.Collect(ch)
 	c.memoryBytesQuotaMetric.Collect(ch)
 	c.diskBytesQuotaMetric.Collect(ch)
+	c.cpuEntitlementPercentageMetric.Collect(ch)
+	c.containerAgeNanosecondsMetric.Collect(ch)
+	c.logRateBytesPerSecondMetric.Collect(ch)
+	c.logRateLimitBytesPerSecondMetric.Collect(ch)
+	c.memoryUtilizationRatioMetric.Collect(ch)
+	c.diskUtilizationRatioMetric.Collect(ch)
+	c.cpuEntitlementRatioMetric.Collect(ch)
+	c.logRateLimitRatioMetric.Collect(ch)
 }
 
 func (c ContainerMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
@@ -163,4 +335,14 @@ func (c ContainerMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
 	c.diskBytesMetric.Describe(ch)
 	c.memoryBytesQuotaMetric.Describe(ch)
 	c.diskBytesQuotaMetric.Describe(ch)
+	c.cpuEntitlementPercentageMetric.Describe(ch)
+	ch <- c.absoluteUsageSecondsDesc
+	ch <- c.absoluteEntitlementSecondsDesc
+	c.containerAgeNanosecondsMetric.Describe(ch)
+	c.logRateBytesPerSecondMetric.Describe(ch)
+	c.logRateLimitBytesPerSecondMetric.Describe(ch)
+	c.memoryUtilizationRatioMetric.Describe(ch)
+	c.diskUtilizationRatioMetric.Describe(ch)
+	c.cpuEntitlementRatioMetric.Describe(ch)
+	c.logRateLimitRatioMetric.Describe(ch)
 }
@@ -7,8 +7,10 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/bosh-prometheus/firehose_exporter/cloudcontroller"
 	"github.com/bosh-prometheus/firehose_exporter/filters"
 	"github.com/bosh-prometheus/firehose_exporter/metrics"
+	"github.com/bosh-prometheus/firehose_exporter/tagpolicy"
 	"github.com/cloudfoundry/sonde-go/events"
 	"github.com/gogo/protobuf/proto"
 	"github.com/prometheus/client_golang/prometheus"
@@ -27,6 +29,8 @@ var _ = Describe("ContainerMetricsCollector", func() {
 		deploymentFilter          *filters.DeploymentFilter
 		eventFilter               *filters.EventFilter
 		containerMetricsCollector *ContainerMetricsCollector
+		applications              *cloudcontroller.Cache
+		tagPolicy                 *tagpolicy.Policy
 
 		cpuPercentageMetric    *prometheus.GaugeVec
 		memoryBytesMetric      *prometheus.GaugeVec
@@ -41,6 +45,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 		boshIP         = "1.2.3.4"
 
 		containerMetric1ApplicationId    = "FakeApplicationId1"
+		containerMetric1ApplicationName  = "fake-app-1"
+		containerMetric1SpaceName        = "fake-space"
+		containerMetric1OrganizationName = "fake-org"
 		containerMetric1InstanceIndex    = int32(1)
 		containerMetric1CpuPercentage    = float64(0.5)
 		containerMetric1MemoryBytes      = uint64(1000)
@@ -49,6 +56,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 		containerMetric1DiskBytesQuota   = uint64(3000)
 
 		containerMetric2ApplicationId    = "FakeApplicationId2"
+		containerMetric2ApplicationName  = ""
+		containerMetric2SpaceName        = ""
+		containerMetric2OrganizationName = ""
 		containerMetric2InstanceIndex    = int32(2)
 		containerMetric2CpuPercentage    = float64(1.5)
 		containerMetric2MemoryBytes      = uint64(2000)
@@ -63,6 +73,15 @@ var _ = Describe("ContainerMetricsCollector", func() {
 		deploymentFilter = filters.NewDeploymentFilter([]string{})
 		eventFilter, _ = filters.NewEventFilter([]string{})
 		metricsStore = metrics.NewStore(metricsExpiration, metricsCleanupInterval, deploymentFilter, eventFilter)
+		tagPolicy = nil
+		applications = cloudcontroller.NewCache(nil, time.Minute)
+		applications.Set(map[string]cloudcontroller.Application{
+			containerMetric1ApplicationId: {
+				Name:             containerMetric1ApplicationName,
+				SpaceName:        containerMetric1SpaceName,
+				OrganizationName: containerMetric1OrganizationName,
+			},
+		})
 
 		cpuPercentageMetric = prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
@@ -72,7 +91,7 @@ var _ = Describe("ContainerMetricsCollector", func() {
 				Help:        "Cloud Foundry Firehose container metric: CPU used, on a scale of 0 to 100.",
 				ConstLabels: prometheus.Labels{"environment": environment},
 			},
-			[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "application_id", "instance_index"},
+			[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "application_id", "application_name", "space_name", "organization_name", "instance_index"},
 		)
 
 		cpuPercentageMetric.WithLabelValues(
@@ -82,6 +101,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 			boshIndex,
 			boshIP,
 			containerMetric1ApplicationId,
+			containerMetric1ApplicationName,
+			containerMetric1SpaceName,
+			containerMetric1OrganizationName,
 			strconv.Itoa(int(containerMetric1InstanceIndex)),
 		).Set(containerMetric1CpuPercentage)
 
@@ -92,6 +114,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 			boshIndex,
 			boshIP,
 			containerMetric2ApplicationId,
+			containerMetric2ApplicationName,
+			containerMetric2SpaceName,
+			containerMetric2OrganizationName,
 			strconv.Itoa(int(containerMetric2InstanceIndex)),
 		).Set(containerMetric2CpuPercentage)
 
@@ -103,7 +128,7 @@ var _ = Describe("ContainerMetricsCollector", func() {
 				Help:        "Cloud Foundry Firehose container metric: bytes of memory used.",
 				ConstLabels: prometheus.Labels{"environment": environment},
 			},
-			[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "application_id", "instance_index"},
+			[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "application_id", "application_name", "space_name", "organization_name", "instance_index"},
 		)
 
 		memoryBytesMetric.WithLabelValues(
@@ -113,6 +138,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 			boshIndex,
 			boshIP,
 			containerMetric1ApplicationId,
+			containerMetric1ApplicationName,
+			containerMetric1SpaceName,
+			containerMetric1OrganizationName,
 			strconv.Itoa(int(containerMetric1InstanceIndex)),
 		).Set(float64(containerMetric1MemoryBytes))
 
@@ -123,6 +151,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 			boshIndex,
 			boshIP,
 			containerMetric2ApplicationId,
+			containerMetric2ApplicationName,
+			containerMetric2SpaceName,
+			containerMetric2OrganizationName,
 			strconv.Itoa(int(containerMetric2InstanceIndex)),
 		).Set(float64(containerMetric2MemoryBytes))
 
@@ -134,7 +165,7 @@ var _ = Describe("ContainerMetricsCollector", func() {
 				Help:        "Cloud Foundry Firehose container metric: bytes of disk used.",
 				ConstLabels: prometheus.Labels{"environment": environment},
 			},
-			[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "application_id", "instance_index"},
+			[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "application_id", "application_name", "space_name", "organization_name", "instance_index"},
 		)
 
 		diskBytesMetric.WithLabelValues(
@@ -144,6 +175,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 			boshIndex,
 			boshIP,
 			containerMetric1ApplicationId,
+			containerMetric1ApplicationName,
+			containerMetric1SpaceName,
+			containerMetric1OrganizationName,
 			strconv.Itoa(int(containerMetric1InstanceIndex)),
 		).Set(float64(containerMetric1DiskBytes))
 
@@ -154,6 +188,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 			boshIndex,
 			boshIP,
 			containerMetric2ApplicationId,
+			containerMetric2ApplicationName,
+			containerMetric2SpaceName,
+			containerMetric2OrganizationName,
 			strconv.Itoa(int(containerMetric2InstanceIndex)),
 		).Set(float64(containerMetric2DiskBytes))
 
@@ -165,7 +202,7 @@ var _ = Describe("ContainerMetricsCollector", func() {
 				Help:        "Cloud Foundry Firehose container metric: maximum bytes of memory allocated to container.",
 				ConstLabels: prometheus.Labels{"environment": environment},
 			},
-			[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "application_id", "instance_index"},
+			[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "application_id", "application_name", "space_name", "organization_name", "instance_index"},
 		)
 
 		memoryBytesQuotaMetric.WithLabelValues(
@@ -175,6 +212,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 			boshIndex,
 			boshIP,
 			containerMetric1ApplicationId,
+			containerMetric1ApplicationName,
+			containerMetric1SpaceName,
+			containerMetric1OrganizationName,
 			strconv.Itoa(int(containerMetric1InstanceIndex)),
 		).Set(float64(containerMetric1MemoryBytesQuota))
 
@@ -185,6 +225,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 			boshIndex,
 			boshIP,
 			containerMetric2ApplicationId,
+			containerMetric2ApplicationName,
+			containerMetric2SpaceName,
+			containerMetric2OrganizationName,
 			strconv.Itoa(int(containerMetric2InstanceIndex)),
 		).Set(float64(containerMetric2MemoryBytesQuota))
 
@@ -196,7 +239,7 @@ var _ = Describe("ContainerMetricsCollector", func() {
 				Help:        "Cloud Foundry Firehose container metric: maximum bytes of disk allocated to container.",
 				ConstLabels: prometheus.Labels{"environment": environment},
 			},
-			[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "application_id", "instance_index"},
+			[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "application_id", "application_name", "space_name", "organization_name", "instance_index"},
 		)
 
 		diskBytesQuotaMetric.WithLabelValues(
@@ -206,6 +249,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 			boshIndex,
 			boshIP,
 			containerMetric1ApplicationId,
+			containerMetric1ApplicationName,
+			containerMetric1SpaceName,
+			containerMetric1OrganizationName,
 			strconv.Itoa(int(containerMetric1InstanceIndex)),
 		).Set(float64(containerMetric1DiskBytesQuota))
 
@@ -216,12 +262,27 @@ var _ = Describe("ContainerMetricsCollector", func() {
 			boshIndex,
 			boshIP,
 			containerMetric2ApplicationId,
+			containerMetric2ApplicationName,
+			containerMetric2SpaceName,
+			containerMetric2OrganizationName,
 			strconv.Itoa(int(containerMetric2InstanceIndex)),
 		).Set(float64(containerMetric2DiskBytesQuota))
 	})
 
 	JustBeforeEach(func() {
-		containerMetricsCollector = NewContainerMetricsCollector(namespace, environment, metricsStore)
+		var err error
+		containerMetricsCollector, err = NewContainerMetricsCollector(namespace, environment, metricsStore, applications, tagPolicy)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	Context("when a tag label collides with a container metrics label", func() {
+		It("returns an error", func() {
+			tagPolicy, err := tagpolicy.NewPolicy([]string{"origin"}, nil, nil, 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = NewContainerMetricsCollector(namespace, environment, metricsStore, applications, tagPolicy)
+			Expect(err).To(HaveOccurred())
+		})
 	})
 
 	Describe("Describe", func() {
@@ -245,6 +306,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 				boshIndex,
 				boshIP,
 				containerMetric1ApplicationId,
+				containerMetric1ApplicationName,
+				containerMetric1SpaceName,
+				containerMetric1OrganizationName,
 				strconv.Itoa(int(containerMetric1InstanceIndex)),
 			).Desc())))
 		})
@@ -257,6 +321,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 				boshIndex,
 				boshIP,
 				containerMetric1ApplicationId,
+				containerMetric1ApplicationName,
+				containerMetric1SpaceName,
+				containerMetric1OrganizationName,
 				strconv.Itoa(int(containerMetric1InstanceIndex)),
 			).Desc())))
 		})
@@ -269,6 +336,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 				boshIndex,
 				boshIP,
 				containerMetric1ApplicationId,
+				containerMetric1ApplicationName,
+				containerMetric1SpaceName,
+				containerMetric1OrganizationName,
 				strconv.Itoa(int(containerMetric1InstanceIndex)),
 			).Desc())))
 		})
@@ -281,6 +351,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 				boshIndex,
 				boshIP,
 				containerMetric1ApplicationId,
+				containerMetric1ApplicationName,
+				containerMetric1SpaceName,
+				containerMetric1OrganizationName,
 				strconv.Itoa(int(containerMetric1InstanceIndex)),
 			).Desc())))
 		})
@@ -293,6 +366,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 				boshIndex,
 				boshIP,
 				containerMetric1ApplicationId,
+				containerMetric1ApplicationName,
+				containerMetric1SpaceName,
+				containerMetric1OrganizationName,
 				strconv.Itoa(int(containerMetric1InstanceIndex)),
 			).Desc())))
 		})
@@ -322,6 +398,10 @@ var _ = Describe("ContainerMetricsCollector", func() {
 						MemoryBytesQuota: proto.Uint64(containerMetric1MemoryBytesQuota),
 						DiskBytesQuota:   proto.Uint64(containerMetric1DiskBytesQuota),
 					},
+					Tags: map[string]string{
+						"source_id": containerMetric1ApplicationId,
+						"other":     "fake-other",
+					},
 				},
 			)
 
@@ -361,6 +441,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 				boshIndex,
 				boshIP,
 				containerMetric1ApplicationId,
+				containerMetric1ApplicationName,
+				containerMetric1SpaceName,
+				containerMetric1OrganizationName,
 				strconv.Itoa(int(containerMetric1InstanceIndex)),
 			))))
 		})
@@ -373,6 +456,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 				boshIndex,
 				boshIP,
 				containerMetric1ApplicationId,
+				containerMetric1ApplicationName,
+				containerMetric1SpaceName,
+				containerMetric1OrganizationName,
 				strconv.Itoa(int(containerMetric1InstanceIndex)),
 			))))
 		})
@@ -385,6 +471,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 				boshIndex,
 				boshIP,
 				containerMetric1ApplicationId,
+				containerMetric1ApplicationName,
+				containerMetric1SpaceName,
+				containerMetric1OrganizationName,
 				strconv.Itoa(int(containerMetric1InstanceIndex)),
 			))))
 		})
@@ -397,6 +486,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 				boshIndex,
 				boshIP,
 				containerMetric1ApplicationId,
+				containerMetric1ApplicationName,
+				containerMetric1SpaceName,
+				containerMetric1OrganizationName,
 				strconv.Itoa(int(containerMetric1InstanceIndex)),
 			))))
 		})
@@ -409,6 +501,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 				boshIndex,
 				boshIP,
 				containerMetric1ApplicationId,
+				containerMetric1ApplicationName,
+				containerMetric1SpaceName,
+				containerMetric1OrganizationName,
 				strconv.Itoa(int(containerMetric1InstanceIndex)),
 			))))
 		})
@@ -421,6 +516,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 				boshIndex,
 				boshIP,
 				containerMetric2ApplicationId,
+				containerMetric2ApplicationName,
+				containerMetric2SpaceName,
+				containerMetric2OrganizationName,
 				strconv.Itoa(int(containerMetric2InstanceIndex)),
 			))))
 		})
@@ -433,6 +531,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 				boshIndex,
 				boshIP,
 				containerMetric2ApplicationId,
+				containerMetric2ApplicationName,
+				containerMetric2SpaceName,
+				containerMetric2OrganizationName,
 				strconv.Itoa(int(containerMetric2InstanceIndex)),
 			))))
 		})
@@ -445,6 +546,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 				boshIndex,
 				boshIP,
 				containerMetric2ApplicationId,
+				containerMetric2ApplicationName,
+				containerMetric2SpaceName,
+				containerMetric2OrganizationName,
 				strconv.Itoa(int(containerMetric2InstanceIndex)),
 			))))
 		})
@@ -457,6 +561,9 @@ var _ = Describe("ContainerMetricsCollector", func() {
 				boshIndex,
 				boshIP,
 				containerMetric2ApplicationId,
+				containerMetric2ApplicationName,
+				containerMetric2SpaceName,
+				containerMetric2OrganizationName,
 				strconv.Itoa(int(containerMetric2InstanceIndex)),
 			))))
 		})
@@ -469,10 +576,187 @@ var _ = Describe("ContainerMetricsCollector", func() {
 				boshIndex,
 				boshIP,
 				containerMetric2ApplicationId,
+				containerMetric2ApplicationName,
+				containerMetric2SpaceName,
+				containerMetric2OrganizationName,
 				strconv.Itoa(int(containerMetric2InstanceIndex)),
 			))))
 		})
 
+		It("returns a container_metric_memory_utilization_ratio metric for FakeApplicationId1", func() {
+			memoryUtilizationRatioMetric := prometheus.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Namespace:   namespace,
+					Subsystem:   "container_metric",
+					Name:        "memory_utilization_ratio",
+					Help:        "Cloud Foundry Firehose container metric: ratio of the memory quota used.",
+					ConstLabels: prometheus.Labels{"environment": environment},
+				},
+				[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "application_id", "application_name", "space_name", "organization_name", "instance_index"},
+			)
+			memoryUtilizationRatioMetric.WithLabelValues(
+				origin,
+				boshDeployment,
+				boshJob,
+				boshIndex,
+				boshIP,
+				containerMetric1ApplicationId,
+				containerMetric1ApplicationName,
+				containerMetric1SpaceName,
+				containerMetric1OrganizationName,
+				strconv.Itoa(int(containerMetric1InstanceIndex)),
+			).Set(float64(containerMetric1MemoryBytes) / float64(containerMetric1MemoryBytesQuota))
+
+			Eventually(containerMetricsChan).Should(Receive(PrometheusMetric(memoryUtilizationRatioMetric.WithLabelValues(
+				origin,
+				boshDeployment,
+				boshJob,
+				boshIndex,
+				boshIP,
+				containerMetric1ApplicationId,
+				containerMetric1ApplicationName,
+				containerMetric1SpaceName,
+				containerMetric1OrganizationName,
+				strconv.Itoa(int(containerMetric1InstanceIndex)),
+			))))
+		})
+
+		Context("when v2 container metrics are received", func() {
+			BeforeEach(func() {
+				for name, value := range map[string]float64{metrics.ContainerMetricLogRate: 250, metrics.ContainerMetricLogRateLimit: 1000, metrics.ContainerMetricAbsoluteUsage: 2.5e9} {
+					metricsStore.AddMetric(
+						&events.Envelope{
+							Origin:     proto.String(origin),
+							EventType:  events.Envelope_ValueMetric.Enum(),
+							Timestamp:  proto.Int64(time.Now().Unix() * 1000),
+							Deployment: proto.String(boshDeployment),
+							Job:        proto.String(boshJob),
+							Index:      proto.String(boshIndex),
+							Ip:         proto.String(boshIP),
+							ValueMetric: &events.ValueMetric{
+								Name:  proto.String(name),
+								Value: proto.Float64(value),
+								Unit:  proto.String("B/s"),
+							},
+							Tags: map[string]string{
+								"source_id":   containerMetric1ApplicationId,
+								"instance_id": strconv.Itoa(int(containerMetric1InstanceIndex)),
+							},
+						},
+					)
+				}
+			})
+
+			It("returns a container_metric_log_rate_limit_ratio metric for FakeApplicationId1", func() {
+				logRateLimitRatioMetric := prometheus.NewGaugeVec(
+					prometheus.GaugeOpts{
+						Namespace:   namespace,
+						Subsystem:   "container_metric",
+						Name:        "log_rate_limit_ratio",
+						Help:        "Cloud Foundry Firehose container metric: ratio of the log rate limit used.",
+						ConstLabels: prometheus.Labels{"environment": environment},
+					},
+					[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "application_id", "application_name", "space_name", "organization_name", "instance_index"},
+				)
+				logRateLimitRatioMetric.WithLabelValues(
+					origin,
+					boshDeployment,
+					boshJob,
+					boshIndex,
+					boshIP,
+					containerMetric1ApplicationId,
+					containerMetric1ApplicationName,
+					containerMetric1SpaceName,
+					containerMetric1OrganizationName,
+					strconv.Itoa(int(containerMetric1InstanceIndex)),
+				).Set(0.25)
+
+				Eventually(containerMetricsChan).Should(Receive(PrometheusMetric(logRateLimitRatioMetric.WithLabelValues(
+					origin,
+					boshDeployment,
+					boshJob,
+					boshIndex,
+					boshIP,
+					containerMetric1ApplicationId,
+					containerMetric1ApplicationName,
+					containerMetric1SpaceName,
+					containerMetric1OrganizationName,
+					strconv.Itoa(int(containerMetric1InstanceIndex)),
+				))))
+			})
+
+			It("returns a container_metric_absolute_usage_seconds_total counter for FakeApplicationId1", func() {
+				Eventually(containerMetricsChan).Should(Receive(PrometheusMetric(prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						prometheus.BuildFQName(namespace, "container_metric", "absolute_usage_seconds_total"),
+						"Cloud Foundry Firehose container metric: total CPU time used by the container, in seconds.",
+						[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "application_id", "application_name", "space_name", "organization_name", "instance_index"},
+						prometheus.Labels{"environment": environment},
+					),
+					prometheus.CounterValue,
+					2.5,
+					origin,
+					boshDeployment,
+					boshJob,
+					boshIndex,
+					boshIP,
+					containerMetric1ApplicationId,
+					containerMetric1ApplicationName,
+					containerMetric1SpaceName,
+					containerMetric1OrganizationName,
+					strconv.Itoa(int(containerMetric1InstanceIndex)),
+				))))
+			})
+		})
+
+		Context("when tags are allowed", func() {
+			BeforeEach(func() {
+				var err error
+				tagPolicy, err = tagpolicy.NewPolicy([]string{"source_id"}, nil, nil, 0)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns a container_metric_cpu_percentage metric with the allowed tags", func() {
+				taggedCpuPercentageMetric := prometheus.NewGaugeVec(
+					prometheus.GaugeOpts{
+						Namespace:   namespace,
+						Subsystem:   "container_metric",
+						Name:        "cpu_percentage",
+						Help:        "Cloud Foundry Firehose container metric: CPU used, on a scale of 0 to 100.",
+						ConstLabels: prometheus.Labels{"environment": environment},
+					},
+					[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "application_id", "application_name", "space_name", "organization_name", "instance_index", "source_id"},
+				)
+				taggedCpuPercentageMetric.WithLabelValues(
+					origin,
+					boshDeployment,
+					boshJob,
+					boshIndex,
+					boshIP,
+					containerMetric1ApplicationId,
+					containerMetric1ApplicationName,
+					containerMetric1SpaceName,
+					containerMetric1OrganizationName,
+					strconv.Itoa(int(containerMetric1InstanceIndex)),
+					containerMetric1ApplicationId,
+				).Set(containerMetric1CpuPercentage)
+
+				Eventually(containerMetricsChan).Should(Receive(PrometheusMetric(taggedCpuPercentageMetric.WithLabelValues(
+					origin,
+					boshDeployment,
+					boshJob,
+					boshIndex,
+					boshIP,
+					containerMetric1ApplicationId,
+					containerMetric1ApplicationName,
+					containerMetric1SpaceName,
+					containerMetric1OrganizationName,
+					strconv.Itoa(int(containerMetric1InstanceIndex)),
+					containerMetric1ApplicationId,
+				))))
+			})
+		})
+
 		Context("when there is no container metrics", func() {
 			BeforeEach(func() {
 				metricsStore.FlushContainerMetrics()
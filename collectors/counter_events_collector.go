@@ -3,17 +3,33 @@ package collectors
 import (
 	"fmt"
 
+	"github.com/cloudfoundry/sonde-go/events"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/log"
 
+	"github.com/bosh-prometheus/firehose_exporter/boshdirector"
+	"github.com/bosh-prometheus/firehose_exporter/mappings"
+	"github.com/bosh-prometheus/firehose_exporter/metadata"
 	"github.com/bosh-prometheus/firehose_exporter/metrics"
+	"github.com/bosh-prometheus/firehose_exporter/naming"
+	"github.com/bosh-prometheus/firehose_exporter/tagpolicy"
 	"github.com/bosh-prometheus/firehose_exporter/utils"
 )
 
+// counterEventsLabelNames are the built-in labels of the counter events.
+var counterEventsLabelNames = []string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip"}
+
 type CounterEventsCollector struct {
 	namespace                  string
 	environment                string
 	metricsStore               *metrics.Store
+	monotonicCounters          bool
+	mapper                     *mappings.Mapper
+	tagPolicy                  *tagpolicy.Policy
+	namer                      *naming.Namer
+	boshInstances              *boshdirector.Cache
+	catalog                    *metadata.Catalog
+	describeCatalog            bool
 	counterEventsCollectorDesc *prometheus.Desc
 }
 
@@ -21,6 +37,13 @@ func NewCounterEventsCollector(
 	namespace string,
 	environment string,
 	metricsStore *metrics.Store,
+	monotonicCounters bool,
+	mapper *mappings.Mapper,
+	tagPolicy *tagpolicy.Policy,
+	namer *naming.Namer,
+	boshInstances *boshdirector.Cache,
+	catalog *metadata.Catalog,
+	describeCatalog bool,
 ) *CounterEventsCollector {
 	counterEventsCollectorDesc := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, counter_events_subsystem, "collector"),
@@ -33,61 +56,139 @@ func NewCounterEventsCollector(
 		namespace:                  namespace,
 		environment:                environment,
 		metricsStore:               metricsStore,
+		monotonicCounters:          monotonicCounters,
+		mapper:                     mapper,
+		tagPolicy:                  tagPolicy,
+		namer:                      namer,
+		boshInstances:              boshInstances,
+		catalog:                    catalog,
+		describeCatalog:            describeCatalog,
 		counterEventsCollectorDesc: counterEventsCollectorDesc,
 	}
 }
 
+// counterEventSeries is a counter event series, before its labels are
+// aligned with the other series of its family.
+type counterEventSeries struct {
+	counterEvent *metrics.CounterEvent
+	metricName   string
+	labelNames   []string
+	labelValues  []string
+}
+
 func (c CounterEventsCollector) Collect(ch chan<- prometheus.Metric) {
+	var tagLabelCollisions int64
+	var series []counterEventSeries
+	labelSets := newLabelSets(append(append([]string{}, counterEventsLabelNames...), boshInstanceLabelNames...))
+	helpEvents := map[string]*metrics.CounterEvent{}
+
 	for _, counterEvent := range c.metricsStore.GetCounterEvents() {
-		metricName := utils.NormalizeName(counterEvent.Origin) + "_" + utils.NormalizeName(counterEvent.Name) + "_total"
+		if c.mapper.Match(mappings.EventTypeCounterEvent, counterEvent.Origin, counterEvent.Name) != nil {
+			continue
+		}
+
+		metricName := c.namer.Name(c.namespace, counter_events_subsystem, mappings.EventTypeCounterEvent, counterEvent.Origin, counterEvent.Name)
 
-		constLabels := []string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip"}
+		constLabels := append([]string{}, counterEventsLabelNames...)
 		labelValues := []string{counterEvent.Origin, counterEvent.Deployment, counterEvent.Job, counterEvent.Index, counterEvent.IP}
 
-		for k, v := range counterEvent.Tags {
-			if (len(k) > 0) && (len(v) > 0) {
-				constLabels = append(constLabels, k)
-				labelValues = append(labelValues, v)
-			}
+		if c.boshInstances != nil {
+			constLabels = append(constLabels, boshInstanceLabelNames...)
+			labelValues = append(labelValues, boshInstanceLabelValues(c.boshInstances, counterEvent.Deployment, counterEvent.Index)...)
+		}
+
+		tagLabelNames, tagLabelValues, collided := c.tagPolicy.Labels(counterEvent.Tags, builtinLabels(constLabels))
+		if collided {
+			tagLabelCollisions++
 		}
+		constLabels = append(constLabels, tagLabelNames...)
+		labelValues = append(labelValues, tagLabelValues...)
+
+		labelSets.add(metricName, constLabels)
+		if _, ok := helpEvents[metricName]; !ok {
+			helpEvents[metricName] = counterEvent
+		}
+		series = append(series, counterEventSeries{
+			counterEvent: counterEvent,
+			metricName:   metricName,
+			labelNames:   constLabels,
+			labelValues:  labelValues,
+		})
+	}
+
+	for _, s := range series {
+		c.collectCounterEvent(ch, s, helpEvents[s.metricName], labelSets.labelNames(s.metricName), labelSets.labelValues(s.metricName, s.labelNames, s.labelValues))
+	}
+
+	if tagLabelCollisions > 0 {
+		c.metricsStore.AddTagLabelCollisions(events.Envelope_CounterEvent, tagLabelCollisions)
+	}
+}
 
-		tcm, err := prometheus.NewConstMetric(
-			prometheus.NewDesc(
-				prometheus.BuildFQName(c.namespace, counter_events_subsystem, metricName),
-				fmt.Sprintf("Cloud Foundry Firehose '%s' total counter event from '%s'.", utils.NormalizeNameDesc(counterEvent.Name), utils.NormalizeOriginDesc(counterEvent.Origin)),
-				constLabels,
-				prometheus.Labels{"environment": c.environment},
-			),
-			prometheus.CounterValue,
-			float64(counterEvent.Total),
-			labelValues...,
+// counterEventValue is one of the series exposed for a counter event.
+type counterEventValue struct {
+	suffix    string
+	valueType prometheus.ValueType
+	value     float64
+	help      string
+}
+
+func (c CounterEventsCollector) collectCounterEvent(ch chan<- prometheus.Metric, s counterEventSeries, helpEvent *metrics.CounterEvent, constLabels []string, labelValues []string) {
+	counterEvent := s.counterEvent
+
+	total := counterEvent.Total
+	if c.monotonicCounters {
+		total = counterEvent.MonotonicTotal
+	}
+
+	series := []counterEventValue{
+		{"_total", prometheus.CounterValue, float64(total), "Cloud Foundry Firehose '%s' total counter event from '%s'."},
+	}
+	if c.monotonicCounters {
+		series = append(series,
+			counterEventValue{"_raw", prometheus.GaugeValue, float64(counterEvent.Total), "Cloud Foundry Firehose '%s' total counter event from '%s' as reported by the emitter."},
+			counterEventValue{"_resets_total", prometheus.CounterValue, float64(counterEvent.Resets), "Number of resets detected on Cloud Foundry Firehose '%s' counter event from '%s'."},
+		)
+	}
+	series = append(series, counterEventValue{"_delta", prometheus.GaugeValue, float64(counterEvent.Delta), "Cloud Foundry Firehose '%s' delta counter event from '%s'."})
+
+	for _, serie := range series {
+		help := serie.help
+		metricType := metadata.TypeGauge
+		if serie.valueType == prometheus.CounterValue {
+			metricType = metadata.TypeCounter
+		}
+
+		desc, err := c.catalog.Desc(
+			metadata.Observation{
+				Source:     counter_events_subsystem,
+				Name:       s.metricName + serie.suffix,
+				Type:       metricType,
+				Origin:     counterEvent.Origin,
+				LabelNames: constLabels,
+			},
+			prometheus.Labels{"environment": c.environment},
+			func() string {
+				return fmt.Sprintf(help, utils.NormalizeNameDesc(helpEvent.Name), utils.NormalizeOriginDesc(helpEvent.Origin))
+			},
 		)
 		if err != nil {
 			log.Errorf("Counter Event `%s` from `%s` discarded: %s", counterEvent.Name, counterEvent.Origin, err)
-			continue
+			return
 		}
-		ch <- tcm
-
-		metricName = utils.NormalizeName(counterEvent.Origin) + "_" + utils.NormalizeName(counterEvent.Name) + "_delta"
-		dcm, err := prometheus.NewConstMetric(
-			prometheus.NewDesc(
-				prometheus.BuildFQName(c.namespace, counter_events_subsystem, metricName),
-				fmt.Sprintf("Cloud Foundry Firehose '%s' delta counter event from '%s'.", utils.NormalizeNameDesc(counterEvent.Name), utils.NormalizeOriginDesc(counterEvent.Origin)),
-				constLabels,
-				prometheus.Labels{"environment": c.environment},
-			),
-			prometheus.GaugeValue,
-			float64(counterEvent.Delta),
-			labelValues...,
-		)
+
+		cm, err := prometheus.NewConstMetric(desc, serie.valueType, serie.value, labelValues...)
 		if err != nil {
 			log.Errorf("Counter Event `%s` from `%s` discarded: %s", counterEvent.Name, counterEvent.Origin, err)
-			continue
+			return
 		}
-		ch <- dcm
+		ch <- cm
 	}
 }
 
 func (c CounterEventsCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.counterEventsCollectorDesc
+	if c.describeCatalog {
+		c.catalog.Descs(counter_events_subsystem, ch)
+	}
 }
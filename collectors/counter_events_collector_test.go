@@ -2,13 +2,19 @@ package collectors_test
 
 import (
 	"fmt"
+	"regexp"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/bosh-prometheus/firehose_exporter/boshdirector"
 	"github.com/bosh-prometheus/firehose_exporter/filters"
+	"github.com/bosh-prometheus/firehose_exporter/mappings"
+	"github.com/bosh-prometheus/firehose_exporter/metadata"
 	"github.com/bosh-prometheus/firehose_exporter/metrics"
+	"github.com/bosh-prometheus/firehose_exporter/naming"
+	"github.com/bosh-prometheus/firehose_exporter/tagpolicy"
 	"github.com/cloudfoundry/sonde-go/events"
 	"github.com/gogo/protobuf/proto"
 	"github.com/prometheus/client_golang/prometheus"
@@ -26,6 +32,13 @@ var _ = Describe("CounterEventsCollector", func() {
 		metricsCleanupInterval time.Duration
 		deploymentFilter       *filters.DeploymentFilter
 		eventFilter            *filters.EventFilter
+		monotonicCounters      bool
+		mapper                 *mappings.Mapper
+		tagPolicy              *tagpolicy.Policy
+		namer                  *naming.Namer
+		boshInstances          *boshdirector.Cache
+		catalog                *metadata.Catalog
+		describeCatalog        bool
 		counterEventsCollector *CounterEventsCollector
 
 		counterEventsCollectorDesc *prometheus.Desc
@@ -37,6 +50,13 @@ var _ = Describe("CounterEventsCollector", func() {
 		deploymentFilter = filters.NewDeploymentFilter([]string{})
 		eventFilter, _ = filters.NewEventFilter([]string{})
 		metricsStore = metrics.NewStore(metricsExpiration, metricsCleanupInterval, deploymentFilter, eventFilter)
+		monotonicCounters = false
+		mapper = nil
+		tagPolicy = nil
+		namer = nil
+		boshInstances = nil
+		catalog = nil
+		describeCatalog = false
 
 		counterEventsCollectorDesc = prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "counter_event", "collector"),
@@ -47,7 +67,7 @@ var _ = Describe("CounterEventsCollector", func() {
 	})
 
 	JustBeforeEach(func() {
-		counterEventsCollector = NewCounterEventsCollector(namespace, environment, metricsStore)
+		counterEventsCollector = NewCounterEventsCollector(namespace, environment, metricsStore, monotonicCounters, mapper, tagPolicy, namer, boshInstances, catalog, describeCatalog)
 	})
 
 	Describe("Describe", func() {
@@ -66,6 +86,33 @@ var _ = Describe("CounterEventsCollector", func() {
 		It("returns a counter_event_collector metric description", func() {
 			Eventually(descriptions).Should(Receive(Equal(counterEventsCollectorDesc)))
 		})
+
+		Context("when the catalog descriptors are enabled", func() {
+			var counterEventDesc *prometheus.Desc
+
+			BeforeEach(func() {
+				catalog = metadata.NewCatalog()
+				describeCatalog = true
+
+				var err error
+				counterEventDesc, err = catalog.Desc(
+					metadata.Observation{
+						Source:     "counter_event",
+						Name:       prometheus.BuildFQName(namespace, "counter_event", "fake_origin_fake_counter_event_total"),
+						Type:       metadata.TypeCounter,
+						LabelNames: []string{"origin"},
+					},
+					prometheus.Labels{"environment": environment},
+					func() string { return "Fake help." },
+				)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns the descriptions of the counter events seen so far", func() {
+				Eventually(descriptions).Should(Receive(Equal(counterEventsCollectorDesc)))
+				Eventually(descriptions).Should(Receive(Equal(counterEventDesc)))
+			})
+		})
 	})
 
 	Describe("Collect", func() {
@@ -100,11 +147,11 @@ var _ = Describe("CounterEventsCollector", func() {
 			deltaCounterEvent2 prometheus.Metric
 
 			tag1Name           = "tag1"
-			tag1NameNormalized = "tag1"
+			tag1NameNormalized = "tag_1"
 			tag1Value          = "fakeTag1"
 
 			tag2Name           = "tag2"
-			tag2NameNormalized = "tag2"
+			tag2NameNormalized = "tag_2"
 			tag2Value          = "fakeTag2"
 		)
 
@@ -240,6 +287,190 @@ var _ = Describe("CounterEventsCollector", func() {
 			Eventually(counterEventsChan).Should(Receive(PrometheusMetric(deltaCounterEvent2)))
 		})
 
+		Context("when a tag collides with a built-in label", func() {
+			BeforeEach(func() {
+				metricsStore.AddMetric(
+					&events.Envelope{
+						Origin:     proto.String(counterEvent1Origin),
+						EventType:  events.Envelope_CounterEvent.Enum(),
+						Timestamp:  proto.Int64(time.Now().Unix() * 1000),
+						Deployment: proto.String(boshDeployment),
+						Job:        proto.String(boshJob),
+						Index:      proto.String(boshIndex),
+						Ip:         proto.String(boshIP),
+						CounterEvent: &events.CounterEvent{
+							Name:  proto.String(counterEvent1Name),
+							Delta: proto.Uint64(counterEvent1Delta),
+							Total: proto.Uint64(counterEvent1Total),
+						},
+						Tags: map[string]string{
+							"origin": "fake-tag-origin",
+						},
+					},
+				)
+			})
+
+			It("counts the collision", func() {
+				Eventually(func() int64 {
+					select {
+					case <-counterEventsChan:
+					default:
+					}
+					return metricsStore.GetInternalMetrics().TotalCounterEventsTagLabelCollisions
+				}).Should(Equal(int64(1)))
+			})
+		})
+
+		Context("when monotonic counters are enabled", func() {
+			var (
+				counterEvent1ResetTotal = uint64(3)
+
+				monotonicTotalCounterEvent1 prometheus.Metric
+				rawCounterEvent1            prometheus.Metric
+				resetsCounterEvent1         prometheus.Metric
+			)
+
+			BeforeEach(func() {
+				monotonicCounters = true
+
+				metricsStore.AddMetric(
+					&events.Envelope{
+						Origin:     proto.String(counterEvent1Origin),
+						EventType:  events.Envelope_CounterEvent.Enum(),
+						Timestamp:  proto.Int64(time.Now().Unix() * 1000),
+						Deployment: proto.String(boshDeployment),
+						Job:        proto.String(boshJob),
+						Index:      proto.String(boshIndex),
+						Ip:         proto.String(boshIP),
+						CounterEvent: &events.CounterEvent{
+							Name:  proto.String(counterEvent1Name),
+							Delta: proto.Uint64(counterEvent1Delta),
+							Total: proto.Uint64(counterEvent1ResetTotal),
+						},
+						Tags: map[string]string{
+							tag1Name: tag1Value,
+						},
+					},
+				)
+
+				monotonicTotalCounterEvent1 = prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						prometheus.BuildFQName(namespace, "counter_event", counterEvent1OriginNameNormalized+"_"+counterEvent1NameNormalized+"_total"),
+						fmt.Sprintf("Cloud Foundry Firehose '%s' total counter event from '%s'.", counterEvent1DescNormalized, counterEvent1OriginDescNormalized),
+						[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", tag1NameNormalized},
+						prometheus.Labels{"environment": environment},
+					),
+					prometheus.CounterValue,
+					float64(2*counterEvent1Delta),
+					counterEvent1Origin,
+					boshDeployment,
+					boshJob,
+					boshIndex,
+					boshIP,
+					tag1Value,
+				)
+
+				rawCounterEvent1 = prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						prometheus.BuildFQName(namespace, "counter_event", counterEvent1OriginNameNormalized+"_"+counterEvent1NameNormalized+"_raw"),
+						fmt.Sprintf("Cloud Foundry Firehose '%s' total counter event from '%s' as reported by the emitter.", counterEvent1DescNormalized, counterEvent1OriginDescNormalized),
+						[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", tag1NameNormalized},
+						prometheus.Labels{"environment": environment},
+					),
+					prometheus.GaugeValue,
+					float64(counterEvent1ResetTotal),
+					counterEvent1Origin,
+					boshDeployment,
+					boshJob,
+					boshIndex,
+					boshIP,
+					tag1Value,
+				)
+
+				resetsCounterEvent1 = prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						prometheus.BuildFQName(namespace, "counter_event", counterEvent1OriginNameNormalized+"_"+counterEvent1NameNormalized+"_resets_total"),
+						fmt.Sprintf("Number of resets detected on Cloud Foundry Firehose '%s' counter event from '%s'.", counterEvent1DescNormalized, counterEvent1OriginDescNormalized),
+						[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", tag1NameNormalized},
+						prometheus.Labels{"environment": environment},
+					),
+					prometheus.CounterValue,
+					float64(1),
+					counterEvent1Origin,
+					boshDeployment,
+					boshJob,
+					boshIndex,
+					boshIP,
+					tag1Value,
+				)
+			})
+
+			It("returns a monotonic counter_event_fake_origin_fake_counter_event_1_total metric", func() {
+				Eventually(counterEventsChan).Should(Receive(PrometheusMetric(monotonicTotalCounterEvent1)))
+			})
+
+			It("returns a counter_event_fake_origin_fake_counter_event_1_raw metric", func() {
+				Eventually(counterEventsChan).Should(Receive(PrometheusMetric(rawCounterEvent1)))
+			})
+
+			It("returns a counter_event_fake_origin_fake_counter_event_1_resets_total metric", func() {
+				Eventually(counterEventsChan).Should(Receive(PrometheusMetric(resetsCounterEvent1)))
+			})
+		})
+
+		Context("when a naming template matches", func() {
+			BeforeEach(func() {
+				var err error
+				namer, err = naming.NewNamer(namespace, []naming.Template{
+					{EventType: "CounterEvent", Origin: regexp.QuoteMeta(counterEvent1Origin), Template: "{namespace}_fake_{name}"},
+				})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns the counter event with the templated name", func() {
+				templatedTotalCounterEvent1 := prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						namespace+"_fake_"+counterEvent1NameNormalized+"_total",
+						fmt.Sprintf("Cloud Foundry Firehose '%s' total counter event from '%s'.", counterEvent1DescNormalized, counterEvent1OriginDescNormalized),
+						[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", tag1NameNormalized},
+						prometheus.Labels{"environment": environment},
+					),
+					prometheus.CounterValue,
+					float64(counterEvent1Total),
+					counterEvent1Origin,
+					boshDeployment,
+					boshJob,
+					boshIndex,
+					boshIP,
+					tag1Value,
+				)
+
+				Eventually(counterEventsChan).Should(Receive(PrometheusMetric(templatedTotalCounterEvent1)))
+			})
+		})
+
+		Context("when a catalog is used", func() {
+			BeforeEach(func() {
+				catalog = metadata.NewCatalog()
+			})
+
+			It("records the counter event families with their type", func() {
+				for i := 0; i < 4; i++ {
+					Eventually(counterEventsChan).Should(Receive())
+				}
+
+				families := catalog.Families()
+				Expect(families).To(HaveLen(4))
+				Expect(families[0].Name).To(Equal(prometheus.BuildFQName(namespace, "counter_event", counterEvent1OriginNameNormalized+"_"+counterEvent1NameNormalized+"_delta")))
+				Expect(families[0].Type).To(Equal(metadata.TypeGauge))
+				Expect(families[1].Name).To(Equal(prometheus.BuildFQName(namespace, "counter_event", counterEvent1OriginNameNormalized+"_"+counterEvent1NameNormalized+"_total")))
+				Expect(families[1].Type).To(Equal(metadata.TypeCounter))
+				Expect(families[1].Source).To(Equal("counter_event"))
+				Expect(families[1].Help).To(Equal(fmt.Sprintf("Cloud Foundry Firehose '%s' total counter event from '%s'.", counterEvent1DescNormalized, counterEvent1OriginDescNormalized)))
+				Expect(families[1].Origins).To(Equal([]string{counterEvent1Origin}))
+			})
+		})
+
 		Context("when there is no counter metrics", func() {
 			BeforeEach(func() {
 				metricsStore.FlushCounterEvents()
@@ -0,0 +1,77 @@
+package collectors
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// GatherErrorsCollector wraps the gatherer used to expose the metrics and
+// reports the errors it returns (inconsistent or duplicated series, relabeling
+// conflicts, ...), which would otherwise only be logged while the affected
+// series silently disappear from the scrape. Errors are counted after each
+// gather, so they show up from the following scrape on.
+type GatherErrorsCollector struct {
+	gatherer                prometheus.Gatherer
+	totalGatherErrorsMetric prometheus.Counter
+	lastGatherErrorsMetric  prometheus.Gauge
+}
+
+func NewGatherErrorsCollector(
+	namespace string,
+	environment string,
+	gatherer prometheus.Gatherer,
+) *GatherErrorsCollector {
+	totalGatherErrorsMetric := prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "gather_errors_total",
+			Help:        "Total number of errors encountered while gathering the exposed metrics.",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+	)
+
+	lastGatherErrorsMetric := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "last_gather_errors",
+			Help:        "Number of errors encountered at the last gathering of the exposed metrics.",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+	)
+
+	return &GatherErrorsCollector{
+		gatherer:                gatherer,
+		totalGatherErrorsMetric: totalGatherErrorsMetric,
+		lastGatherErrorsMetric:  lastGatherErrorsMetric,
+	}
+}
+
+func (c *GatherErrorsCollector) Gather() ([]*dto.MetricFamily, error) {
+	metricFamilies, err := c.gatherer.Gather()
+
+	var gatherErrors int
+	if err != nil {
+		if multiErr, ok := err.(prometheus.MultiError); ok {
+			gatherErrors = len(multiErr)
+		} else {
+			gatherErrors = 1
+		}
+	}
+	c.totalGatherErrorsMetric.Add(float64(gatherErrors))
+	c.lastGatherErrorsMetric.Set(float64(gatherErrors))
+
+	return metricFamilies, err
+}
+
+func (c *GatherErrorsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.totalGatherErrorsMetric.Collect(ch)
+	c.lastGatherErrorsMetric.Collect(ch)
+}
+
+func (c *GatherErrorsCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.totalGatherErrorsMetric.Describe(ch)
+	c.lastGatherErrorsMetric.Describe(ch)
+}
@@ -0,0 +1,110 @@
+package collectors_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	. "github.com/bosh-prometheus/firehose_exporter/collectors"
+	. "github.com/bosh-prometheus/firehose_exporter/utils/test_matchers"
+)
+
+type inconsistentCollector struct{}
+
+func (c inconsistentCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc("fake_metric", "Fake help.", []string{"fake_label"}, nil),
+		prometheus.GaugeValue,
+		1,
+		"fake_value_1",
+	)
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc("fake_metric", "Other fake help.", []string{"fake_label"}, nil),
+		prometheus.GaugeValue,
+		1,
+		"fake_value_2",
+	)
+}
+
+func (c inconsistentCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+var _ = Describe("GatherErrorsCollector", func() {
+	var (
+		namespace             string
+		environment           string
+		registry              *prometheus.Registry
+		gatherErrorsCollector *GatherErrorsCollector
+
+		totalGatherErrorsMetric prometheus.Counter
+		lastGatherErrorsMetric  prometheus.Gauge
+	)
+
+	BeforeEach(func() {
+		namespace = "test_exporter"
+		environment = "test_environment"
+		registry = prometheus.NewRegistry()
+
+		totalGatherErrorsMetric = prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace:   namespace,
+				Subsystem:   "",
+				Name:        "gather_errors_total",
+				Help:        "Total number of errors encountered while gathering the exposed metrics.",
+				ConstLabels: prometheus.Labels{"environment": environment},
+			},
+		)
+
+		lastGatherErrorsMetric = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   "",
+				Name:        "last_gather_errors",
+				Help:        "Number of errors encountered at the last gathering of the exposed metrics.",
+				ConstLabels: prometheus.Labels{"environment": environment},
+			},
+		)
+	})
+
+	JustBeforeEach(func() {
+		gatherErrorsCollector = NewGatherErrorsCollector(namespace, environment, registry)
+		registry.MustRegister(gatherErrorsCollector)
+	})
+
+	Describe("Gather", func() {
+		Context("when the gathered metrics are consistent", func() {
+			It("does not count any error", func() {
+				_, err := gatherErrorsCollector.Gather()
+				Expect(err).ToNot(HaveOccurred())
+
+				metrics := make(chan prometheus.Metric, 2)
+				gatherErrorsCollector.Collect(metrics)
+				Expect(<-metrics).To(PrometheusMetric(totalGatherErrorsMetric))
+				Expect(<-metrics).To(PrometheusMetric(lastGatherErrorsMetric))
+			})
+		})
+
+		Context("when the gathered metrics are inconsistent", func() {
+			JustBeforeEach(func() {
+				registry.MustRegister(inconsistentCollector{})
+			})
+
+			It("returns the metrics and counts the errors", func() {
+				metricFamilies, err := gatherErrorsCollector.Gather()
+				Expect(err).To(HaveOccurred())
+				Expect(metricFamilies).ToNot(BeEmpty())
+
+				_, err = gatherErrorsCollector.Gather()
+				Expect(err).To(HaveOccurred())
+
+				totalGatherErrorsMetric.Add(2)
+				lastGatherErrorsMetric.Set(1)
+
+				metrics := make(chan prometheus.Metric, 2)
+				gatherErrorsCollector.Collect(metrics)
+				Expect(<-metrics).To(PrometheusMetric(totalGatherErrorsMetric))
+				Expect(<-metrics).To(PrometheusMetric(lastGatherErrorsMetric))
+			})
+		})
+	})
+})
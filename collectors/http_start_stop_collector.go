@@ -1,12 +1,24 @@
 package collectors
 
 import (
+	"fmt"
 	"net/url"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/golang/protobuf/proto"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 
+	"github.com/bosh-prometheus/firehose_exporter/cloudcontroller"
 	"github.com/bosh-prometheus/firehose_exporter/metrics"
+	"github.com/bosh-prometheus/firehose_exporter/routes"
+	"github.com/bosh-prometheus/firehose_exporter/tagpolicy"
 	"github.com/bosh-prometheus/firehose_exporter/utils"
 )
 
@@ -14,39 +26,136 @@ type HttpStartStopCollector struct {
 	namespace                          string
 	environment                        string
 	metricsStore                       *metrics.Store
-	requestsMetric                     *prometheus.GaugeVec
-	responseSizeBytesMetric            *prometheus.SummaryVec
+	seriesExpiration                   time.Duration
+	routeMatcher                       *routes.Matcher
+	applications                       *cloudcontroller.Cache
+	tagPolicy                          *tagpolicy.Policy
+	statusCodeClasses                  bool
+	topApplications                    *topApplications
+	labelNames                         []string
+	requestLabelNames                  []string
+	nameLabelNames                     []string
+	requestsMetric                     *prometheus.CounterVec
+	responseSizeBytesMetric            *prometheus.HistogramVec
 	lastRequestTimestampMetric         *prometheus.GaugeVec
-	clientRequestDurationSecondsMetric *prometheus.SummaryVec
-	serverRequestDurationSecondsMetric *prometheus.SummaryVec
+	clientRequestDurationSecondsMetric *prometheus.HistogramVec
+	serverRequestDurationSecondsMetric *prometheus.HistogramVec
+	routerOverheadSecondsMetric        *prometheus.HistogramVec
+	requestsDesc                       *prometheus.Desc
+	responseSizeBytesDesc              *prometheus.Desc
+	lastRequestTimestampDesc           *prometheus.Desc
+	clientRequestDurationSecondsDesc   *prometheus.Desc
+	serverRequestDurationSecondsDesc   *prometheus.Desc
+	routerOverheadSecondsDesc          *prometheus.Desc
+
+	mutex               sync.Mutex
+	seriesByApplication map[string]*applicationSeries
+}
+
+// httpStartStopLabelNames are the labels of the http start stop metrics, in
+// order. Only those in httpStartStopDroppableLabelNames can be dropped. The
+// httpStartStopNameLabelNames are not part of the identity of the series:
+// they are looked up from the application id at collection.
+var (
+	httpStartStopLabelNames          = []string{"bosh_deployment", "application_id", "application_name", "space_name", "organization_name", "instance_id", "method", "scheme", "host", "route"}
+	httpStartStopNameLabelNames      = []string{"application_name", "space_name", "organization_name"}
+	httpStartStopDroppableLabelNames = map[string]bool{"application_name": true, "space_name": true, "organization_name": true, "instance_id": true, "method": true, "scheme": true, "host": true, "route": true, "status_code": true}
+)
+
+// applicationSeries tracks the label values observed for an application, so
+// that its series can be deleted once it stops receiving requests.
+type applicationSeries struct {
+	lastSeen      time.Time
+	series        map[string][]string
+	requestSeries map[string][]string
 }
 
 func NewHttpStartStopCollector(
 	namespace string,
 	environment string,
 	metricsStore *metrics.Store,
-) *HttpStartStopCollector {
-	requestsMetric := prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
+	durationBuckets []float64,
+	responseSizeBuckets []float64,
+	seriesExpiration time.Duration,
+	routeMatcher *routes.Matcher,
+	applications *cloudcontroller.Cache,
+	dropLabels []string,
+	statusCodeClasses bool,
+	tagPolicy *tagpolicy.Policy,
+	topApplicationsCount int,
+	topApplicationsWindow time.Duration,
+) (*HttpStartStopCollector, error) {
+	if topApplicationsCount > 0 && topApplicationsWindow <= 0 {
+		return nil, fmt.Errorf("Http start stop top applications window must be positive")
+	}
+
+	droppedLabels := map[string]bool{}
+	for _, label := range dropLabels {
+		if !httpStartStopDroppableLabelNames[label] {
+			return nil, fmt.Errorf("Http start stop label `%s` can not be dropped", label)
+		}
+		droppedLabels[label] = true
+	}
+
+	var labelNames, nameLabelNames, describedLabelNames []string
+	for _, label := range httpStartStopLabelNames {
+		if droppedLabels[label] {
+			continue
+		}
+		describedLabelNames = append(describedLabelNames, label)
+		if utils.ContainsString(httpStartStopNameLabelNames, label) {
+			nameLabelNames = append(nameLabelNames, label)
+		} else {
+			labelNames = append(labelNames, label)
+		}
+	}
+	for _, label := range tagPolicy.FixedLabelNames() {
+		if label == "environment" || label == "status_code" || utils.ContainsString(httpStartStopLabelNames, label) {
+			return nil, fmt.Errorf("Tag label `%s` collides with a http start stop label", label)
+		}
+	}
+	labelNames = append(labelNames, tagPolicy.FixedLabelNames()...)
+	describedLabelNames = append(describedLabelNames, tagPolicy.FixedLabelNames()...)
+	requestLabelNames := labelNames[:len(labelNames):len(labelNames)]
+	describedRequestLabelNames := describedLabelNames[:len(describedLabelNames):len(describedLabelNames)]
+	if !droppedLabels["status_code"] {
+		requestLabelNames = append(requestLabelNames, "status_code")
+		describedRequestLabelNames = append(describedRequestLabelNames, "status_code")
+	}
+
+	desc := func(name string, help string, labelNames []string) *prometheus.Desc {
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, http_start_stop_subsystem, name),
+			help,
+			labelNames,
+			prometheus.Labels{"environment": environment},
+		)
+	}
+
+	requestsMetric := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
 			Namespace:   namespace,
 			Subsystem:   http_start_stop_subsystem,
-			Name:        "requests",
-			Help:        "Cloud Foundry Firehose http start stop requests.",
+			Name:        "requests_total",
+			Help:        "Total number of Cloud Foundry Firehose http start stop requests.",
 			ConstLabels: prometheus.Labels{"environment": environment},
 		},
-		[]string{"bosh_deployment", "application_id", "instance_id", "method", "scheme", "host", "status_code"},
+		requestLabelNames,
 	)
+	requestsDesc := desc("requests_total", "Total number of Cloud Foundry Firehose http start stop requests.", describedRequestLabelNames)
 
-	responseSizeBytesMetric := prometheus.NewSummaryVec(
-		prometheus.SummaryOpts{
+	responseSizeBytesMetric := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
 			Namespace:   namespace,
 			Subsystem:   http_start_stop_subsystem,
 			Name:        "response_size_bytes",
-			Help:        "Summary of Cloud Foundry Firehose http start stop request size in bytes.",
+			Help:        "Histogram of Cloud Foundry Firehose http start stop request size in bytes.",
 			ConstLabels: prometheus.Labels{"environment": environment},
+			Buckets:     responseSizeBuckets,
 		},
-		[]string{"bosh_deployment", "application_id", "instance_id", "method", "scheme", "host"},
+		labelNames,
 	)
+	responseSizeBytesDesc := desc("response_size_bytes", "Histogram of Cloud Foundry Firehose http start stop request size in bytes.", describedLabelNames)
 
 	lastRequestTimestampMetric := prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -56,137 +165,367 @@ func NewHttpStartStopCollector(
 			Help:        "Number of seconds since 1970 since last http start stop received from Cloud Foundry Firehose.",
 			ConstLabels: prometheus.Labels{"environment": environment},
 		},
-		[]string{"bosh_deployment", "application_id", "instance_id", "method", "scheme", "host"},
+		labelNames,
 	)
+	lastRequestTimestampDesc := desc("last_request_timestamp", "Number of seconds since 1970 since last http start stop received from Cloud Foundry Firehose.", describedLabelNames)
 
-	clientRequestDurationSecondsMetric := prometheus.NewSummaryVec(
-		prometheus.SummaryOpts{
+	clientRequestDurationSecondsMetric := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
 			Namespace:   namespace,
 			Subsystem:   http_start_stop_subsystem,
 			Name:        "client_request_duration_seconds",
-			Help:        "Summary of Cloud Foundry Firehose http start stop client request duration in seconds.",
+			Help:        "Histogram of Cloud Foundry Firehose http start stop client request duration in seconds.",
 			ConstLabels: prometheus.Labels{"environment": environment},
+			Buckets:     durationBuckets,
 		},
-		[]string{"bosh_deployment", "application_id", "instance_id", "method", "scheme", "host"},
+		labelNames,
 	)
+	clientRequestDurationSecondsDesc := desc("client_request_duration_seconds", "Histogram of Cloud Foundry Firehose http start stop client request duration in seconds.", describedLabelNames)
 
-	serverRequestDurationSecondsMetric := prometheus.NewSummaryVec(
-		prometheus.SummaryOpts{
+	serverRequestDurationSecondsMetric := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
 			Namespace:   namespace,
 			Subsystem:   http_start_stop_subsystem,
 			Name:        "server_request_duration_seconds",
-			Help:        "Summary of Cloud Foundry Firehose http start stop server request duration in seconds.",
+			Help:        "Histogram of Cloud Foundry Firehose http start stop server request duration in seconds.",
+			ConstLabels: prometheus.Labels{"environment": environment},
+			Buckets:     durationBuckets,
+		},
+		labelNames,
+	)
+	serverRequestDurationSecondsDesc := desc("server_request_duration_seconds", "Histogram of Cloud Foundry Firehose http start stop server request duration in seconds.", describedLabelNames)
+
+	routerOverheadSecondsMetric := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   http_start_stop_subsystem,
+			Name:        "router_overhead_seconds",
+			Help:        "Histogram of Cloud Foundry Firehose http start stop router overhead (client request duration minus server request duration) in seconds.",
 			ConstLabels: prometheus.Labels{"environment": environment},
+			Buckets:     durationBuckets,
 		},
-		[]string{"bosh_deployment", "application_id", "instance_id", "method", "scheme", "host"},
+		labelNames,
 	)
+	routerOverheadSecondsDesc := desc("router_overhead_seconds", "Histogram of Cloud Foundry Firehose http start stop router overhead (client request duration minus server request duration) in seconds.", describedLabelNames)
 
-	return &HttpStartStopCollector{
+	collector := &HttpStartStopCollector{
 		namespace:                          namespace,
 		environment:                        environment,
 		metricsStore:                       metricsStore,
+		seriesExpiration:                   seriesExpiration,
+		routeMatcher:                       routeMatcher,
+		applications:                       applications,
+		tagPolicy:                          tagPolicy,
+		statusCodeClasses:                  statusCodeClasses,
+		topApplications:                    newTopApplications(topApplicationsCount, topApplicationsWindow),
+		labelNames:                         labelNames,
+		requestLabelNames:                  requestLabelNames,
+		nameLabelNames:                     nameLabelNames,
 		requestsMetric:                     requestsMetric,
 		responseSizeBytesMetric:            responseSizeBytesMetric,
 		lastRequestTimestampMetric:         lastRequestTimestampMetric,
 		clientRequestDurationSecondsMetric: clientRequestDurationSecondsMetric,
 		serverRequestDurationSecondsMetric: serverRequestDurationSecondsMetric,
+		routerOverheadSecondsMetric:        routerOverheadSecondsMetric,
+		requestsDesc:                       requestsDesc,
+		responseSizeBytesDesc:              responseSizeBytesDesc,
+		lastRequestTimestampDesc:           lastRequestTimestampDesc,
+		clientRequestDurationSecondsDesc:   clientRequestDurationSecondsDesc,
+		serverRequestDurationSecondsDesc:   serverRequestDurationSecondsDesc,
+		routerOverheadSecondsDesc:          routerOverheadSecondsDesc,
+		seriesByApplication:                map[string]*applicationSeries{},
 	}
+	metricsStore.AddHttpStartStopObserver(collector)
+
+	return collector, nil
 }
 
-func (c HttpStartStopCollector) Collect(ch chan<- prometheus.Metric) {
-	// We reset metrics here to NOT report HttpStartStop events for
-	// applications that do NOT exist anymore. The trade-off is that
-	// these metrics will only report events captured during the
-	// slidding window defined at the `doppler.metric-expiration`
-	// command flag.
-	c.requestsMetric.Reset()
-	c.responseSizeBytesMetric.Reset()
-	c.lastRequestTimestampMetric.Reset()
-	c.clientRequestDurationSecondsMetric.Reset()
-	c.serverRequestDurationSecondsMetric.Reset()
+// ObserveHttpStartStop accumulates http start stop events as they are
+// received. The request is accounted when the client peer (the one carrying
+// the application) is received, and the server duration whenever both peers
+// are known, whatever the order they arrive in. The router overhead is
+// observed once both peers are known. The requests of the
+// applications out of the top applications, if enabled, are folded into the
+// `other` application, without application, instance, host nor route, and
+// the series of the applications leaving the tops are deleted.
+func (c *HttpStartStopCollector) ObserveHttpStartStop(httpStartStop metrics.HttpStartStop, peerType events.PeerType) {
+	if httpStartStop.ApplicationId == "" {
+		return
+	}
 
-	for _, httpStartStop := range c.metricsStore.GetHttpStartStops() {
-		if httpStartStop.ApplicationId == "" {
-			continue
-		}
+	request := peerType != events.PeerType_Server
+	kept, demoted := c.topApplications.keep(httpStartStop.ApplicationId, request, request && httpStartStop.StatusCode >= 500)
+	folded := !kept
 
-		var scheme, host string
-		uri, err := url.Parse(httpStartStop.Uri)
-		if err == nil {
-			scheme = uri.Scheme
+	var scheme, host, route string
+	uri, err := url.Parse(httpStartStop.Uri)
+	if err == nil {
+		scheme = uri.Scheme
+		if !folded {
 			host = uri.Host
+			route = c.routeMatcher.Route(httpStartStop.ApplicationId, uri.Path)
 		}
+	}
 
-		c.requestsMetric.WithLabelValues(
-			httpStartStop.Deployment,
-			httpStartStop.ApplicationId,
-			httpStartStop.InstanceId,
-			httpStartStop.Method,
-			scheme,
-			host,
-			strconv.Itoa(int(httpStartStop.StatusCode)),
-		).Inc()
-
-		c.responseSizeBytesMetric.WithLabelValues(
-			httpStartStop.Deployment,
-			httpStartStop.ApplicationId,
-			httpStartStop.InstanceId,
-			httpStartStop.Method,
-			scheme,
-			host,
-		).Observe(float64(httpStartStop.ContentLength))
-
-		var lastRequestTimestamp float64
-		if httpStartStop.ClientStartTimestamp > 0 {
-			lastRequestTimestamp = utils.NanosecondsToSeconds(httpStartStop.ClientStartTimestamp)
-		} else {
-			lastRequestTimestamp = utils.NanosecondsToSeconds(httpStartStop.ServerStartTimestamp)
+	statusCode := strconv.Itoa(int(httpStartStop.StatusCode))
+	if c.statusCodeClasses && httpStartStop.StatusCode >= 100 && httpStartStop.StatusCode < 600 {
+		statusCode = statusCode[:1] + "xx"
+	}
+
+	applicationID := httpStartStop.ApplicationId
+	instanceID := httpStartStop.InstanceId
+	if folded {
+		applicationID = otherApplicationID
+		instanceID = ""
+	}
+
+	values := map[string]string{
+		"bosh_deployment": httpStartStop.Deployment,
+		"application_id":  applicationID,
+		"instance_id":     instanceID,
+		"method":          httpStartStop.Method,
+		"scheme":          scheme,
+		"host":            host,
+		"route":           route,
+		"status_code":     statusCode,
+	}
+	tagLabelValues := c.tagPolicy.FixedLabelValues(httpStartStop.Tags)
+	for i, label := range c.tagPolicy.FixedLabelNames() {
+		values[label] = tagLabelValues[i]
+	}
+	labelValues := make([]string, len(c.labelNames))
+	for i, label := range c.labelNames {
+		labelValues[i] = values[label]
+	}
+	requestLabelValues := make([]string, len(c.requestLabelNames))
+	for i, label := range c.requestLabelNames {
+		requestLabelValues[i] = values[label]
+	}
+
+	exemplar := requestExemplar(httpStartStop)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, demotedApplicationID := range demoted {
+		c.forget(demotedApplicationID)
+	}
+	c.track(applicationID, labelValues, requestLabelValues)
+
+	clientDuration := httpStartStop.ClientStopTimestamp - httpStartStop.ClientStartTimestamp
+	serverDuration := httpStartStop.ServerStopTimestamp - httpStartStop.ServerStartTimestamp
+	if clientDuration > 0 && serverDuration > 0 && clientDuration >= serverDuration {
+		observeWithExemplar(c.routerOverheadSecondsMetric.WithLabelValues(labelValues...), utils.NanosecondsToSeconds(clientDuration-serverDuration), exemplar)
+	}
+
+	if peerType == events.PeerType_Server {
+		if serverDuration > 0 {
+			observeWithExemplar(c.serverRequestDurationSecondsMetric.WithLabelValues(labelValues...), utils.NanosecondsToSeconds(serverDuration), exemplar)
+		}
+		return
+	}
+
+	c.requestsMetric.WithLabelValues(requestLabelValues...).Inc()
+
+	c.responseSizeBytesMetric.WithLabelValues(labelValues...).Observe(float64(httpStartStop.ContentLength))
+
+	var lastRequestTimestamp float64
+	if httpStartStop.ClientStartTimestamp > 0 {
+		lastRequestTimestamp = utils.NanosecondsToSeconds(httpStartStop.ClientStartTimestamp)
+	} else {
+		lastRequestTimestamp = utils.NanosecondsToSeconds(httpStartStop.ServerStartTimestamp)
+	}
+	c.lastRequestTimestampMetric.WithLabelValues(labelValues...).Set(lastRequestTimestamp)
+
+	if clientDuration > 0 {
+		observeWithExemplar(c.clientRequestDurationSecondsMetric.WithLabelValues(labelValues...), utils.NanosecondsToSeconds(clientDuration), exemplar)
+	}
+
+	if serverDuration > 0 {
+		observeWithExemplar(c.serverRequestDurationSecondsMetric.WithLabelValues(labelValues...), utils.NanosecondsToSeconds(serverDuration), exemplar)
+	}
+}
+
+// traceIdTags are the envelope tags the B3 trace id of a request may be
+// reported in.
+var traceIdTags = []string{"b3_trace_id", "x_b3_traceid", "trace_id"}
+
+// requestExemplar returns the exemplar labels linking an observation to its
+// request: its request id and, when tagged, its B3 trace id. As exemplar
+// labels are limited to prometheus.ExemplarMaxRunes, the trace id wins when
+// both do not fit (the request id can then be found with the trace).
+func requestExemplar(httpStartStop metrics.HttpStartStop) prometheus.Labels {
+	var traceId string
+	for _, tag := range traceIdTags {
+		if traceId = httpStartStop.Tags[tag]; traceId != "" {
+			break
 		}
-		c.lastRequestTimestampMetric.WithLabelValues(
-			httpStartStop.Deployment,
-			httpStartStop.ApplicationId,
-			httpStartStop.InstanceId,
-			httpStartStop.Method,
-			scheme,
-			host,
-		).Set(lastRequestTimestamp)
-
-		clientDuration := httpStartStop.ClientStopTimestamp - httpStartStop.ClientStartTimestamp
-		if clientDuration > 0 {
-			c.clientRequestDurationSecondsMetric.WithLabelValues(
-				httpStartStop.Deployment,
-				httpStartStop.ApplicationId,
-				httpStartStop.InstanceId,
-				httpStartStop.Method,
-				scheme,
-				host,
-			).Observe(utils.NanosecondsToSeconds(clientDuration))
+	}
+	if !utf8.ValidString(traceId) {
+		traceId = ""
+	}
+
+	exemplar := prometheus.Labels{}
+	if httpStartStop.RequestId != "" {
+		exemplar["request_id"] = httpStartStop.RequestId
+	}
+	if traceId != "" {
+		exemplar["trace_id"] = traceId
+		if exemplarRunes(exemplar) > prometheus.ExemplarMaxRunes {
+			exemplar = prometheus.Labels{"trace_id": traceId}
 		}
+	}
+	if len(exemplar) == 0 || exemplarRunes(exemplar) > prometheus.ExemplarMaxRunes {
+		return nil
+	}
 
-		serverDuration := httpStartStop.ServerStopTimestamp - httpStartStop.ServerStartTimestamp
-		if serverDuration > 0 {
-			c.serverRequestDurationSecondsMetric.WithLabelValues(
-				httpStartStop.Deployment,
-				httpStartStop.ApplicationId,
-				httpStartStop.InstanceId,
-				httpStartStop.Method,
-				scheme,
-				host,
-			).Observe(utils.NanosecondsToSeconds(serverDuration))
+	return exemplar
+}
+
+func exemplarRunes(exemplar prometheus.Labels) int {
+	var runes int
+	for name, value := range exemplar {
+		runes += utf8.RuneCountInString(name) + utf8.RuneCountInString(value)
+	}
+
+	return runes
+}
+
+func observeWithExemplar(observer prometheus.Observer, value float64, exemplar prometheus.Labels) {
+	if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok && exemplar != nil {
+		exemplarObserver.ObserveWithExemplar(value, exemplar)
+		return
+	}
+
+	observer.Observe(value)
+}
+
+func (c *HttpStartStopCollector) track(applicationID string, labelValues []string, requestLabelValues []string) {
+	application, ok := c.seriesByApplication[applicationID]
+	if !ok {
+		application = &applicationSeries{
+			series:        map[string][]string{},
+			requestSeries: map[string][]string{},
+		}
+		c.seriesByApplication[applicationID] = application
+	}
+
+	application.lastSeen = time.Now()
+	application.series[strings.Join(labelValues, "\xff")] = labelValues
+	application.requestSeries[strings.Join(requestLabelValues, "\xff")] = requestLabelValues
+}
+
+// expire deletes the series of the applications that did not receive any
+// request during the series expiration, such as deleted applications.
+func (c *HttpStartStopCollector) expire() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	for applicationID, application := range c.seriesByApplication {
+		if now.Sub(application.lastSeen) >= c.seriesExpiration {
+			c.forget(applicationID)
+		}
+	}
+}
+
+// forget deletes the series of an application.
+func (c *HttpStartStopCollector) forget(applicationID string) {
+	application, ok := c.seriesByApplication[applicationID]
+	if !ok {
+		return
+	}
+
+	for _, labelValues := range application.series {
+		c.responseSizeBytesMetric.DeleteLabelValues(labelValues...)
+		c.lastRequestTimestampMetric.DeleteLabelValues(labelValues...)
+		c.clientRequestDurationSecondsMetric.DeleteLabelValues(labelValues...)
+		c.serverRequestDurationSecondsMetric.DeleteLabelValues(labelValues...)
+		c.routerOverheadSecondsMetric.DeleteLabelValues(labelValues...)
+	}
+	for _, labelValues := range application.requestSeries {
+		c.requestsMetric.DeleteLabelValues(labelValues...)
+	}
+	delete(c.seriesByApplication, applicationID)
+	c.routeMatcher.Forget(applicationID)
+}
+
+func (c *HttpStartStopCollector) Collect(ch chan<- prometheus.Metric) {
+	c.expire()
+
+	c.collectWithNames(c.requestsMetric, c.requestsDesc, ch)
+	c.collectWithNames(c.responseSizeBytesMetric, c.responseSizeBytesDesc, ch)
+	c.collectWithNames(c.lastRequestTimestampMetric, c.lastRequestTimestampDesc, ch)
+	c.collectWithNames(c.clientRequestDurationSecondsMetric, c.clientRequestDurationSecondsDesc, ch)
+	c.collectWithNames(c.serverRequestDurationSecondsMetric, c.serverRequestDurationSecondsDesc, ch)
+	c.collectWithNames(c.routerOverheadSecondsMetric, c.routerOverheadSecondsDesc, ch)
+}
+
+// collectWithNames collects the series of a metric, adding the application,
+// space and organization names currently known for their application id. As
+// the names are not part of the identity of the series, renaming an
+// application does not reset its series.
+func (c *HttpStartStopCollector) collectWithNames(collector prometheus.Collector, desc *prometheus.Desc, ch chan<- prometheus.Metric) {
+	metrics := make(chan prometheus.Metric)
+	go func() {
+		collector.Collect(metrics)
+		close(metrics)
+	}()
+
+	for metric := range metrics {
+		named := &dto.Metric{}
+		if err := metric.Write(named); err != nil {
+			ch <- prometheus.NewInvalidMetric(desc, err)
+			continue
+		}
+
+		var application cloudcontroller.Application
+		for _, label := range named.Label {
+			if label.GetName() == "application_id" {
+				application, _ = c.applications.Lookup(label.GetValue())
+			}
+		}
+		names := map[string]string{
+			"application_name":  application.Name,
+			"space_name":        application.SpaceName,
+			"organization_name": application.OrganizationName,
+		}
+		for _, label := range c.nameLabelNames {
+			named.Label = append(named.Label, &dto.LabelPair{Name: proto.String(label), Value: proto.String(names[label])})
 		}
+		sort.Slice(named.Label, func(i, j int) bool {
+			return named.Label[i].GetName() < named.Label[j].GetName()
+		})
+
+		ch <- &writtenMetric{desc: desc, metric: named}
 	}
+}
+
+// writtenMetric is a metric already written, exemplars included.
+type writtenMetric struct {
+	desc   *prometheus.Desc
+	metric *dto.Metric
+}
+
+func (m *writtenMetric) Desc() *prometheus.Desc {
+	return m.desc
+}
+
+func (m *writtenMetric) Write(out *dto.Metric) error {
+	out.Label = m.metric.Label
+	out.Gauge = m.metric.Gauge
+	out.Counter = m.metric.Counter
+	out.Histogram = m.metric.Histogram
+	out.TimestampMs = m.metric.TimestampMs
 
-	c.requestsMetric.Collect(ch)
-	c.responseSizeBytesMetric.Collect(ch)
-	c.lastRequestTimestampMetric.Collect(ch)
-	c.clientRequestDurationSecondsMetric.Collect(ch)
-	c.serverRequestDurationSecondsMetric.Collect(ch)
+	return nil
 }
 
-func (c HttpStartStopCollector) Describe(ch chan<- *prometheus.Desc) {
-	c.requestsMetric.Describe(ch)
-	c.responseSizeBytesMetric.Describe(ch)
-	c.lastRequestTimestampMetric.Describe(ch)
-	c.clientRequestDurationSecondsMetric.Describe(ch)
-	c.serverRequestDurationSecondsMetric.Describe(ch)
+func (c *HttpStartStopCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.requestsDesc
+	ch <- c.responseSizeBytesDesc
+	ch <- c.lastRequestTimestampDesc
+	ch <- c.clientRequestDurationSecondsDesc
+	ch <- c.serverRequestDurationSecondsDesc
+	ch <- c.routerOverheadSecondsDesc
 }
@@ -8,12 +8,16 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/bosh-prometheus/firehose_exporter/cloudcontroller"
 	"github.com/bosh-prometheus/firehose_exporter/filters"
 	"github.com/bosh-prometheus/firehose_exporter/metrics"
+	"github.com/bosh-prometheus/firehose_exporter/routes"
+	"github.com/bosh-prometheus/firehose_exporter/tagpolicy"
 	"github.com/bosh-prometheus/firehose_exporter/utils"
 	"github.com/cloudfoundry/sonde-go/events"
 	"github.com/gogo/protobuf/proto"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 
 	. "github.com/bosh-prometheus/firehose_exporter/collectors"
 	. "github.com/bosh-prometheus/firehose_exporter/utils/test_matchers"
@@ -30,11 +34,22 @@ var _ = Describe("HttpStartStopCollector", func() {
 		eventFilter            *filters.EventFilter
 		httpStartStopCollector *HttpStartStopCollector
 
-		requestsMetric                     *prometheus.GaugeVec
-		responseSizeBytesMetric            *prometheus.SummaryVec
+		durationBuckets                    []float64
+		responseSizeBuckets                []float64
+		seriesExpiration                   time.Duration
+		routeMatcher                       *routes.Matcher
+		applications                       *cloudcontroller.Cache
+		dropLabels                         []string
+		statusCodeClasses                  bool
+		tagPolicy                          *tagpolicy.Policy
+		topApplicationsCount               int
+		topApplicationsWindow              time.Duration
+		requestsMetric                     *prometheus.CounterVec
+		responseSizeBytesMetric            *prometheus.HistogramVec
 		lastRequestTimestampMetric         *prometheus.GaugeVec
-		clientRequestDurationSecondsMetric *prometheus.SummaryVec
-		serverRequestDurationSecondsMetric *prometheus.SummaryVec
+		clientRequestDurationSecondsMetric *prometheus.HistogramVec
+		serverRequestDurationSecondsMetric *prometheus.HistogramVec
+		routerOverheadSecondsMetric        *prometheus.HistogramVec
 
 		origin         = "fake-origin"
 		boshDeployment = "fake-deployment-name"
@@ -48,18 +63,23 @@ var _ = Describe("HttpStartStopCollector", func() {
 		httpStartStopServerStopTimestamp  = int64(10)
 		httpStartStopClientDuration       = utils.NanosecondsToSeconds(httpStartStopClientStopTimestamp - httpStartStopClientStartTimestamp)
 		httpStartStopServerDuration       = utils.NanosecondsToSeconds(httpStartStopServerStopTimestamp - httpStartStopServerStartTimestamp)
+		httpStartStopRouterOverhead       = httpStartStopClientDuration - httpStartStopServerDuration
 		httpStartStopRequestId            = "1beb4072-acaa-483f-5a8b-425dc080af13"
 		httpStartStopClientPeerType       = events.PeerType_Client
 		httpStartStopServerPeerType       = events.PeerType_Server
 		httpStartStopMethod               = "GET"
 		httpStartStopScheme               = "http"
 		httpStartStopHost                 = "www.example.com"
+		httpStartStopRoute                = "/foo"
 		httpStartStopUri                  = fmt.Sprintf("%s://user:password@%s/foo?bar", httpStartStopScheme, httpStartStopHost)
 		httpStartStopRemoteAddress        = "FakeRemoteAddress"
 		httpStartStopUserAgent            = "FakeUserAgent"
 		httpStartStopStatusCode           = int32(200)
 		httpStartStopContentLength        = int64(32)
 		httpStartStopApplicationId        = "8060986d-43aa-4097-8989-1c292accbeb3"
+		httpStartStopApplicationName      = "fake-app"
+		httpStartStopSpaceName            = "fake-space"
+		httpStartStopOrganizationName     = "fake-org"
 		httpStartStopInstanceIndex        = int32(1)
 		httpStartStopInstanceId           = "FakeInstanceId"
 	)
@@ -70,46 +90,72 @@ var _ = Describe("HttpStartStopCollector", func() {
 		deploymentFilter = filters.NewDeploymentFilter([]string{})
 		eventFilter, _ = filters.NewEventFilter([]string{})
 		metricsStore = metrics.NewStore(metricsExpiration, metricsCleanupInterval, deploymentFilter, eventFilter)
-
-		requestsMetric = prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
+		durationBuckets = []float64{0.1, 1}
+		responseSizeBuckets = []float64{10, 100}
+		seriesExpiration = time.Hour
+		routeMatcher, _ = routes.NewMatcher([]routes.Rule{{Prefix: "/foo"}}, 10)
+		applications = cloudcontroller.NewCache(nil, time.Minute)
+		applications.Set(map[string]cloudcontroller.Application{
+			httpStartStopApplicationId: {
+				Name:             httpStartStopApplicationName,
+				SpaceName:        httpStartStopSpaceName,
+				OrganizationName: httpStartStopOrganizationName,
+			},
+		})
+		dropLabels = []string{}
+		statusCodeClasses = false
+		tagPolicy = nil
+		topApplicationsCount = 0
+		topApplicationsWindow = time.Hour
+
+		requestsMetric = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
 				Namespace:   namespace,
 				Subsystem:   "http_start_stop",
-				Name:        "requests",
-				Help:        "Cloud Foundry Firehose http start stop requests.",
+				Name:        "requests_total",
+				Help:        "Total number of Cloud Foundry Firehose http start stop requests.",
 				ConstLabels: prometheus.Labels{"environment": environment},
 			},
-			[]string{"bosh_deployment", "application_id", "instance_id", "method", "scheme", "host", "status_code"},
+			[]string{"bosh_deployment", "application_id", "application_name", "space_name", "organization_name", "instance_id", "method", "scheme", "host", "route", "status_code"},
 		)
 
 		requestsMetric.WithLabelValues(
 			boshDeployment,
 			httpStartStopApplicationId,
+			httpStartStopApplicationName,
+			httpStartStopSpaceName,
+			httpStartStopOrganizationName,
 			httpStartStopInstanceId,
 			httpStartStopMethod,
 			httpStartStopScheme,
 			httpStartStopHost,
+			httpStartStopRoute,
 			strconv.Itoa(int(httpStartStopStatusCode)),
 		).Inc()
 
-		responseSizeBytesMetric = prometheus.NewSummaryVec(
-			prometheus.SummaryOpts{
+		responseSizeBytesMetric = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
 				Namespace:   namespace,
 				Subsystem:   "http_start_stop",
 				Name:        "response_size_bytes",
-				Help:        "Summary of Cloud Foundry Firehose http start stop request size in bytes.",
+				Help:        "Histogram of Cloud Foundry Firehose http start stop request size in bytes.",
+				Buckets:     responseSizeBuckets,
 				ConstLabels: prometheus.Labels{"environment": environment},
 			},
-			[]string{"bosh_deployment", "application_id", "instance_id", "method", "scheme", "host"},
+			[]string{"bosh_deployment", "application_id", "application_name", "space_name", "organization_name", "instance_id", "method", "scheme", "host", "route"},
 		)
 
 		responseSizeBytesMetric.WithLabelValues(
 			boshDeployment,
 			httpStartStopApplicationId,
+			httpStartStopApplicationName,
+			httpStartStopSpaceName,
+			httpStartStopOrganizationName,
 			httpStartStopInstanceId,
 			httpStartStopMethod,
 			httpStartStopScheme,
 			httpStartStopHost,
+			httpStartStopRoute,
 		).Observe(float64(httpStartStopContentLength))
 
 		lastRequestTimestampMetric = prometheus.NewGaugeVec(
@@ -120,61 +166,114 @@ var _ = Describe("HttpStartStopCollector", func() {
 				Help:        "Number of seconds since 1970 since last http start stop received from Cloud Foundry Firehose.",
 				ConstLabels: prometheus.Labels{"environment": environment},
 			},
-			[]string{"bosh_deployment", "application_id", "instance_id", "method", "scheme", "host"},
+			[]string{"bosh_deployment", "application_id", "application_name", "space_name", "organization_name", "instance_id", "method", "scheme", "host", "route"},
 		)
 
 		lastRequestTimestampMetric.WithLabelValues(
 			boshDeployment,
 			httpStartStopApplicationId,
+			httpStartStopApplicationName,
+			httpStartStopSpaceName,
+			httpStartStopOrganizationName,
 			httpStartStopInstanceId,
 			httpStartStopMethod,
 			httpStartStopScheme,
 			httpStartStopHost,
+			httpStartStopRoute,
 		).Set(utils.NanosecondsToSeconds(httpStartStopClientStartTimestamp))
 
-		clientRequestDurationSecondsMetric = prometheus.NewSummaryVec(
-			prometheus.SummaryOpts{
+		clientRequestDurationSecondsMetric = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
 				Namespace:   namespace,
 				Subsystem:   "http_start_stop",
 				Name:        "client_request_duration_seconds",
-				Help:        "Summary of Cloud Foundry Firehose http start stop client request duration in seconds.",
+				Help:        "Histogram of Cloud Foundry Firehose http start stop client request duration in seconds.",
+				Buckets:     durationBuckets,
 				ConstLabels: prometheus.Labels{"environment": environment},
 			},
-			[]string{"bosh_deployment", "application_id", "instance_id", "method", "scheme", "host"},
+			[]string{"bosh_deployment", "application_id", "application_name", "space_name", "organization_name", "instance_id", "method", "scheme", "host", "route"},
 		)
 
 		clientRequestDurationSecondsMetric.WithLabelValues(
 			boshDeployment,
 			httpStartStopApplicationId,
+			httpStartStopApplicationName,
+			httpStartStopSpaceName,
+			httpStartStopOrganizationName,
 			httpStartStopInstanceId,
 			httpStartStopMethod,
 			httpStartStopScheme,
 			httpStartStopHost,
-		).Observe(httpStartStopClientDuration)
+			httpStartStopRoute,
+		).(prometheus.ExemplarObserver).ObserveWithExemplar(httpStartStopClientDuration, prometheus.Labels{"request_id": httpStartStopRequestId})
 
-		serverRequestDurationSecondsMetric = prometheus.NewSummaryVec(
-			prometheus.SummaryOpts{
+		serverRequestDurationSecondsMetric = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
 				Namespace:   namespace,
 				Subsystem:   "http_start_stop",
 				Name:        "server_request_duration_seconds",
-				Help:        "Summary of Cloud Foundry Firehose http start stop server request duration in seconds.",
+				Help:        "Histogram of Cloud Foundry Firehose http start stop server request duration in seconds.",
+				Buckets:     durationBuckets,
 				ConstLabels: prometheus.Labels{"environment": environment},
 			},
-			[]string{"bosh_deployment", "application_id", "instance_id", "method", "scheme", "host"},
+			[]string{"bosh_deployment", "application_id", "application_name", "space_name", "organization_name", "instance_id", "method", "scheme", "host", "route"},
 		)
 
 		serverRequestDurationSecondsMetric.WithLabelValues(
 			boshDeployment,
 			httpStartStopApplicationId,
+			httpStartStopApplicationName,
+			httpStartStopSpaceName,
+			httpStartStopOrganizationName,
+			httpStartStopInstanceId,
+			httpStartStopMethod,
+			httpStartStopScheme,
+			httpStartStopHost,
+			httpStartStopRoute,
+		).(prometheus.ExemplarObserver).ObserveWithExemplar(httpStartStopServerDuration, prometheus.Labels{"request_id": httpStartStopRequestId})
+
+		routerOverheadSecondsMetric = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace:   namespace,
+				Subsystem:   "http_start_stop",
+				Name:        "router_overhead_seconds",
+				Help:        "Histogram of Cloud Foundry Firehose http start stop router overhead (client request duration minus server request duration) in seconds.",
+				Buckets:     durationBuckets,
+				ConstLabels: prometheus.Labels{"environment": environment},
+			},
+			[]string{"bosh_deployment", "application_id", "application_name", "space_name", "organization_name", "instance_id", "method", "scheme", "host", "route"},
+		)
+
+		routerOverheadSecondsMetric.WithLabelValues(
+			boshDeployment,
+			httpStartStopApplicationId,
+			httpStartStopApplicationName,
+			httpStartStopSpaceName,
+			httpStartStopOrganizationName,
 			httpStartStopInstanceId,
 			httpStartStopMethod,
 			httpStartStopScheme,
 			httpStartStopHost,
-		).Observe(httpStartStopServerDuration)
+			httpStartStopRoute,
+		).(prometheus.ExemplarObserver).ObserveWithExemplar(httpStartStopRouterOverhead, prometheus.Labels{"request_id": httpStartStopRequestId})
 	})
 
 	JustBeforeEach(func() {
-		httpStartStopCollector = NewHttpStartStopCollector(namespace, environment, metricsStore)
+		var err error
+		httpStartStopCollector, err = NewHttpStartStopCollector(namespace, environment, metricsStore, durationBuckets, responseSizeBuckets, seriesExpiration, routeMatcher, applications, dropLabels, statusCodeClasses, tagPolicy, topApplicationsCount, topApplicationsWindow)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	Describe("NewHttpStartStopCollector", func() {
+		It("returns an error when a label can not be dropped", func() {
+			_, err := NewHttpStartStopCollector(namespace, environment, metricsStore, durationBuckets, responseSizeBuckets, seriesExpiration, routeMatcher, applications, []string{"application_id"}, statusCodeClasses, tagPolicy, topApplicationsCount, topApplicationsWindow)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error when the top applications window is not positive", func() {
+			_, err := NewHttpStartStopCollector(namespace, environment, metricsStore, durationBuckets, responseSizeBuckets, seriesExpiration, routeMatcher, applications, dropLabels, statusCodeClasses, tagPolicy, 10, 0)
+			Expect(err).To(HaveOccurred())
+		})
 	})
 
 	Describe("Describe", func() {
@@ -194,10 +293,14 @@ var _ = Describe("HttpStartStopCollector", func() {
 			Eventually(descriptions).Should(Receive(Equal(requestsMetric.WithLabelValues(
 				boshDeployment,
 				httpStartStopApplicationId,
+				httpStartStopApplicationName,
+				httpStartStopSpaceName,
+				httpStartStopOrganizationName,
 				httpStartStopInstanceId,
 				httpStartStopMethod,
 				httpStartStopScheme,
 				httpStartStopHost,
+				httpStartStopRoute,
 				strconv.Itoa(int(httpStartStopStatusCode)),
 			).Desc())))
 		})
@@ -206,21 +309,29 @@ var _ = Describe("HttpStartStopCollector", func() {
 			Eventually(descriptions).Should(Receive(Equal(responseSizeBytesMetric.WithLabelValues(
 				boshDeployment,
 				httpStartStopApplicationId,
+				httpStartStopApplicationName,
+				httpStartStopSpaceName,
+				httpStartStopOrganizationName,
 				httpStartStopInstanceId,
 				httpStartStopMethod,
 				httpStartStopScheme,
 				httpStartStopHost,
-			).(prometheus.Summary).Desc())))
+				httpStartStopRoute,
+			).(prometheus.Histogram).Desc())))
 		})
 
 		It("returns a last_request_timestamp metric description", func() {
 			Eventually(descriptions).Should(Receive(Equal(lastRequestTimestampMetric.WithLabelValues(
 				boshDeployment,
 				httpStartStopApplicationId,
+				httpStartStopApplicationName,
+				httpStartStopSpaceName,
+				httpStartStopOrganizationName,
 				httpStartStopInstanceId,
 				httpStartStopMethod,
 				httpStartStopScheme,
 				httpStartStopHost,
+				httpStartStopRoute,
 			).(prometheus.Gauge).Desc())))
 		})
 
@@ -228,31 +339,310 @@ var _ = Describe("HttpStartStopCollector", func() {
 			Eventually(descriptions).Should(Receive(Equal(clientRequestDurationSecondsMetric.WithLabelValues(
 				boshDeployment,
 				httpStartStopApplicationId,
+				httpStartStopApplicationName,
+				httpStartStopSpaceName,
+				httpStartStopOrganizationName,
 				httpStartStopInstanceId,
 				httpStartStopMethod,
 				httpStartStopScheme,
 				httpStartStopHost,
-			).(prometheus.Summary).Desc())))
+				httpStartStopRoute,
+			).(prometheus.Histogram).Desc())))
 		})
 
 		It("returns a server_request_duration_seconds metric description", func() {
 			Eventually(descriptions).Should(Receive(Equal(serverRequestDurationSecondsMetric.WithLabelValues(
 				boshDeployment,
 				httpStartStopApplicationId,
+				httpStartStopApplicationName,
+				httpStartStopSpaceName,
+				httpStartStopOrganizationName,
+				httpStartStopInstanceId,
+				httpStartStopMethod,
+				httpStartStopScheme,
+				httpStartStopHost,
+				httpStartStopRoute,
+			).(prometheus.Histogram).Desc())))
+		})
+
+		It("returns a router_overhead_seconds metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(routerOverheadSecondsMetric.WithLabelValues(
+				boshDeployment,
+				httpStartStopApplicationId,
+				httpStartStopApplicationName,
+				httpStartStopSpaceName,
+				httpStartStopOrganizationName,
 				httpStartStopInstanceId,
 				httpStartStopMethod,
 				httpStartStopScheme,
 				httpStartStopHost,
-			).(prometheus.Summary).Desc())))
+				httpStartStopRoute,
+			).(prometheus.Histogram).Desc())))
+		})
+	})
+
+	Describe("ObserveHttpStartStop", func() {
+		var (
+			httpStartStopMetricsChan chan prometheus.Metric
+		)
+
+		Context("when the server peer was received before the client peer", func() {
+			JustBeforeEach(func() {
+				httpStartStopCollector.ObserveHttpStartStop(
+					metrics.HttpStartStop{
+						Deployment:           boshDeployment,
+						RequestId:            httpStartStopRequestId,
+						Method:               httpStartStopMethod,
+						Uri:                  httpStartStopUri,
+						StatusCode:           httpStartStopStatusCode,
+						ContentLength:        httpStartStopContentLength,
+						ApplicationId:        httpStartStopApplicationId,
+						InstanceId:           httpStartStopInstanceId,
+						ClientStartTimestamp: httpStartStopClientStartTimestamp,
+						ClientStopTimestamp:  httpStartStopClientStopTimestamp,
+						ServerStartTimestamp: httpStartStopServerStartTimestamp,
+						ServerStopTimestamp:  httpStartStopServerStopTimestamp,
+					},
+					events.PeerType_Client,
+				)
+
+				httpStartStopMetricsChan = make(chan prometheus.Metric)
+				go httpStartStopCollector.Collect(httpStartStopMetricsChan)
+			})
+
+			It("returns a server_request_duration_seconds metric", func() {
+				Eventually(httpStartStopMetricsChan).Should(Receive(PrometheusMetric(serverRequestDurationSecondsMetric.WithLabelValues(
+					boshDeployment,
+					httpStartStopApplicationId,
+					httpStartStopApplicationName,
+					httpStartStopSpaceName,
+					httpStartStopOrganizationName,
+					httpStartStopInstanceId,
+					httpStartStopMethod,
+					httpStartStopScheme,
+					httpStartStopHost,
+					httpStartStopRoute,
+				).(prometheus.Histogram))))
+			})
+
+			It("returns a router_overhead_seconds metric", func() {
+				Eventually(httpStartStopMetricsChan).Should(Receive(PrometheusMetric(routerOverheadSecondsMetric.WithLabelValues(
+					boshDeployment,
+					httpStartStopApplicationId,
+					httpStartStopApplicationName,
+					httpStartStopSpaceName,
+					httpStartStopOrganizationName,
+					httpStartStopInstanceId,
+					httpStartStopMethod,
+					httpStartStopScheme,
+					httpStartStopHost,
+					httpStartStopRoute,
+				).(prometheus.Histogram))))
+			})
+		})
+	})
+
+	Describe("application names", func() {
+		observe := func() {
+			httpStartStopCollector.ObserveHttpStartStop(
+				metrics.HttpStartStop{
+					Deployment:           boshDeployment,
+					Method:               httpStartStopMethod,
+					Uri:                  httpStartStopUri,
+					StatusCode:           httpStartStopStatusCode,
+					ApplicationId:        httpStartStopApplicationId,
+					InstanceId:           httpStartStopInstanceId,
+					ClientStartTimestamp: httpStartStopClientStartTimestamp,
+					ClientStopTimestamp:  httpStartStopClientStopTimestamp,
+				},
+				events.PeerType_Client,
+			)
+		}
+
+		Context("when the application is renamed", func() {
+			var renamedRequestsMetric prometheus.Counter
+
+			JustBeforeEach(func() {
+				observe()
+				applications.Set(map[string]cloudcontroller.Application{
+					httpStartStopApplicationId: {
+						Name:             "fake-app-renamed",
+						SpaceName:        httpStartStopSpaceName,
+						OrganizationName: httpStartStopOrganizationName,
+					},
+				})
+				observe()
+
+				renamedRequestsMetric = requestsMetric.WithLabelValues(
+					boshDeployment,
+					httpStartStopApplicationId,
+					"fake-app-renamed",
+					httpStartStopSpaceName,
+					httpStartStopOrganizationName,
+					httpStartStopInstanceId,
+					httpStartStopMethod,
+					httpStartStopScheme,
+					httpStartStopHost,
+					httpStartStopRoute,
+					strconv.Itoa(int(httpStartStopStatusCode)),
+				)
+				renamedRequestsMetric.Add(2)
+			})
+
+			It("keeps counting the requests in a single series with the new name", func() {
+				httpStartStopMetricsChan := make(chan prometheus.Metric, 100)
+				httpStartStopCollector.Collect(httpStartStopMetricsChan)
+				close(httpStartStopMetricsChan)
+
+				var requestsMetrics []prometheus.Metric
+				for metric := range httpStartStopMetricsChan {
+					if metric.Desc().String() == renamedRequestsMetric.Desc().String() {
+						requestsMetrics = append(requestsMetrics, metric)
+					}
+				}
+				Expect(requestsMetrics).To(HaveLen(1))
+				Expect(requestsMetrics[0]).To(PrometheusMetric(renamedRequestsMetric))
+			})
+		})
+	})
+
+	Describe("top applications", func() {
+		var (
+			otherApplicationId       = "a9e3ac11-4d4c-4b1f-9b6f-2f0b5ed9a0d4"
+			failingApplicationId     = "5b8f6d24-7d0e-4c47-a7a5-98e6b1e2c3f0"
+			httpStartStopMetricsChan chan prometheus.Metric
+			observe                  func(applicationId string, statusCode int32)
+			requests                 func(applicationId string, instanceId string, host string, route string, statusCode string) prometheus.Metric
+		)
+
+		BeforeEach(func() {
+			topApplicationsCount = 1
+
+			observe = func(applicationId string, statusCode int32) {
+				httpStartStopCollector.ObserveHttpStartStop(
+					metrics.HttpStartStop{
+						Deployment:           boshDeployment,
+						Method:               httpStartStopMethod,
+						Uri:                  httpStartStopUri,
+						StatusCode:           statusCode,
+						ApplicationId:        applicationId,
+						InstanceId:           httpStartStopInstanceId,
+						ClientStartTimestamp: httpStartStopClientStartTimestamp,
+						ClientStopTimestamp:  httpStartStopClientStopTimestamp,
+					},
+					events.PeerType_Client,
+				)
+			}
+
+			requests = func(applicationId string, instanceId string, host string, route string, statusCode string) prometheus.Metric {
+				metric := prometheus.NewCounterVec(
+					prometheus.CounterOpts{
+						Namespace:   namespace,
+						Subsystem:   "http_start_stop",
+						Name:        "requests_total",
+						Help:        "Total number of Cloud Foundry Firehose http start stop requests.",
+						ConstLabels: prometheus.Labels{"environment": environment},
+					},
+					[]string{"bosh_deployment", "application_id", "application_name", "space_name", "organization_name", "instance_id", "method", "scheme", "host", "route", "status_code"},
+				).WithLabelValues(boshDeployment, applicationId, "", "", "", instanceId, httpStartStopMethod, httpStartStopScheme, host, route, statusCode)
+				metric.Inc()
+				return metric
+			}
+
+			httpStartStopMetricsChan = make(chan prometheus.Metric)
+		})
+
+		Context("when the top applications are not full", func() {
+			JustBeforeEach(func() {
+				observe(otherApplicationId, 200)
+				go httpStartStopCollector.Collect(httpStartStopMetricsChan)
+			})
+
+			It("keeps the full detail of the applications", func() {
+				Eventually(httpStartStopMetricsChan).Should(Receive(PrometheusMetric(requests(otherApplicationId, httpStartStopInstanceId, httpStartStopHost, httpStartStopRoute, "200"))))
+			})
+		})
+
+		Context("when the top applications are full", func() {
+			JustBeforeEach(func() {
+				observe(httpStartStopApplicationId, 200)
+				observe(otherApplicationId, 200)
+				observe(failingApplicationId, 503)
+				go httpStartStopCollector.Collect(httpStartStopMetricsChan)
+			})
+
+			It("folds the requests of the other applications into the other application", func() {
+				Eventually(httpStartStopMetricsChan).Should(Receive(PrometheusMetric(requests("other", "", "", "", "200"))))
+			})
+
+			It("keeps the full detail of the top application by requests", func() {
+				Eventually(httpStartStopMetricsChan).Should(Receive(PrometheusMetric(requestsMetric.WithLabelValues(
+					boshDeployment,
+					httpStartStopApplicationId,
+					httpStartStopApplicationName,
+					httpStartStopSpaceName,
+					httpStartStopOrganizationName,
+					httpStartStopInstanceId,
+					httpStartStopMethod,
+					httpStartStopScheme,
+					httpStartStopHost,
+					httpStartStopRoute,
+					strconv.Itoa(int(httpStartStopStatusCode)),
+				))))
+			})
+
+			It("keeps the full detail of the top application by errors", func() {
+				Eventually(httpStartStopMetricsChan).Should(Receive(PrometheusMetric(requests(failingApplicationId, httpStartStopInstanceId, httpStartStopHost, httpStartStopRoute, "503"))))
+			})
+		})
+
+		Context("when the window slides", func() {
+			BeforeEach(func() {
+				topApplicationsWindow = 100 * time.Millisecond
+			})
+
+			JustBeforeEach(func() {
+				observe(httpStartStopApplicationId, 200)
+				for i := 0; i < 3; i++ {
+					observe(otherApplicationId, 200)
+				}
+				time.Sleep(20 * time.Millisecond)
+				observe(otherApplicationId, 200)
+				go httpStartStopCollector.Collect(httpStartStopMetricsChan)
+			})
+
+			It("keeps the full detail of the busiest application", func() {
+				Eventually(httpStartStopMetricsChan).Should(Receive(PrometheusMetric(requests(otherApplicationId, httpStartStopInstanceId, httpStartStopHost, httpStartStopRoute, "200"))))
+			})
+
+			It("deletes the series of the application that left the top", func() {
+				metricsChan := make(chan prometheus.Metric, 100)
+				httpStartStopCollector.Collect(metricsChan)
+				close(metricsChan)
+
+				for metric := range metricsChan {
+					written := &dto.Metric{}
+					Expect(metric.Write(written)).To(Succeed())
+					for _, label := range written.Label {
+						Expect(label.GetValue()).ToNot(Equal(httpStartStopApplicationId))
+					}
+				}
+			})
+
 		})
 	})
 
 	Describe("Collect", func() {
 		var (
 			httpStartStopMetricsChan chan prometheus.Metric
+			httpStartStopTags        map[string]string
 		)
 
 		BeforeEach(func() {
+			httpStartStopTags = map[string]string{}
+		})
+
+		JustBeforeEach(func() {
 			metricsStore.AddMetric(
 				&events.Envelope{
 					Origin:     proto.String(origin),
@@ -262,7 +652,7 @@ var _ = Describe("HttpStartStopCollector", func() {
 					Job:        proto.String(boshJob),
 					Index:      proto.String(boshIndex),
 					Ip:         proto.String(boshIP),
-					Tags:       map[string]string{},
+					Tags:       httpStartStopTags,
 					HttpStartStop: &events.HttpStartStop{
 						StartTimestamp: proto.Int64(httpStartStopClientStartTimestamp),
 						StopTimestamp:  proto.Int64(httpStartStopClientStopTimestamp),
@@ -290,7 +680,7 @@ var _ = Describe("HttpStartStopCollector", func() {
 					Job:        proto.String(boshJob),
 					Index:      proto.String(boshIndex),
 					Ip:         proto.String(boshIP),
-					Tags:       map[string]string{},
+					Tags:       httpStartStopTags,
 					HttpStartStop: &events.HttpStartStop{
 						StartTimestamp: proto.Int64(httpStartStopServerStartTimestamp),
 						StopTimestamp:  proto.Int64(httpStartStopServerStopTimestamp),
@@ -317,10 +707,14 @@ var _ = Describe("HttpStartStopCollector", func() {
 			Eventually(httpStartStopMetricsChan).Should(Receive(PrometheusMetric(requestsMetric.WithLabelValues(
 				boshDeployment,
 				httpStartStopApplicationId,
+				httpStartStopApplicationName,
+				httpStartStopSpaceName,
+				httpStartStopOrganizationName,
 				httpStartStopInstanceId,
 				httpStartStopMethod,
 				httpStartStopScheme,
 				httpStartStopHost,
+				httpStartStopRoute,
 				strconv.Itoa(int(httpStartStopStatusCode)),
 			))))
 		})
@@ -329,21 +723,29 @@ var _ = Describe("HttpStartStopCollector", func() {
 			Eventually(httpStartStopMetricsChan).Should(Receive(PrometheusMetric(responseSizeBytesMetric.WithLabelValues(
 				boshDeployment,
 				httpStartStopApplicationId,
+				httpStartStopApplicationName,
+				httpStartStopSpaceName,
+				httpStartStopOrganizationName,
 				httpStartStopInstanceId,
 				httpStartStopMethod,
 				httpStartStopScheme,
 				httpStartStopHost,
-			).(prometheus.Summary))))
+				httpStartStopRoute,
+			).(prometheus.Histogram))))
 		})
 
 		It("returns a last_request_timestamp metric", func() {
 			Eventually(httpStartStopMetricsChan).Should(Receive(PrometheusMetric(lastRequestTimestampMetric.WithLabelValues(
 				boshDeployment,
 				httpStartStopApplicationId,
+				httpStartStopApplicationName,
+				httpStartStopSpaceName,
+				httpStartStopOrganizationName,
 				httpStartStopInstanceId,
 				httpStartStopMethod,
 				httpStartStopScheme,
 				httpStartStopHost,
+				httpStartStopRoute,
 			).(prometheus.Gauge))))
 		})
 
@@ -351,27 +753,183 @@ var _ = Describe("HttpStartStopCollector", func() {
 			Eventually(httpStartStopMetricsChan).Should(Receive(PrometheusMetric(clientRequestDurationSecondsMetric.WithLabelValues(
 				boshDeployment,
 				httpStartStopApplicationId,
+				httpStartStopApplicationName,
+				httpStartStopSpaceName,
+				httpStartStopOrganizationName,
 				httpStartStopInstanceId,
 				httpStartStopMethod,
 				httpStartStopScheme,
 				httpStartStopHost,
-			).(prometheus.Summary))))
+				httpStartStopRoute,
+			).(prometheus.Histogram))))
 		})
 
 		It("returns a server_request_duration_seconds metric", func() {
 			Eventually(httpStartStopMetricsChan).Should(Receive(PrometheusMetric(serverRequestDurationSecondsMetric.WithLabelValues(
 				boshDeployment,
 				httpStartStopApplicationId,
+				httpStartStopApplicationName,
+				httpStartStopSpaceName,
+				httpStartStopOrganizationName,
 				httpStartStopInstanceId,
 				httpStartStopMethod,
 				httpStartStopScheme,
 				httpStartStopHost,
-			).(prometheus.Summary))))
+				httpStartStopRoute,
+			).(prometheus.Histogram))))
 		})
 
-		Context("when there is no http start stop metrics", func() {
+		It("returns a router_overhead_seconds metric", func() {
+			Eventually(httpStartStopMetricsChan).Should(Receive(PrometheusMetric(routerOverheadSecondsMetric.WithLabelValues(
+				boshDeployment,
+				httpStartStopApplicationId,
+				httpStartStopApplicationName,
+				httpStartStopSpaceName,
+				httpStartStopOrganizationName,
+				httpStartStopInstanceId,
+				httpStartStopMethod,
+				httpStartStopScheme,
+				httpStartStopHost,
+				httpStartStopRoute,
+			).(prometheus.Histogram))))
+		})
+
+		Context("when the request has a B3 trace id", func() {
+			var httpStartStopTraceId = "463ac35c9f6413ad48485a3953bb6124"
+
 			BeforeEach(func() {
+				httpStartStopTags = map[string]string{"b3_trace_id": httpStartStopTraceId}
+			})
+
+			It("returns a client_request_duration_seconds metric with a trace_id exemplar", func() {
+				tracedClientRequestDurationSecondsMetric := prometheus.NewHistogramVec(
+					prometheus.HistogramOpts{
+						Namespace:   namespace,
+						Subsystem:   "http_start_stop",
+						Name:        "client_request_duration_seconds",
+						Help:        "Histogram of Cloud Foundry Firehose http start stop client request duration in seconds.",
+						Buckets:     durationBuckets,
+						ConstLabels: prometheus.Labels{"environment": environment},
+					},
+					[]string{"bosh_deployment", "application_id", "application_name", "space_name", "organization_name", "instance_id", "method", "scheme", "host", "route"},
+				)
+				tracedClientRequestDuration := tracedClientRequestDurationSecondsMetric.WithLabelValues(
+					boshDeployment,
+					httpStartStopApplicationId,
+					httpStartStopApplicationName,
+					httpStartStopSpaceName,
+					httpStartStopOrganizationName,
+					httpStartStopInstanceId,
+					httpStartStopMethod,
+					httpStartStopScheme,
+					httpStartStopHost,
+					httpStartStopRoute,
+				)
+				tracedClientRequestDuration.(prometheus.ExemplarObserver).ObserveWithExemplar(httpStartStopClientDuration, prometheus.Labels{"trace_id": httpStartStopTraceId})
+
+				Eventually(httpStartStopMetricsChan).Should(Receive(PrometheusMetric(tracedClientRequestDuration.(prometheus.Histogram))))
+			})
+		})
+
+		Context("when the http start stop events are no longer cached", func() {
+			It("keeps returning the requests metric", func() {
 				metricsStore.FlushHttpStartStops()
+
+				metricsChan := make(chan prometheus.Metric)
+				go httpStartStopCollector.Collect(metricsChan)
+				Eventually(metricsChan).Should(Receive(PrometheusMetric(requestsMetric.WithLabelValues(
+					boshDeployment,
+					httpStartStopApplicationId,
+					httpStartStopApplicationName,
+					httpStartStopSpaceName,
+					httpStartStopOrganizationName,
+					httpStartStopInstanceId,
+					httpStartStopMethod,
+					httpStartStopScheme,
+					httpStartStopHost,
+					httpStartStopRoute,
+					strconv.Itoa(int(httpStartStopStatusCode)),
+				))))
+			})
+		})
+
+		Context("when labels are dropped and status codes are collapsed into classes", func() {
+			BeforeEach(func() {
+				dropLabels = []string{"instance_id", "host"}
+				statusCodeClasses = true
+			})
+
+			It("returns an aggregated requests metric", func() {
+				aggregatedRequestsMetric := prometheus.NewCounterVec(
+					prometheus.CounterOpts{
+						Namespace:   namespace,
+						Subsystem:   "http_start_stop",
+						Name:        "requests_total",
+						Help:        "Total number of Cloud Foundry Firehose http start stop requests.",
+						ConstLabels: prometheus.Labels{"environment": environment},
+					},
+					[]string{"bosh_deployment", "application_id", "application_name", "space_name", "organization_name", "method", "scheme", "route", "status_code"},
+				)
+				aggregatedRequestsMetric.WithLabelValues(
+					boshDeployment,
+					httpStartStopApplicationId,
+					httpStartStopApplicationName,
+					httpStartStopSpaceName,
+					httpStartStopOrganizationName,
+					httpStartStopMethod,
+					httpStartStopScheme,
+					httpStartStopRoute,
+					"2xx",
+				).Inc()
+
+				Eventually(httpStartStopMetricsChan).Should(Receive(PrometheusMetric(aggregatedRequestsMetric.WithLabelValues(
+					boshDeployment,
+					httpStartStopApplicationId,
+					httpStartStopApplicationName,
+					httpStartStopSpaceName,
+					httpStartStopOrganizationName,
+					httpStartStopMethod,
+					httpStartStopScheme,
+					httpStartStopRoute,
+					"2xx",
+				))))
+			})
+		})
+
+		Context("when the application name labels are dropped", func() {
+			BeforeEach(func() {
+				dropLabels = []string{"application_name", "space_name", "organization_name"}
+			})
+
+			It("returns a requests metric without the names", func() {
+				unnamedRequestsMetric := prometheus.NewCounterVec(
+					prometheus.CounterOpts{
+						Namespace:   namespace,
+						Subsystem:   "http_start_stop",
+						Name:        "requests_total",
+						Help:        "Total number of Cloud Foundry Firehose http start stop requests.",
+						ConstLabels: prometheus.Labels{"environment": environment},
+					},
+					[]string{"bosh_deployment", "application_id", "instance_id", "method", "scheme", "host", "route", "status_code"},
+				).WithLabelValues(
+					boshDeployment,
+					httpStartStopApplicationId,
+					httpStartStopInstanceId,
+					httpStartStopMethod,
+					httpStartStopScheme,
+					httpStartStopHost,
+					httpStartStopRoute,
+					strconv.Itoa(int(httpStartStopStatusCode)),
+				)
+				unnamedRequestsMetric.Inc()
+
+				Eventually(httpStartStopMetricsChan).Should(Receive(PrometheusMetric(unnamedRequestsMetric)))
+			})
+		})
+
+		Context("when the application series have expired", func() {
+			BeforeEach(func() {
+				seriesExpiration = 0
 			})
 
 			It("does not return any metric", func() {
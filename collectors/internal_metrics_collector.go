@@ -1,6 +1,7 @@
 package collectors
 
 import (
+	"github.com/cloudfoundry/sonde-go/events"
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/bosh-prometheus/firehose_exporter/metrics"
@@ -26,12 +27,20 @@ type InternalMetricsCollector struct {
 	totalHttpStartStopProcessedMetric          prometheus.Gauge
 	httpStartStopCachedMetric                  prometheus.Gauge
 	lastHttpStartStopReceivedTimestampMetric   prometheus.Gauge
+	totalHttpStartStopMatchedMetric            prometheus.Gauge
+	totalHttpStartStopUnmatchedClientMetric    prometheus.Gauge
+	totalHttpStartStopUnmatchedServerMetric    prometheus.Gauge
+	totalHttpStartStopUnknownPeerMetric        prometheus.Gauge
 	totalValueMetricsReceivedMetric            prometheus.Gauge
 	totalValueMetricsProcessedMetric           prometheus.Gauge
 	valueMetricsCachedMetric                   prometheus.Gauge
 	lastValueMetricReceivedTimestampMetric     prometheus.Gauge
 	slowConsumerAlertMetric                    prometheus.Gauge
 	lastSlowConsumerAlertTimestampMetric       prometheus.Gauge
+	totalDuplicateEnvelopesSuppressedMetric    prometheus.Gauge
+	totalStaleEnvelopesDiscardedMetric         prometheus.Gauge
+	envelopesIngestedDesc                      *prometheus.Desc
+	tagLabelCollisionsDesc                     *prometheus.Desc
 }
 
 func NewInternalMetricsCollector(
@@ -199,6 +208,46 @@ func NewInternalMetricsCollector(
 		},
 	)
 
+	totalHttpStartStopMatchedMetric := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "total_http_start_stop_matched",
+			Help:        "Total number of http start stop requests from Cloud Foundry Firehose whose client and server peers were matched.",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+	)
+
+	totalHttpStartStopUnmatchedClientMetric := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "total_http_start_stop_unmatched_client",
+			Help:        "Total number of http start stop client peers from Cloud Foundry Firehose flushed or expired without their server peer.",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+	)
+
+	totalHttpStartStopUnmatchedServerMetric := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "total_http_start_stop_unmatched_server",
+			Help:        "Total number of http start stop server peers from Cloud Foundry Firehose flushed or expired without their client peer.",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+	)
+
+	totalHttpStartStopUnknownPeerMetric := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "total_http_start_stop_unknown_peer",
+			Help:        "Total number of http start stop with an unknown peer type received from Cloud Foundry Firehose and discarded.",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+	)
+
 	totalValueMetricsReceivedMetric := prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Namespace:   namespace,
@@ -259,6 +308,40 @@ func NewInternalMetricsCollector(
 		},
 	)
 
+	totalDuplicateEnvelopesSuppressedMetric := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "total_duplicate_envelopes_suppressed",
+			Help:        "Total number of duplicate envelopes received from Cloud Foundry Firehose and suppressed.",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+	)
+
+	totalStaleEnvelopesDiscardedMetric := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "",
+			Name:        "total_stale_envelopes_discarded",
+			Help:        "Total number of out-of-order envelopes received from Cloud Foundry Firehose and discarded.",
+			ConstLabels: prometheus.Labels{"environment": environment},
+		},
+	)
+
+	envelopesIngestedDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "envelopes_ingested_total"),
+		"Total number of envelopes ingested from Cloud Foundry Firehose by origin, event type and outcome.",
+		[]string{"origin", "event_type", "outcome"},
+		prometheus.Labels{"environment": environment},
+	)
+
+	tagLabelCollisionsDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "tag_label_collisions_total"),
+		"Total number of Cloud Foundry Firehose metrics collected whose tags collided with a built-in label by event type.",
+		[]string{"event_type"},
+		prometheus.Labels{"environment": environment},
+	)
+
 	collector := &InternalMetricsCollector{
 		namespace:                                  namespace,
 		environment:                                environment,
@@ -279,12 +362,20 @@ func NewInternalMetricsCollector(
 		totalHttpStartStopProcessedMetric:          totalHttpStartStopProcessedMetric,
 		httpStartStopCachedMetric:                  httpStartStopCachedMetric,
 		lastHttpStartStopReceivedTimestampMetric:   lastHttpStartStopReceivedTimestampMetric,
+		totalHttpStartStopMatchedMetric:            totalHttpStartStopMatchedMetric,
+		totalHttpStartStopUnmatchedClientMetric:    totalHttpStartStopUnmatchedClientMetric,
+		totalHttpStartStopUnmatchedServerMetric:    totalHttpStartStopUnmatchedServerMetric,
+		totalHttpStartStopUnknownPeerMetric:        totalHttpStartStopUnknownPeerMetric,
 		totalValueMetricsReceivedMetric:            totalValueMetricsReceivedMetric,
 		totalValueMetricsProcessedMetric:           totalValueMetricsProcessedMetric,
 		valueMetricsCachedMetric:                   valueMetricsCachedMetric,
 		lastValueMetricReceivedTimestampMetric:     lastValueMetricReceivedTimestampMetric,
 		slowConsumerAlertMetric:                    slowConsumerAlertMetric,
 		lastSlowConsumerAlertTimestampMetric:       lastSlowConsumerAlertTimestampMetric,
+		totalDuplicateEnvelopesSuppressedMetric:    totalDuplicateEnvelopesSuppressedMetric,
+		totalStaleEnvelopesDiscardedMetric:         totalStaleEnvelopesDiscardedMetric,
+		envelopesIngestedDesc:                      envelopesIngestedDesc,
+		tagLabelCollisionsDesc:                     tagLabelCollisionsDesc,
 	}
 	return collector
 }
@@ -340,6 +431,18 @@ func (c InternalMetricsCollector) Collect(ch chan<- prometheus.Metric) {
 	c.lastHttpStartStopReceivedTimestampMetric.Set(float64(internalMetrics.LastHttpStartStopReceivedTimestamp))
 	c.lastHttpStartStopReceivedTimestampMetric.Collect(ch)
 
+	c.totalHttpStartStopMatchedMetric.Set(float64(internalMetrics.TotalHttpStartStopMatched))
+	c.totalHttpStartStopMatchedMetric.Collect(ch)
+
+	c.totalHttpStartStopUnmatchedClientMetric.Set(float64(internalMetrics.TotalHttpStartStopUnmatchedClient))
+	c.totalHttpStartStopUnmatchedClientMetric.Collect(ch)
+
+	c.totalHttpStartStopUnmatchedServerMetric.Set(float64(internalMetrics.TotalHttpStartStopUnmatchedServer))
+	c.totalHttpStartStopUnmatchedServerMetric.Collect(ch)
+
+	c.totalHttpStartStopUnknownPeerMetric.Set(float64(internalMetrics.TotalHttpStartStopUnknownPeer))
+	c.totalHttpStartStopUnknownPeerMetric.Collect(ch)
+
 	c.totalValueMetricsReceivedMetric.Set(float64(internalMetrics.TotalValueMetricsReceived))
 	c.totalValueMetricsReceivedMetric.Collect(ch)
 
@@ -360,6 +463,36 @@ func (c InternalMetricsCollector) Collect(ch chan<- prometheus.Metric) {
 
 	c.lastSlowConsumerAlertTimestampMetric.Set(float64(internalMetrics.LastSlowConsumerAlertTimestamp))
 	c.lastSlowConsumerAlertTimestampMetric.Collect(ch)
+
+	c.totalDuplicateEnvelopesSuppressedMetric.Set(float64(internalMetrics.TotalDuplicateEnvelopesSuppressed))
+	c.totalDuplicateEnvelopesSuppressedMetric.Collect(ch)
+
+	c.totalStaleEnvelopesDiscardedMetric.Set(float64(internalMetrics.TotalStaleEnvelopesDiscarded))
+	c.totalStaleEnvelopesDiscardedMetric.Collect(ch)
+
+	ch <- prometheus.MustNewConstMetric(
+		c.tagLabelCollisionsDesc,
+		prometheus.CounterValue,
+		float64(internalMetrics.TotalCounterEventsTagLabelCollisions),
+		events.Envelope_CounterEvent.String(),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.tagLabelCollisionsDesc,
+		prometheus.CounterValue,
+		float64(internalMetrics.TotalValueMetricsTagLabelCollisions),
+		events.Envelope_ValueMetric.String(),
+	)
+
+	for key, value := range c.metricsStore.GetIngestionStats() {
+		ch <- prometheus.MustNewConstMetric(
+			c.envelopesIngestedDesc,
+			prometheus.CounterValue,
+			float64(value),
+			key.Origin,
+			key.EventType,
+			key.Outcome,
+		)
+	}
 }
 
 func (c InternalMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
@@ -379,10 +512,18 @@ func (c InternalMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
 	c.totalHttpStartStopProcessedMetric.Describe(ch)
 	c.httpStartStopCachedMetric.Describe(ch)
 	c.lastHttpStartStopReceivedTimestampMetric.Describe(ch)
+	c.totalHttpStartStopMatchedMetric.Describe(ch)
+	c.totalHttpStartStopUnmatchedClientMetric.Describe(ch)
+	c.totalHttpStartStopUnmatchedServerMetric.Describe(ch)
+	c.totalHttpStartStopUnknownPeerMetric.Describe(ch)
 	c.totalValueMetricsReceivedMetric.Describe(ch)
 	c.totalValueMetricsProcessedMetric.Describe(ch)
 	c.valueMetricsCachedMetric.Describe(ch)
 	c.lastValueMetricReceivedTimestampMetric.Describe(ch)
 	c.slowConsumerAlertMetric.Describe(ch)
 	c.lastSlowConsumerAlertTimestampMetric.Describe(ch)
+	c.totalDuplicateEnvelopesSuppressedMetric.Describe(ch)
+	c.totalStaleEnvelopesDiscardedMetric.Describe(ch)
+	ch <- c.envelopesIngestedDesc
+	ch <- c.tagLabelCollisionsDesc
 }
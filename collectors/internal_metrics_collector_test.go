@@ -8,6 +8,8 @@ import (
 
 	"github.com/bosh-prometheus/firehose_exporter/filters"
 	"github.com/bosh-prometheus/firehose_exporter/metrics"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
 	"github.com/prometheus/client_golang/prometheus"
 
 	. "github.com/bosh-prometheus/firehose_exporter/collectors"
@@ -41,12 +43,20 @@ var _ = Describe("InternalMetricsCollector", func() {
 		totalHttpStartStopProcessedMetric          prometheus.Gauge
 		httpStartStopCachedMetric                  prometheus.Gauge
 		lastHttpStartStopReceivedTimestampMetric   prometheus.Gauge
+		totalHttpStartStopMatchedMetric            prometheus.Gauge
+		totalHttpStartStopUnmatchedClientMetric    prometheus.Gauge
+		totalHttpStartStopUnmatchedServerMetric    prometheus.Gauge
+		totalHttpStartStopUnknownPeerMetric        prometheus.Gauge
 		totalValueMetricsReceivedMetric            prometheus.Gauge
 		totalValueMetricsProcessedMetric           prometheus.Gauge
 		valueMetricsCachedMetric                   prometheus.Gauge
 		lastValueMetricReceivedTimestampMetric     prometheus.Gauge
 		slowConsumerAlertMetric                    prometheus.Gauge
 		lastSlowConsumerAlertTimestampMetric       prometheus.Gauge
+		totalDuplicateEnvelopesSuppressedMetric    prometheus.Gauge
+		totalStaleEnvelopesDiscardedMetric         prometheus.Gauge
+		envelopesIngestedDesc                      *prometheus.Desc
+		tagLabelCollisionsDesc                     *prometheus.Desc
 	)
 
 	BeforeEach(func() {
@@ -216,6 +226,46 @@ var _ = Describe("InternalMetricsCollector", func() {
 			},
 		)
 
+		totalHttpStartStopMatchedMetric = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   "",
+				Name:        "total_http_start_stop_matched",
+				Help:        "Total number of http start stop requests from Cloud Foundry Firehose whose client and server peers were matched.",
+				ConstLabels: prometheus.Labels{"environment": environment},
+			},
+		)
+
+		totalHttpStartStopUnmatchedClientMetric = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   "",
+				Name:        "total_http_start_stop_unmatched_client",
+				Help:        "Total number of http start stop client peers from Cloud Foundry Firehose flushed or expired without their server peer.",
+				ConstLabels: prometheus.Labels{"environment": environment},
+			},
+		)
+
+		totalHttpStartStopUnmatchedServerMetric = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   "",
+				Name:        "total_http_start_stop_unmatched_server",
+				Help:        "Total number of http start stop server peers from Cloud Foundry Firehose flushed or expired without their client peer.",
+				ConstLabels: prometheus.Labels{"environment": environment},
+			},
+		)
+
+		totalHttpStartStopUnknownPeerMetric = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   "",
+				Name:        "total_http_start_stop_unknown_peer",
+				Help:        "Total number of http start stop with an unknown peer type received from Cloud Foundry Firehose and discarded.",
+				ConstLabels: prometheus.Labels{"environment": environment},
+			},
+		)
+
 		totalValueMetricsReceivedMetric = prometheus.NewGauge(
 			prometheus.GaugeOpts{
 				Namespace:   namespace,
@@ -275,6 +325,40 @@ var _ = Describe("InternalMetricsCollector", func() {
 				ConstLabels: prometheus.Labels{"environment": environment},
 			},
 		)
+
+		totalDuplicateEnvelopesSuppressedMetric = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   "",
+				Name:        "total_duplicate_envelopes_suppressed",
+				Help:        "Total number of duplicate envelopes received from Cloud Foundry Firehose and suppressed.",
+				ConstLabels: prometheus.Labels{"environment": environment},
+			},
+		)
+
+		totalStaleEnvelopesDiscardedMetric = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   "",
+				Name:        "total_stale_envelopes_discarded",
+				Help:        "Total number of out-of-order envelopes received from Cloud Foundry Firehose and discarded.",
+				ConstLabels: prometheus.Labels{"environment": environment},
+			},
+		)
+
+		envelopesIngestedDesc = prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "envelopes_ingested_total"),
+			"Total number of envelopes ingested from Cloud Foundry Firehose by origin, event type and outcome.",
+			[]string{"origin", "event_type", "outcome"},
+			prometheus.Labels{"environment": environment},
+		)
+
+		tagLabelCollisionsDesc = prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "tag_label_collisions_total"),
+			"Total number of Cloud Foundry Firehose metrics collected whose tags collided with a built-in label by event type.",
+			[]string{"event_type"},
+			prometheus.Labels{"environment": environment},
+		)
 	})
 
 	JustBeforeEach(func() {
@@ -358,6 +442,22 @@ var _ = Describe("InternalMetricsCollector", func() {
 			Eventually(descriptions).Should(Receive(Equal(lastHttpStartStopReceivedTimestampMetric.Desc())))
 		})
 
+		It("returns a total_http_start_stop_matched metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(totalHttpStartStopMatchedMetric.Desc())))
+		})
+
+		It("returns a total_http_start_stop_unmatched_client metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(totalHttpStartStopUnmatchedClientMetric.Desc())))
+		})
+
+		It("returns a total_http_start_stop_unmatched_server metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(totalHttpStartStopUnmatchedServerMetric.Desc())))
+		})
+
+		It("returns a total_http_start_stop_unknown_peer metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(totalHttpStartStopUnknownPeerMetric.Desc())))
+		})
+
 		It("returns a total_value_metrics_received metric description", func() {
 			Eventually(descriptions).Should(Receive(Equal(totalValueMetricsReceivedMetric.Desc())))
 		})
@@ -381,6 +481,22 @@ var _ = Describe("InternalMetricsCollector", func() {
 		It("returns a last_slow_consumer_alert_timestamp metric description", func() {
 			Eventually(descriptions).Should(Receive(Equal(lastSlowConsumerAlertTimestampMetric.Desc())))
 		})
+
+		It("returns a total_duplicate_envelopes_suppressed metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(totalDuplicateEnvelopesSuppressedMetric.Desc())))
+		})
+
+		It("returns a total_stale_envelopes_discarded metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(totalStaleEnvelopesDiscardedMetric.Desc())))
+		})
+
+		It("returns a envelopes_ingested_total metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(envelopesIngestedDesc)))
+		})
+
+		It("returns a tag_label_collisions_total metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(tagLabelCollisionsDesc)))
+		})
 	})
 
 	Describe("Collect", func() {
@@ -399,11 +515,19 @@ var _ = Describe("InternalMetricsCollector", func() {
 			totalHttpStartStopReceived           = int64(300)
 			totalHttpStartStopProcessed          = int64(150)
 			lastHttpStartStopReceivedTimestamp   = time.Now().Unix()
+			totalHttpStartStopMatched            = int64(120)
+			totalHttpStartStopUnmatchedClient    = int64(10)
+			totalHttpStartStopUnmatchedServer    = int64(5)
+			totalHttpStartStopUnknownPeer        = int64(2)
 			totalValueMetricsReceived            = int64(400)
 			totalValueMetricsProcessed           = int64(200)
 			lastValueMetricReceivedTimestamp     = time.Now().Unix()
 			slowConsumerAlert                    = false
 			lastSlowConsumerAlertTimestamp       = time.Now().Unix()
+			totalDuplicateEnvelopesSuppressed    = int64(25)
+			totalStaleEnvelopesDiscarded         = int64(35)
+			totalCounterEventsTagLabelCollisions = int64(3)
+			totalValueMetricsTagLabelCollisions  = int64(4)
 
 			internalMetricsChan chan prometheus.Metric
 		)
@@ -423,11 +547,19 @@ var _ = Describe("InternalMetricsCollector", func() {
 				TotalHttpStartStopReceived:           totalHttpStartStopReceived,
 				TotalHttpStartStopProcessed:          totalHttpStartStopProcessed,
 				LastHttpStartStopReceivedTimestamp:   lastHttpStartStopReceivedTimestamp,
+				TotalHttpStartStopMatched:            totalHttpStartStopMatched,
+				TotalHttpStartStopUnmatchedClient:    totalHttpStartStopUnmatchedClient,
+				TotalHttpStartStopUnmatchedServer:    totalHttpStartStopUnmatchedServer,
+				TotalHttpStartStopUnknownPeer:        totalHttpStartStopUnknownPeer,
 				TotalValueMetricsReceived:            totalValueMetricsReceived,
 				TotalValueMetricsProcessed:           totalValueMetricsProcessed,
 				LastValueMetricReceivedTimestamp:     lastValueMetricReceivedTimestamp,
 				SlowConsumerAlert:                    slowConsumerAlert,
 				LastSlowConsumerAlertTimestamp:       lastSlowConsumerAlertTimestamp,
+				TotalDuplicateEnvelopesSuppressed:    totalDuplicateEnvelopesSuppressed,
+				TotalStaleEnvelopesDiscarded:         totalStaleEnvelopesDiscarded,
+				TotalCounterEventsTagLabelCollisions: totalCounterEventsTagLabelCollisions,
+				TotalValueMetricsTagLabelCollisions:  totalValueMetricsTagLabelCollisions,
 			}
 
 			internalMetricsChan = make(chan prometheus.Metric)
@@ -466,6 +598,14 @@ var _ = Describe("InternalMetricsCollector", func() {
 
 			lastHttpStartStopReceivedTimestampMetric.Set(float64(lastHttpStartStopReceivedTimestamp))
 
+			totalHttpStartStopMatchedMetric.Set(float64(totalHttpStartStopMatched))
+
+			totalHttpStartStopUnmatchedClientMetric.Set(float64(totalHttpStartStopUnmatchedClient))
+
+			totalHttpStartStopUnmatchedServerMetric.Set(float64(totalHttpStartStopUnmatchedServer))
+
+			totalHttpStartStopUnknownPeerMetric.Set(float64(totalHttpStartStopUnknownPeer))
+
 			totalValueMetricsReceivedMetric.Set(float64(totalValueMetricsReceived))
 
 			totalValueMetricsProcessedMetric.Set(float64(totalValueMetricsProcessed))
@@ -477,6 +617,10 @@ var _ = Describe("InternalMetricsCollector", func() {
 			slowConsumerAlertMetric.Set(0)
 
 			lastSlowConsumerAlertTimestampMetric.Set(float64(lastSlowConsumerAlertTimestamp))
+
+			totalDuplicateEnvelopesSuppressedMetric.Set(float64(totalDuplicateEnvelopesSuppressed))
+
+			totalStaleEnvelopesDiscardedMetric.Set(float64(totalStaleEnvelopesDiscarded))
 		})
 
 		JustBeforeEach(func() {
@@ -548,6 +692,22 @@ var _ = Describe("InternalMetricsCollector", func() {
 			Eventually(internalMetricsChan).Should(Receive(PrometheusMetric(lastHttpStartStopReceivedTimestampMetric)))
 		})
 
+		It("returns a total_http_start_stop_matched metric", func() {
+			Eventually(internalMetricsChan).Should(Receive(PrometheusMetric(totalHttpStartStopMatchedMetric)))
+		})
+
+		It("returns a total_http_start_stop_unmatched_client metric", func() {
+			Eventually(internalMetricsChan).Should(Receive(PrometheusMetric(totalHttpStartStopUnmatchedClientMetric)))
+		})
+
+		It("returns a total_http_start_stop_unmatched_server metric", func() {
+			Eventually(internalMetricsChan).Should(Receive(PrometheusMetric(totalHttpStartStopUnmatchedServerMetric)))
+		})
+
+		It("returns a total_http_start_stop_unknown_peer metric", func() {
+			Eventually(internalMetricsChan).Should(Receive(PrometheusMetric(totalHttpStartStopUnknownPeerMetric)))
+		})
+
 		It("returns a total_value_metrics_received metric", func() {
 			Eventually(internalMetricsChan).Should(Receive(PrometheusMetric(totalValueMetricsReceivedMetric)))
 		})
@@ -585,5 +745,56 @@ var _ = Describe("InternalMetricsCollector", func() {
 		It("returns a last_slow_consumer_alert_timestamp metric", func() {
 			Eventually(internalMetricsChan).Should(Receive(PrometheusMetric(lastSlowConsumerAlertTimestampMetric)))
 		})
+
+		It("returns a total_duplicate_envelopes_suppressed metric", func() {
+			Eventually(internalMetricsChan).Should(Receive(PrometheusMetric(totalDuplicateEnvelopesSuppressedMetric)))
+		})
+
+		It("returns a total_stale_envelopes_discarded metric", func() {
+			Eventually(internalMetricsChan).Should(Receive(PrometheusMetric(totalStaleEnvelopesDiscardedMetric)))
+		})
+
+		It("returns a tag_label_collisions_total metric for counter events", func() {
+			Eventually(internalMetricsChan).Should(Receive(PrometheusMetric(prometheus.MustNewConstMetric(tagLabelCollisionsDesc, prometheus.CounterValue, float64(totalCounterEventsTagLabelCollisions), "CounterEvent"))))
+		})
+
+		It("returns a tag_label_collisions_total metric for value metrics", func() {
+			Eventually(internalMetricsChan).Should(Receive(PrometheusMetric(prometheus.MustNewConstMetric(tagLabelCollisionsDesc, prometheus.CounterValue, float64(totalValueMetricsTagLabelCollisions), "ValueMetric"))))
+		})
+
+		Context("when envelopes have been ingested", func() {
+			var (
+				envelopesIngestedMetric prometheus.Metric
+			)
+
+			BeforeEach(func() {
+				metricsStore.AddMetric(
+					&events.Envelope{
+						Origin:     proto.String("fake-origin"),
+						EventType:  events.Envelope_ValueMetric.Enum(),
+						Timestamp:  proto.Int64(time.Now().UnixNano()),
+						Deployment: proto.String("fake-deployment-name"),
+						ValueMetric: &events.ValueMetric{
+							Name:  proto.String("FakeValueMetric"),
+							Value: proto.Float64(1),
+							Unit:  proto.String("count"),
+						},
+					},
+				)
+
+				envelopesIngestedMetric = prometheus.MustNewConstMetric(
+					envelopesIngestedDesc,
+					prometheus.CounterValue,
+					float64(1),
+					"fake-origin",
+					"ValueMetric",
+					"processed",
+				)
+			})
+
+			It("returns a envelopes_ingested_total metric", func() {
+				Eventually(internalMetricsChan).Should(Receive(PrometheusMetric(envelopesIngestedMetric)))
+			})
+		})
 	})
 })
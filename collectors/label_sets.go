@@ -0,0 +1,85 @@
+package collectors
+
+import (
+	"sort"
+
+	"github.com/bosh-prometheus/firehose_exporter/utils"
+)
+
+// labelSets aligns the label names of the series of const metric families
+// whose labels depend on each envelope (tags, units): every series of a
+// family gets the union of the family label names, missing labels being
+// reported empty (which Prometheus treats as absent). Built-in labels come
+// first, in their order, followed by the other labels sorted.
+type labelSets struct {
+	builtinLabels []string
+	families      map[string]*labelSet
+}
+
+type labelSet struct {
+	labelNames map[string]bool
+	union      []string
+}
+
+func newLabelSets(builtinLabels []string) *labelSets {
+	return &labelSets{
+		builtinLabels: builtinLabels,
+		families:      map[string]*labelSet{},
+	}
+}
+
+func (s *labelSets) add(family string, labelNames []string) {
+	set, ok := s.families[family]
+	if !ok {
+		set = &labelSet{labelNames: map[string]bool{}}
+		s.families[family] = set
+	}
+
+	for _, label := range labelNames {
+		set.labelNames[label] = true
+	}
+	set.union = nil
+}
+
+func (s *labelSets) labelNames(family string) []string {
+	set, ok := s.families[family]
+	if !ok {
+		return nil
+	}
+
+	if set.union == nil {
+		set.union = make([]string, 0, len(set.labelNames))
+		for _, label := range s.builtinLabels {
+			if set.labelNames[label] {
+				set.union = append(set.union, label)
+			}
+		}
+		otherLabels := make([]string, 0, len(set.labelNames)-len(set.union))
+		for label := range set.labelNames {
+			if !utils.ContainsString(s.builtinLabels, label) {
+				otherLabels = append(otherLabels, label)
+			}
+		}
+		sort.Strings(otherLabels)
+		set.union = append(set.union, otherLabels...)
+	}
+
+	return set.union
+}
+
+// labelValues returns the values of a series of a family, in the family
+// labelNames order.
+func (s *labelSets) labelValues(family string, labelNames []string, labelValues []string) []string {
+	values := make(map[string]string, len(labelNames))
+	for i, label := range labelNames {
+		values[label] = labelValues[i]
+	}
+
+	union := s.labelNames(family)
+	alignedValues := make([]string, len(union))
+	for i, label := range union {
+		alignedValues[i] = values[label]
+	}
+
+	return alignedValues
+}
@@ -0,0 +1,290 @@
+package collectors
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+
+	"github.com/bosh-prometheus/firehose_exporter/mappings"
+	"github.com/bosh-prometheus/firehose_exporter/metrics"
+)
+
+type MappingsCollector struct {
+	namespace             string
+	environment           string
+	metricsStore          *metrics.Store
+	mapper                *mappings.Mapper
+	mappingsCollectorDesc *prometheus.Desc
+	summaries             map[string]*prometheus.SummaryVec
+	histograms            map[string]*prometheus.HistogramVec
+	seriesExpiration      time.Duration
+
+	mutex      sync.Mutex
+	seriesSeen map[string]map[string]*mappedSeries
+}
+
+// mappedSeries tracks the label values observed for a summary or histogram
+// target, so that the series can be deleted once they stop being observed.
+type mappedSeries struct {
+	labelValues []string
+	lastSeen    time.Time
+}
+
+// mappedSample is the value of a gauge or counter series, aggregated over
+// the events mapped to it.
+type mappedSample struct {
+	mapping     *mappings.Mapping
+	valueType   prometheus.ValueType
+	value       float64
+	labelValues []string
+}
+
+func NewMappingsCollector(
+	namespace string,
+	environment string,
+	metricsStore *metrics.Store,
+	mapper *mappings.Mapper,
+	seriesExpiration time.Duration,
+) *MappingsCollector {
+	mappingsCollectorDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, mappings_subsystem, "collector"),
+		"Cloud Foundry Firehose mapped metrics collector.",
+		nil,
+		prometheus.Labels{"environment": environment},
+	)
+
+	summaries := map[string]*prometheus.SummaryVec{}
+	histograms := map[string]*prometheus.HistogramVec{}
+	for _, mapping := range mapper.Mappings() {
+		switch mapping.Type {
+		case mappings.TypeSummary:
+			if _, ok := summaries[mapping.Target]; !ok {
+				summaries[mapping.Target] = prometheus.NewSummaryVec(
+					prometheus.SummaryOpts{
+						Namespace:   namespace,
+						Name:        mapping.Target,
+						Help:        mapping.Help,
+						ConstLabels: prometheus.Labels{"environment": environment},
+					},
+					mapping.LabelNames(),
+				)
+			}
+		case mappings.TypeHistogram:
+			if _, ok := histograms[mapping.Target]; !ok {
+				histograms[mapping.Target] = prometheus.NewHistogramVec(
+					prometheus.HistogramOpts{
+						Namespace:   namespace,
+						Name:        mapping.Target,
+						Help:        mapping.Help,
+						ConstLabels: prometheus.Labels{"environment": environment},
+						Buckets:     mapping.Buckets,
+					},
+					mapping.LabelNames(),
+				)
+			}
+		}
+	}
+
+	collector := &MappingsCollector{
+		namespace:             namespace,
+		environment:           environment,
+		metricsStore:          metricsStore,
+		mapper:                mapper,
+		mappingsCollectorDesc: mappingsCollectorDesc,
+		summaries:             summaries,
+		histograms:            histograms,
+		seriesExpiration:      seriesExpiration,
+		seriesSeen:            map[string]map[string]*mappedSeries{},
+	}
+	metricsStore.AddCounterEventObserver(collector)
+	metricsStore.AddValueMetricObserver(collector)
+
+	return collector
+}
+
+// ObserveCounterEvent feeds the deltas of mapped counter events into
+// summaries and histograms.
+func (c *MappingsCollector) ObserveCounterEvent(counterEvent metrics.CounterEvent) {
+	mapping := c.mapper.Match(mappings.EventTypeCounterEvent, counterEvent.Origin, counterEvent.Name)
+	if mapping == nil {
+		return
+	}
+
+	c.observe(mapping, float64(counterEvent.Delta), counterEventFields(counterEvent), counterEvent.Tags)
+}
+
+// ObserveValueMetric feeds the samples of mapped value metrics into
+// summaries and histograms.
+func (c *MappingsCollector) ObserveValueMetric(valueMetric metrics.ValueMetric) {
+	mapping := c.mapper.Match(mappings.EventTypeValueMetric, valueMetric.Origin, valueMetric.Name)
+	if mapping == nil {
+		return
+	}
+
+	c.observe(mapping, valueMetric.Value, valueMetricFields(valueMetric), valueMetric.Tags)
+}
+
+func (c *MappingsCollector) observe(mapping *mappings.Mapping, value float64, fields map[string]string, tags map[string]string) {
+	labelValues := mapping.LabelValues(fields, tags)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	switch mapping.Type {
+	case mappings.TypeSummary:
+		c.summaries[mapping.Target].WithLabelValues(labelValues...).Observe(value)
+	case mappings.TypeHistogram:
+		c.histograms[mapping.Target].WithLabelValues(labelValues...).Observe(value)
+	default:
+		return
+	}
+
+	series, ok := c.seriesSeen[mapping.Target]
+	if !ok {
+		series = map[string]*mappedSeries{}
+		c.seriesSeen[mapping.Target] = series
+	}
+	series[strings.Join(labelValues, "\xff")] = &mappedSeries{labelValues: labelValues, lastSeen: time.Now()}
+}
+
+// expire deletes the summary and histogram series that were not observed
+// during the series expiration, such as the series of deleted jobs.
+func (c *MappingsCollector) expire() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	for target, series := range c.seriesSeen {
+		for key, serie := range series {
+			if now.Sub(serie.lastSeen) < c.seriesExpiration {
+				continue
+			}
+
+			if summary, ok := c.summaries[target]; ok {
+				summary.DeleteLabelValues(serie.labelValues...)
+			}
+			if histogram, ok := c.histograms[target]; ok {
+				histogram.DeleteLabelValues(serie.labelValues...)
+			}
+			delete(series, key)
+		}
+	}
+}
+
+func (c *MappingsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.expire()
+
+	samples := map[string]*mappedSample{}
+
+	for _, counterEvent := range c.metricsStore.GetCounterEvents() {
+		mapping := c.mapper.Match(mappings.EventTypeCounterEvent, counterEvent.Origin, counterEvent.Name)
+		if mapping == nil {
+			continue
+		}
+
+		switch mapping.Type {
+		case mappings.TypeGauge:
+			addMappedSample(samples, mapping, prometheus.GaugeValue, float64(counterEvent.Total), counterEventFields(*counterEvent), counterEvent.Tags)
+		case mappings.TypeCounter:
+			addMappedSample(samples, mapping, prometheus.CounterValue, float64(counterEvent.MonotonicTotal), counterEventFields(*counterEvent), counterEvent.Tags)
+		}
+	}
+
+	for _, valueMetric := range c.metricsStore.GetValueMetrics() {
+		mapping := c.mapper.Match(mappings.EventTypeValueMetric, valueMetric.Origin, valueMetric.Name)
+		if mapping == nil {
+			continue
+		}
+
+		switch mapping.Type {
+		case mappings.TypeGauge:
+			addMappedSample(samples, mapping, prometheus.GaugeValue, valueMetric.Value, valueMetricFields(*valueMetric), valueMetric.Tags)
+		case mappings.TypeCounter:
+			addMappedSample(samples, mapping, prometheus.CounterValue, valueMetric.Value, valueMetricFields(*valueMetric), valueMetric.Tags)
+		}
+	}
+
+	for _, sample := range samples {
+		c.collectConst(ch, sample)
+	}
+
+	for _, summary := range c.summaries {
+		summary.Collect(ch)
+	}
+	for _, histogram := range c.histograms {
+		histogram.Collect(ch)
+	}
+}
+
+// addMappedSample adds the value of an event to its series. Events that only
+// differ by labels the mapping does not keep end up in the same series, so
+// their values are aggregated following the mapping aggregation.
+func addMappedSample(samples map[string]*mappedSample, mapping *mappings.Mapping, valueType prometheus.ValueType, value float64, fields map[string]string, tags map[string]string) {
+	labelValues := mapping.LabelValues(fields, tags)
+	key := mapping.Target + "\xff" + strings.Join(labelValues, "\xff")
+
+	if sample, ok := samples[key]; ok {
+		sample.value = mapping.Aggregate(sample.value, value)
+		return
+	}
+
+	samples[key] = &mappedSample{
+		mapping:     mapping,
+		valueType:   valueType,
+		value:       value,
+		labelValues: labelValues,
+	}
+}
+
+func (c *MappingsCollector) collectConst(ch chan<- prometheus.Metric, sample *mappedSample) {
+	metric, err := prometheus.NewConstMetric(
+		prometheus.NewDesc(
+			prometheus.BuildFQName(c.namespace, "", sample.mapping.Target),
+			sample.mapping.Help,
+			sample.mapping.LabelNames(),
+			prometheus.Labels{"environment": c.environment},
+		),
+		sample.valueType,
+		sample.value,
+		sample.labelValues...,
+	)
+	if err != nil {
+		log.Errorf("Mapped metric `%s` discarded: %s", sample.mapping.Target, err)
+		return
+	}
+	ch <- metric
+}
+
+func (c *MappingsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.mappingsCollectorDesc
+	for _, summary := range c.summaries {
+		summary.Describe(ch)
+	}
+	for _, histogram := range c.histograms {
+		histogram.Describe(ch)
+	}
+}
+
+func counterEventFields(counterEvent metrics.CounterEvent) map[string]string {
+	return map[string]string{
+		"origin":          counterEvent.Origin,
+		"bosh_deployment": counterEvent.Deployment,
+		"bosh_job_name":   counterEvent.Job,
+		"bosh_job_id":     counterEvent.Index,
+		"bosh_job_ip":     counterEvent.IP,
+	}
+}
+
+func valueMetricFields(valueMetric metrics.ValueMetric) map[string]string {
+	return map[string]string{
+		"origin":          valueMetric.Origin,
+		"bosh_deployment": valueMetric.Deployment,
+		"bosh_job_name":   valueMetric.Job,
+		"bosh_job_id":     valueMetric.Index,
+		"bosh_job_ip":     valueMetric.IP,
+		"unit":            valueMetric.Unit,
+	}
+}
@@ -0,0 +1,300 @@
+package collectors_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bosh-prometheus/firehose_exporter/filters"
+	"github.com/bosh-prometheus/firehose_exporter/mappings"
+	"github.com/bosh-prometheus/firehose_exporter/metrics"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+
+	. "github.com/bosh-prometheus/firehose_exporter/collectors"
+	. "github.com/bosh-prometheus/firehose_exporter/utils/test_matchers"
+)
+
+var _ = Describe("MappingsCollector", func() {
+	var (
+		namespace              string
+		environment            string
+		metricsStore           *metrics.Store
+		metricsExpiration      time.Duration
+		metricsCleanupInterval time.Duration
+		deploymentFilter       *filters.DeploymentFilter
+		eventFilter            *filters.EventFilter
+		mapper                 *mappings.Mapper
+		mappingsCollector      *MappingsCollector
+		seriesExpiration       time.Duration
+
+		mappingsCollectorDesc *prometheus.Desc
+
+		boshDeployment = "fake-deployment-name"
+		boshJob        = "fake-job-name"
+		boshIndex      = "0"
+		boshIP         = "1.2.3.4"
+	)
+
+	BeforeEach(func() {
+		namespace = "test_exporter"
+		environment = "test_environment"
+		deploymentFilter = filters.NewDeploymentFilter([]string{})
+		eventFilter, _ = filters.NewEventFilter([]string{})
+		metricsStore = metrics.NewStore(metricsExpiration, metricsCleanupInterval, deploymentFilter, eventFilter)
+		seriesExpiration = time.Hour
+
+		var err error
+		mapper, err = mappings.NewMapper([]mappings.Mapping{
+			{
+				EventType: mappings.EventTypeValueMetric,
+				Origin:    "fake.origin",
+				Name:      "FakeGauge",
+				Type:      mappings.TypeGauge,
+				Target:    "fake_gauge",
+				Labels:    map[string]string{"deployment": "bosh_deployment", "component": "tags.component"},
+			},
+			{
+				EventType: mappings.EventTypeValueMetric,
+				Origin:    "fake.origin",
+				Name:      "FakeLatency",
+				Type:      mappings.TypeHistogram,
+				Target:    "fake_latency_milliseconds",
+				Buckets:   []float64{10, 100, 1000},
+				Labels:    map[string]string{"deployment": "bosh_deployment"},
+			},
+			{
+				EventType: mappings.EventTypeCounterEvent,
+				Origin:    "fake.origin",
+				Name:      "FakeRequests",
+				Type:      mappings.TypeCounter,
+				Target:    "fake_requests_total",
+				Labels:    map[string]string{"deployment": "bosh_deployment"},
+			},
+			{
+				EventType: mappings.EventTypeCounterEvent,
+				Origin:    "fake.origin",
+				Name:      "FakeBatch",
+				Type:      mappings.TypeSummary,
+				Target:    "fake_batch_size",
+				Labels:    map[string]string{"deployment": "bosh_deployment"},
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		mappingsCollectorDesc = prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "mappings", "collector"),
+			"Cloud Foundry Firehose mapped metrics collector.",
+			nil,
+			prometheus.Labels{"environment": environment},
+		)
+	})
+
+	JustBeforeEach(func() {
+		mappingsCollector = NewMappingsCollector(namespace, environment, metricsStore, mapper, seriesExpiration)
+	})
+
+	Describe("Describe", func() {
+		var (
+			descriptions chan *prometheus.Desc
+		)
+
+		BeforeEach(func() {
+			descriptions = make(chan *prometheus.Desc)
+		})
+
+		JustBeforeEach(func() {
+			go mappingsCollector.Describe(descriptions)
+		})
+
+		It("returns a mappings_collector metric description", func() {
+			Eventually(descriptions).Should(Receive(Equal(mappingsCollectorDesc)))
+		})
+	})
+
+	Describe("Collect", func() {
+		var (
+			mappingsChan chan prometheus.Metric
+
+			gaugeMetric     prometheus.Metric
+			counterMetric   prometheus.Metric
+			histogramMetric *prometheus.HistogramVec
+			summaryMetric   *prometheus.SummaryVec
+		)
+
+		addValueMetric := func(index string, name string, value float64) {
+			metricsStore.AddMetric(
+				&events.Envelope{
+					Origin:     proto.String("fake.origin"),
+					EventType:  events.Envelope_ValueMetric.Enum(),
+					Timestamp:  proto.Int64(time.Now().Unix() * 1000),
+					Deployment: proto.String(boshDeployment),
+					Job:        proto.String(boshJob),
+					Index:      proto.String(index),
+					Ip:         proto.String(boshIP),
+					ValueMetric: &events.ValueMetric{
+						Name:  proto.String(name),
+						Value: proto.Float64(value),
+						Unit:  proto.String("ms"),
+					},
+					Tags: map[string]string{
+						"component": "fake-component",
+					},
+				},
+			)
+		}
+
+		addCounterEvent := func(index string, name string, delta uint64, total uint64) {
+			metricsStore.AddMetric(
+				&events.Envelope{
+					Origin:     proto.String("fake.origin"),
+					EventType:  events.Envelope_CounterEvent.Enum(),
+					Timestamp:  proto.Int64(time.Now().Unix() * 1000),
+					Deployment: proto.String(boshDeployment),
+					Job:        proto.String(boshJob),
+					Index:      proto.String(index),
+					Ip:         proto.String(boshIP),
+					CounterEvent: &events.CounterEvent{
+						Name:  proto.String(name),
+						Delta: proto.Uint64(delta),
+						Total: proto.Uint64(total),
+					},
+				},
+			)
+		}
+
+		BeforeEach(func() {
+			mappingsChan = make(chan prometheus.Metric)
+
+			gaugeMetric = prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "", "fake_gauge"),
+					"Cloud Foundry Firehose metrics mapped to 'fake_gauge'.",
+					[]string{"component", "deployment"},
+					prometheus.Labels{"environment": environment},
+				),
+				prometheus.GaugeValue,
+				float64(42),
+				"fake-component",
+				boshDeployment,
+			)
+
+			counterMetric = prometheus.MustNewConstMetric(
+				prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "", "fake_requests_total"),
+					"Cloud Foundry Firehose metrics mapped to 'fake_requests_total'.",
+					[]string{"deployment"},
+					prometheus.Labels{"environment": environment},
+				),
+				prometheus.CounterValue,
+				float64(15),
+				boshDeployment,
+			)
+
+			histogramMetric = prometheus.NewHistogramVec(
+				prometheus.HistogramOpts{
+					Namespace:   namespace,
+					Name:        "fake_latency_milliseconds",
+					Help:        "Cloud Foundry Firehose metrics mapped to 'fake_latency_milliseconds'.",
+					ConstLabels: prometheus.Labels{"environment": environment},
+					Buckets:     []float64{10, 100, 1000},
+				},
+				[]string{"deployment"},
+			)
+			histogramMetric.WithLabelValues(boshDeployment).Observe(5)
+			histogramMetric.WithLabelValues(boshDeployment).Observe(500)
+
+			summaryMetric = prometheus.NewSummaryVec(
+				prometheus.SummaryOpts{
+					Namespace:   namespace,
+					Name:        "fake_batch_size",
+					Help:        "Cloud Foundry Firehose metrics mapped to 'fake_batch_size'.",
+					ConstLabels: prometheus.Labels{"environment": environment},
+				},
+				[]string{"deployment"},
+			)
+			summaryMetric.WithLabelValues(boshDeployment).Observe(3)
+			summaryMetric.WithLabelValues(boshDeployment).Observe(7)
+		})
+
+		JustBeforeEach(func() {
+			addValueMetric(boshIndex, "FakeGauge", 42)
+			addValueMetric(boshIndex, "FakeLatency", 5)
+			addValueMetric(boshIndex, "FakeLatency", 500)
+			addCounterEvent(boshIndex, "FakeRequests", 10, 10)
+			addCounterEvent(boshIndex, "FakeRequests", 5, 15)
+			addCounterEvent(boshIndex, "FakeBatch", 3, 3)
+			addCounterEvent(boshIndex, "FakeBatch", 7, 10)
+
+			go mappingsCollector.Collect(mappingsChan)
+		})
+
+		It("returns a gauge for a value metric mapped to a gauge", func() {
+			Eventually(mappingsChan).Should(Receive(PrometheusMetric(gaugeMetric)))
+		})
+
+		It("returns a counter for a counter event mapped to a counter", func() {
+			Eventually(mappingsChan).Should(Receive(PrometheusMetric(counterMetric)))
+		})
+
+		It("returns a histogram for a value metric mapped to a histogram", func() {
+			Eventually(mappingsChan).Should(Receive(PrometheusMetric(histogramMetric.WithLabelValues(boshDeployment).(prometheus.Histogram))))
+		})
+
+		It("returns a summary for a counter event mapped to a summary", func() {
+			Eventually(mappingsChan).Should(Receive(PrometheusMetric(summaryMetric.WithLabelValues(boshDeployment).(prometheus.Summary))))
+		})
+
+		Context("when the mapping labels do not tell apart several metrics", func() {
+			BeforeEach(func() {
+				addValueMetric("1", "FakeGauge", 8)
+				addCounterEvent("1", "FakeRequests", 5, 5)
+
+				gaugeMetric = prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						prometheus.BuildFQName(namespace, "", "fake_gauge"),
+						"Cloud Foundry Firehose metrics mapped to 'fake_gauge'.",
+						[]string{"component", "deployment"},
+						prometheus.Labels{"environment": environment},
+					),
+					prometheus.GaugeValue,
+					float64(50),
+					"fake-component",
+					boshDeployment,
+				)
+
+				counterMetric = prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						prometheus.BuildFQName(namespace, "", "fake_requests_total"),
+						"Cloud Foundry Firehose metrics mapped to 'fake_requests_total'.",
+						[]string{"deployment"},
+						prometheus.Labels{"environment": environment},
+					),
+					prometheus.CounterValue,
+					float64(20),
+					boshDeployment,
+				)
+			})
+
+			It("returns the gauges aggregated in a single series", func() {
+				Eventually(mappingsChan).Should(Receive(PrometheusMetric(gaugeMetric)))
+			})
+
+			It("returns the counters summed in a single series", func() {
+				Eventually(mappingsChan).Should(Receive(PrometheusMetric(counterMetric)))
+			})
+		})
+
+		Context("when the summary and histogram series expire", func() {
+			BeforeEach(func() {
+				seriesExpiration = 0
+			})
+
+			It("does not return them", func() {
+				Consistently(mappingsChan).ShouldNot(Receive(PrometheusMetric(histogramMetric.WithLabelValues(boshDeployment).(prometheus.Histogram))))
+			})
+		})
+	})
+})
@@ -0,0 +1,180 @@
+package collectors
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bosh-prometheus/firehose_exporter/utils"
+)
+
+// otherApplicationID is the application id the http start stop requests of
+// the applications out of the top applications are folded into.
+const otherApplicationID = "other"
+
+// topApplicationsSlots is the number of slots the rolling window of the top
+// applications is divided into.
+const topApplicationsSlots = 10
+
+type applicationCounts struct {
+	requests int64
+	errors   int64
+}
+
+// topApplications keeps the top n applications by requests, and the top n
+// by server errors, over a rolling window. The tops are recomputed each time
+// the window slides by a slot; in between, applications are admitted while
+// a top has room. The applications leaving the tops are reported so their
+// series can be deleted, bounding the number of series.
+type topApplications struct {
+	n            int
+	slotDuration time.Duration
+
+	mutex      sync.Mutex
+	slots      []map[string]*applicationCounts
+	current    int
+	slotStart  time.Time
+	byRequests map[string]bool
+	byErrors   map[string]bool
+	demoted    []string
+}
+
+// newTopApplications returns nil, keeping every application, when n is not
+// positive.
+func newTopApplications(n int, window time.Duration) *topApplications {
+	if n <= 0 {
+		return nil
+	}
+
+	slots := make([]map[string]*applicationCounts, topApplicationsSlots)
+	for i := range slots {
+		slots[i] = map[string]*applicationCounts{}
+	}
+
+	slotDuration := window / topApplicationsSlots
+	if slotDuration <= 0 {
+		slotDuration = 1
+	}
+
+	return &topApplications{
+		n:            n,
+		slotDuration: slotDuration,
+		slots:        slots,
+		slotStart:    time.Now(),
+		byRequests:   map[string]bool{},
+		byErrors:     map[string]bool{},
+	}
+}
+
+// keep accounts a request (failed if it got a server error) of an
+// application, and returns whether the application is in a top, along with
+// the applications that left the tops since the last call. A nil
+// topApplications keeps every application.
+func (t *topApplications) keep(applicationID string, request bool, failed bool) (bool, []string) {
+	if t == nil {
+		return true, nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.slide(time.Now())
+
+	if request {
+		counts, ok := t.slots[t.current][applicationID]
+		if !ok {
+			counts = &applicationCounts{}
+			t.slots[t.current][applicationID] = counts
+		}
+		counts.requests++
+		if failed {
+			counts.errors++
+		}
+	}
+
+	if !t.byRequests[applicationID] && len(t.byRequests) < t.n {
+		t.byRequests[applicationID] = true
+	}
+	if failed && !t.byErrors[applicationID] && len(t.byErrors) < t.n {
+		t.byErrors[applicationID] = true
+	}
+
+	demoted := t.demoted
+	t.demoted = nil
+
+	return t.byRequests[applicationID] || t.byErrors[applicationID], demoted
+}
+
+// slide moves the window to the slot of now, dropping the counts of the
+// slots that left the window, and recomputes the tops, recording the
+// applications that left them.
+func (t *topApplications) slide(now time.Time) {
+	elapsed := now.Sub(t.slotStart)
+	if elapsed < t.slotDuration {
+		return
+	}
+
+	steps := int(elapsed / t.slotDuration)
+	t.slotStart = t.slotStart.Add(time.Duration(steps) * t.slotDuration)
+	if steps > len(t.slots) {
+		steps = len(t.slots)
+	}
+	for i := 0; i < steps; i++ {
+		t.current = (t.current + 1) % len(t.slots)
+		t.slots[t.current] = map[string]*applicationCounts{}
+	}
+
+	totals := map[string]*applicationCounts{}
+	for _, slot := range t.slots {
+		for applicationID, counts := range slot {
+			total, ok := totals[applicationID]
+			if !ok {
+				total = &applicationCounts{}
+				totals[applicationID] = total
+			}
+			total.requests += counts.requests
+			total.errors += counts.errors
+		}
+	}
+
+	byRequests := topN(totals, t.n, func(counts *applicationCounts) int64 { return counts.requests })
+	byErrors := topN(totals, t.n, func(counts *applicationCounts) int64 { return counts.errors })
+	for _, top := range []map[string]bool{t.byRequests, t.byErrors} {
+		for applicationID := range top {
+			if !byRequests[applicationID] && !byErrors[applicationID] && !utils.ContainsString(t.demoted, applicationID) {
+				t.demoted = append(t.demoted, applicationID)
+			}
+		}
+	}
+	t.byRequests = byRequests
+	t.byErrors = byErrors
+}
+
+// topN returns the n applications with the highest non zero count, ties
+// being broken by application id.
+func topN(totals map[string]*applicationCounts, n int, count func(*applicationCounts) int64) map[string]bool {
+	var applicationIDs []string
+	for applicationID, counts := range totals {
+		if count(counts) > 0 {
+			applicationIDs = append(applicationIDs, applicationID)
+		}
+	}
+
+	sort.Slice(applicationIDs, func(i, j int) bool {
+		ci, cj := count(totals[applicationIDs[i]]), count(totals[applicationIDs[j]])
+		if ci != cj {
+			return ci > cj
+		}
+		return applicationIDs[i] < applicationIDs[j]
+	})
+	if len(applicationIDs) > n {
+		applicationIDs = applicationIDs[:n]
+	}
+
+	top := make(map[string]bool, len(applicationIDs))
+	for _, applicationID := range applicationIDs {
+		top[applicationID] = true
+	}
+
+	return top
+}
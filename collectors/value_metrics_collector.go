@@ -3,17 +3,33 @@ package collectors
 import (
 	"fmt"
 
+	"github.com/cloudfoundry/sonde-go/events"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/log"
 
+	"github.com/bosh-prometheus/firehose_exporter/boshdirector"
+	"github.com/bosh-prometheus/firehose_exporter/mappings"
+	"github.com/bosh-prometheus/firehose_exporter/metadata"
 	"github.com/bosh-prometheus/firehose_exporter/metrics"
+	"github.com/bosh-prometheus/firehose_exporter/naming"
+	"github.com/bosh-prometheus/firehose_exporter/tagpolicy"
 	"github.com/bosh-prometheus/firehose_exporter/utils"
 )
 
+// valueMetricsLabelNames are the built-in labels of the value metrics.
+var valueMetricsLabelNames = []string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "unit"}
+
 type ValueMetricsCollector struct {
 	namespace                 string
 	environment               string
 	metricsStore              *metrics.Store
+	unitConversions           map[string]utils.UnitConversion
+	mapper                    *mappings.Mapper
+	tagPolicy                 *tagpolicy.Policy
+	namer                     *naming.Namer
+	boshInstances             *boshdirector.Cache
+	catalog                   *metadata.Catalog
+	describeCatalog           bool
 	valueMetricsCollectorDesc *prometheus.Desc
 }
 
@@ -21,6 +37,13 @@ func NewValueMetricsCollector(
 	namespace string,
 	environment string,
 	metricsStore *metrics.Store,
+	unitConversions map[string]utils.UnitConversion,
+	mapper *mappings.Mapper,
+	tagPolicy *tagpolicy.Policy,
+	namer *naming.Namer,
+	boshInstances *boshdirector.Cache,
+	catalog *metadata.Catalog,
+	describeCatalog bool,
 ) *ValueMetricsCollector {
 	valueMetricsCollectorDesc := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, value_metrics_subsystem, "collector"),
@@ -33,42 +56,126 @@ func NewValueMetricsCollector(
 		namespace:                 namespace,
 		environment:               environment,
 		metricsStore:              metricsStore,
+		unitConversions:           unitConversions,
+		mapper:                    mapper,
+		tagPolicy:                 tagPolicy,
+		namer:                     namer,
+		boshInstances:             boshInstances,
+		catalog:                   catalog,
+		describeCatalog:           describeCatalog,
 		valueMetricsCollectorDesc: valueMetricsCollectorDesc,
 	}
 }
 
+// valueMetricSeries is a value metric series, before its labels are aligned
+// with the other series of its family.
+type valueMetricSeries struct {
+	valueMetric *metrics.ValueMetric
+	metricName  string
+	converted   bool
+	value       float64
+	labelNames  []string
+	labelValues []string
+}
+
+func (s valueMetricSeries) help() string {
+	if s.converted {
+		return fmt.Sprintf("Cloud Foundry Firehose '%s' value metric from '%s' (originally reported in '%s').", utils.NormalizeNameDesc(s.valueMetric.Name), utils.NormalizeOriginDesc(s.valueMetric.Origin), s.valueMetric.Unit)
+	}
+
+	return fmt.Sprintf("Cloud Foundry Firehose '%s' value metric from '%s'.", utils.NormalizeNameDesc(s.valueMetric.Name), utils.NormalizeOriginDesc(s.valueMetric.Origin))
+}
+
 func (c ValueMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	var tagLabelCollisions int64
+	var series []valueMetricSeries
+	labelSets := newLabelSets(append(append([]string{}, valueMetricsLabelNames...), boshInstanceLabelNames...))
+	helpSeries := map[string]valueMetricSeries{}
+
 	for _, valueMetric := range c.metricsStore.GetValueMetrics() {
-		metricName := utils.NormalizeName(valueMetric.Origin) + "_" + utils.NormalizeName(valueMetric.Name)
+		if c.mapper.Match(mappings.EventTypeValueMetric, valueMetric.Origin, valueMetric.Name) != nil {
+			continue
+		}
+
+		s := valueMetricSeries{
+			valueMetric: valueMetric,
+			metricName:  c.namer.Name(c.namespace, value_metrics_subsystem, mappings.EventTypeValueMetric, valueMetric.Origin, valueMetric.Name),
+			value:       valueMetric.Value,
+			labelNames:  append([]string{}, valueMetricsLabelNames...),
+			labelValues: []string{valueMetric.Origin, valueMetric.Deployment, valueMetric.Job, valueMetric.Index, valueMetric.IP, valueMetric.Unit},
+		}
 
-		constLabels := []string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "unit"}
-		labelValues := []string{valueMetric.Origin, valueMetric.Deployment, valueMetric.Job, valueMetric.Index, valueMetric.IP, valueMetric.Unit}
+		if convertedName, convertedValue, ok := utils.ConvertUnit(s.metricName, s.value, valueMetric.Unit, c.unitConversions); ok {
+			s.metricName = convertedName
+			s.converted = true
+			s.value = convertedValue
+			s.labelNames = s.labelNames[:len(s.labelNames)-1]
+			s.labelValues = s.labelValues[:len(s.labelValues)-1]
+		}
+
+		if c.boshInstances != nil {
+			s.labelNames = append(s.labelNames, boshInstanceLabelNames...)
+			s.labelValues = append(s.labelValues, boshInstanceLabelValues(c.boshInstances, valueMetric.Deployment, valueMetric.Index)...)
+		}
 
-		for k, v := range valueMetric.Tags {
-			constLabels = append(constLabels, utils.NormalizeName(k))
-			labelValues = append(labelValues, v)
+		tagLabelNames, tagLabelValues, collided := c.tagPolicy.Labels(valueMetric.Tags, builtinLabels(s.labelNames))
+		if collided {
+			tagLabelCollisions++
+		}
+		s.labelNames = append(s.labelNames, tagLabelNames...)
+		s.labelValues = append(s.labelValues, tagLabelValues...)
+
+		labelSets.add(s.metricName, s.labelNames)
+		if _, ok := helpSeries[s.metricName]; !ok {
+			helpSeries[s.metricName] = s
+		}
+		series = append(series, s)
+	}
+
+	for _, s := range series {
+		unit := s.valueMetric.Unit
+		if s.converted {
+			unit = ""
+		}
+
+		desc, err := c.catalog.Desc(
+			metadata.Observation{
+				Source:     value_metrics_subsystem,
+				Name:       s.metricName,
+				Type:       metadata.TypeGauge,
+				Unit:       unit,
+				Origin:     s.valueMetric.Origin,
+				LabelNames: labelSets.labelNames(s.metricName),
+			},
+			prometheus.Labels{"environment": c.environment},
+			helpSeries[s.metricName].help,
+		)
+		if err != nil {
+			log.Errorf("Value Metric `%s` from `%s` discarded: %s", s.valueMetric.Name, s.valueMetric.Origin, err)
+			continue
 		}
 
 		vm, err := prometheus.NewConstMetric(
-			prometheus.NewDesc(
-				prometheus.BuildFQName(c.namespace, value_metrics_subsystem, metricName),
-				fmt.Sprintf("Cloud Foundry Firehose '%s' value metric from '%s'.", utils.NormalizeNameDesc(valueMetric.Name), utils.NormalizeOriginDesc(valueMetric.Origin)),
-				constLabels,
-				prometheus.Labels{"environment": c.environment},
-			),
+			desc,
 			prometheus.GaugeValue,
-			float64(valueMetric.Value),
-			labelValues...,
+			s.value,
+			labelSets.labelValues(s.metricName, s.labelNames, s.labelValues)...,
 		)
-
 		if err != nil {
-			log.Errorf("Value Metric `%s` from `%s` discarded: %s", valueMetric.Name, valueMetric.Origin, err)
+			log.Errorf("Value Metric `%s` from `%s` discarded: %s", s.valueMetric.Name, s.valueMetric.Origin, err)
 			continue
 		}
 		ch <- vm
 	}
+
+	if tagLabelCollisions > 0 {
+		c.metricsStore.AddTagLabelCollisions(events.Envelope_ValueMetric, tagLabelCollisions)
+	}
 }
 
 func (c ValueMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.valueMetricsCollectorDesc
+	if c.describeCatalog {
+		c.catalog.Descs(value_metrics_subsystem, ch)
+	}
 }
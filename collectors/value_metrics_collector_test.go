@@ -2,13 +2,20 @@ package collectors_test
 
 import (
 	"fmt"
+	"regexp"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/bosh-prometheus/firehose_exporter/boshdirector"
 	"github.com/bosh-prometheus/firehose_exporter/filters"
+	"github.com/bosh-prometheus/firehose_exporter/mappings"
+	"github.com/bosh-prometheus/firehose_exporter/metadata"
 	"github.com/bosh-prometheus/firehose_exporter/metrics"
+	"github.com/bosh-prometheus/firehose_exporter/naming"
+	"github.com/bosh-prometheus/firehose_exporter/tagpolicy"
+	"github.com/bosh-prometheus/firehose_exporter/utils"
 	"github.com/cloudfoundry/sonde-go/events"
 	"github.com/gogo/protobuf/proto"
 	"github.com/prometheus/client_golang/prometheus"
@@ -27,6 +34,13 @@ var _ = Describe("ValueMetricsCollector", func() {
 		deploymentFilter       *filters.DeploymentFilter
 		eventFilter            *filters.EventFilter
 		valueMetricsCollector  *ValueMetricsCollector
+		unitConversions        map[string]utils.UnitConversion
+		mapper                 *mappings.Mapper
+		tagPolicy              *tagpolicy.Policy
+		namer                  *naming.Namer
+		boshInstances          *boshdirector.Cache
+		catalog                *metadata.Catalog
+		describeCatalog        bool
 
 		valueMetricsCollectorDesc *prometheus.Desc
 	)
@@ -37,6 +51,13 @@ var _ = Describe("ValueMetricsCollector", func() {
 		deploymentFilter = filters.NewDeploymentFilter([]string{})
 		eventFilter, _ = filters.NewEventFilter([]string{})
 		metricsStore = metrics.NewStore(metricsExpiration, metricsCleanupInterval, deploymentFilter, eventFilter)
+		unitConversions = nil
+		mapper = nil
+		tagPolicy = nil
+		namer = nil
+		boshInstances = nil
+		catalog = nil
+		describeCatalog = false
 
 		valueMetricsCollectorDesc = prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "value_metric", "collector"),
@@ -47,7 +68,7 @@ var _ = Describe("ValueMetricsCollector", func() {
 	})
 
 	JustBeforeEach(func() {
-		valueMetricsCollector = NewValueMetricsCollector(namespace, environment, metricsStore)
+		valueMetricsCollector = NewValueMetricsCollector(namespace, environment, metricsStore, unitConversions, mapper, tagPolicy, namer, boshInstances, catalog, describeCatalog)
 	})
 
 	Describe("Describe", func() {
@@ -66,6 +87,45 @@ var _ = Describe("ValueMetricsCollector", func() {
 		It("returns a value_metric_collector metric description", func() {
 			Eventually(descriptions).Should(Receive(Equal(valueMetricsCollectorDesc)))
 		})
+
+		Context("when the catalog descriptors are enabled", func() {
+			var valueMetricDesc *prometheus.Desc
+
+			BeforeEach(func() {
+				catalog = metadata.NewCatalog()
+				describeCatalog = true
+
+				var err error
+				valueMetricDesc, err = catalog.Desc(
+					metadata.Observation{
+						Source:     "value_metric",
+						Name:       prometheus.BuildFQName(namespace, "value_metric", "fake_origin_fake_value_metric"),
+						Type:       metadata.TypeGauge,
+						LabelNames: []string{"origin"},
+					},
+					prometheus.Labels{"environment": environment},
+					func() string { return "Fake help." },
+				)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns the descriptions of the value metrics seen so far", func() {
+				Eventually(descriptions).Should(Receive(Equal(valueMetricsCollectorDesc)))
+				Eventually(descriptions).Should(Receive(Equal(valueMetricDesc)))
+			})
+
+			It("lets the registry reject a conflicting registration", func() {
+				registry := prometheus.NewRegistry()
+				Expect(registry.Register(valueMetricsCollector)).To(Succeed())
+
+				conflictingGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+					Name:        prometheus.BuildFQName(namespace, "value_metric", "fake_origin_fake_value_metric"),
+					Help:        "Fake help.",
+					ConstLabels: prometheus.Labels{"environment": environment},
+				})
+				Expect(registry.Register(conflictingGauge)).ToNot(Succeed())
+			})
+		})
 	})
 
 	Describe("Collect", func() {
@@ -198,6 +258,250 @@ var _ = Describe("ValueMetricsCollector", func() {
 			Eventually(valueMetricsChan).Should(Receive(PrometheusMetric(valueMetric2)))
 		})
 
+		Context("when units are converted", func() {
+			BeforeEach(func() {
+				unitConversions = utils.UnitConversions
+			})
+
+			It("returns a value_metric_fake_origin_fake_value_metric_1_bytes metric", func() {
+				convertedValueMetric1 := prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						prometheus.BuildFQName(namespace, "value_metric", valueMetric1OriginNameNormalized+"_"+valueMetric1NameNormalized+"_bytes"),
+						fmt.Sprintf("Cloud Foundry Firehose '%s' value metric from '%s' (originally reported in '%s').", valueMetric1DescNormalized, valueMetric1OriginDescNormalized, valueMetric1Unit),
+						[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", tag1NameNormalized},
+						prometheus.Labels{"environment": environment},
+					),
+					prometheus.GaugeValue,
+					valueMetric1Value*1000,
+					valueMetric1Origin,
+					boshDeployment,
+					boshJob,
+					boshIndex,
+					boshIP,
+					tag1Value,
+				)
+
+				Eventually(valueMetricsChan).Should(Receive(PrometheusMetric(convertedValueMetric1)))
+			})
+
+			It("does not convert unknown units", func() {
+				Eventually(valueMetricsChan).Should(Receive(PrometheusMetric(valueMetric2)))
+			})
+		})
+
+		Context("when a tag collides with a built-in label", func() {
+			BeforeEach(func() {
+				metricsStore.AddMetric(
+					&events.Envelope{
+						Origin:     proto.String(valueMetric1Origin),
+						EventType:  events.Envelope_ValueMetric.Enum(),
+						Timestamp:  proto.Int64(time.Now().Unix() * 1000),
+						Deployment: proto.String(boshDeployment),
+						Job:        proto.String(boshJob),
+						Index:      proto.String(boshIndex),
+						Ip:         proto.String(boshIP),
+						ValueMetric: &events.ValueMetric{
+							Name:  proto.String(valueMetric1Name),
+							Value: proto.Float64(valueMetric1Value),
+							Unit:  proto.String(valueMetric1Unit),
+						},
+						Tags: map[string]string{
+							tag1Name: tag1Value,
+							"origin": "fake-tag-origin",
+						},
+					},
+				)
+			})
+
+			It("returns the tag prefixed and counts the collision", func() {
+				collidingValueMetric1 := prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						prometheus.BuildFQName(namespace, "value_metric", valueMetric1OriginNameNormalized+"_"+valueMetric1NameNormalized),
+						fmt.Sprintf("Cloud Foundry Firehose '%s' value metric from '%s'.", valueMetric1DescNormalized, valueMetric1OriginDescNormalized),
+						[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "unit", "tag_1", "tag_origin"},
+						prometheus.Labels{"environment": environment},
+					),
+					prometheus.GaugeValue,
+					valueMetric1Value,
+					valueMetric1Origin,
+					boshDeployment,
+					boshJob,
+					boshIndex,
+					boshIP,
+					valueMetric1Unit,
+					tag1Value,
+					"fake-tag-origin",
+				)
+
+				Eventually(valueMetricsChan).Should(Receive(PrometheusMetric(collidingValueMetric1)))
+				Eventually(func() int64 {
+					select {
+					case <-valueMetricsChan:
+					default:
+					}
+					return metricsStore.GetInternalMetrics().TotalValueMetricsTagLabelCollisions
+				}).Should(Equal(int64(1)))
+			})
+		})
+
+		Context("when emitters report different tags for the same value metric", func() {
+			var otherBoshJob = "fake-other-job-name"
+
+			BeforeEach(func() {
+				metricsStore.AddMetric(
+					&events.Envelope{
+						Origin:     proto.String(valueMetric1Origin),
+						EventType:  events.Envelope_ValueMetric.Enum(),
+						Timestamp:  proto.Int64(time.Now().Unix() * 1000),
+						Deployment: proto.String(boshDeployment),
+						Job:        proto.String(otherBoshJob),
+						Index:      proto.String(boshIndex),
+						Ip:         proto.String(boshIP),
+						ValueMetric: &events.ValueMetric{
+							Name:  proto.String(valueMetric1Name),
+							Value: proto.Float64(valueMetric1Value),
+							Unit:  proto.String(valueMetric1Unit),
+						},
+						Tags: map[string]string{
+							tag2Name: tag2Value,
+						},
+					},
+				)
+			})
+
+			It("returns the union of the label names with the missing labels empty", func() {
+				labelNames := []string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "unit", tag1NameNormalized, tag2NameNormalized}
+				otherValueMetric1 := prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						prometheus.BuildFQName(namespace, "value_metric", valueMetric1OriginNameNormalized+"_"+valueMetric1NameNormalized),
+						fmt.Sprintf("Cloud Foundry Firehose '%s' value metric from '%s'.", valueMetric1DescNormalized, valueMetric1OriginDescNormalized),
+						labelNames,
+						prometheus.Labels{"environment": environment},
+					),
+					prometheus.GaugeValue,
+					valueMetric1Value,
+					valueMetric1Origin,
+					boshDeployment,
+					otherBoshJob,
+					boshIndex,
+					boshIP,
+					valueMetric1Unit,
+					"",
+					tag2Value,
+				)
+
+				Eventually(valueMetricsChan).Should(Receive(PrometheusMetric(otherValueMetric1)))
+			})
+		})
+
+		Context("when a catalog is used", func() {
+			BeforeEach(func() {
+				catalog = metadata.NewCatalog()
+			})
+
+			It("records the value metric families", func() {
+				for i := 0; i < 2; i++ {
+					Eventually(valueMetricsChan).Should(Receive())
+				}
+
+				families := catalog.Families()
+				Expect(families).To(HaveLen(2))
+				Expect(families[0].Name).To(Equal(prometheus.BuildFQName(namespace, "value_metric", valueMetric1OriginNameNormalized+"_"+valueMetric1NameNormalized)))
+				Expect(families[0].Source).To(Equal("value_metric"))
+				Expect(families[0].Type).To(Equal(metadata.TypeGauge))
+				Expect(families[0].Help).To(Equal(fmt.Sprintf("Cloud Foundry Firehose '%s' value metric from '%s'.", valueMetric1DescNormalized, valueMetric1OriginDescNormalized)))
+				Expect(families[0].Unit).To(Equal(valueMetric1Unit))
+				Expect(families[0].Origins).To(Equal([]string{valueMetric1Origin}))
+				Expect(families[0].LabelNames).To(ConsistOf("origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "unit", tag1NameNormalized))
+			})
+		})
+
+		Context("when BOSH instances are known", func() {
+			BeforeEach(func() {
+				boshInstances = boshdirector.NewCache(nil, time.Minute)
+				boshInstances.Set(map[string]boshdirector.Instance{
+					boshdirector.InstanceKey(boshDeployment, boshIndex): {Deployment: boshDeployment, Job: boshJob, ID: boshIndex, Index: 3, AZ: "z1", VMCID: "fake-vm-cid", Stemcell: "fake-stemcell/1.0"},
+				})
+			})
+
+			It("returns the value metrics with the BOSH instance labels", func() {
+				enrichedValueMetric1 := prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						prometheus.BuildFQName(namespace, "value_metric", valueMetric1OriginNameNormalized+"_"+valueMetric1NameNormalized),
+						fmt.Sprintf("Cloud Foundry Firehose '%s' value metric from '%s'.", valueMetric1DescNormalized, valueMetric1OriginDescNormalized),
+						[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "unit", "bosh_instance_name", "bosh_az", "bosh_vm_cid", "bosh_stemcell", tag1NameNormalized},
+						prometheus.Labels{"environment": environment},
+					),
+					prometheus.GaugeValue,
+					valueMetric1Value,
+					valueMetric1Origin,
+					boshDeployment,
+					boshJob,
+					boshIndex,
+					boshIP,
+					valueMetric1Unit,
+					boshJob+"/3",
+					"z1",
+					"fake-vm-cid",
+					"fake-stemcell/1.0",
+					tag1Value,
+				)
+
+				Eventually(valueMetricsChan).Should(Receive(PrometheusMetric(enrichedValueMetric1)))
+			})
+		})
+
+		Context("when a naming template matches", func() {
+			BeforeEach(func() {
+				var err error
+				namer, err = naming.NewNamer(namespace, []naming.Template{
+					{Origin: regexp.QuoteMeta(valueMetric1Origin), Template: "cf_fake_{name}"},
+				})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns the value metric with the templated name", func() {
+				templatedValueMetric1 := prometheus.MustNewConstMetric(
+					prometheus.NewDesc(
+						"cf_fake_"+valueMetric1NameNormalized,
+						fmt.Sprintf("Cloud Foundry Firehose '%s' value metric from '%s'.", valueMetric1DescNormalized, valueMetric1OriginDescNormalized),
+						[]string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "unit", tag1NameNormalized},
+						prometheus.Labels{"environment": environment},
+					),
+					prometheus.GaugeValue,
+					valueMetric1Value,
+					valueMetric1Origin,
+					boshDeployment,
+					boshJob,
+					boshIndex,
+					boshIP,
+					valueMetric1Unit,
+					tag1Value,
+				)
+
+				Eventually(valueMetricsChan).Should(Receive(PrometheusMetric(templatedValueMetric1)))
+			})
+
+			It("keeps the default name of the other value metrics", func() {
+				Eventually(valueMetricsChan).Should(Receive(PrometheusMetric(valueMetric2)))
+			})
+		})
+
+		Context("when a value metric is mapped", func() {
+			BeforeEach(func() {
+				var err error
+				mapper, err = mappings.NewMapper([]mappings.Mapping{
+					{Origin: valueMetric1Origin, Name: valueMetric1Name, Type: mappings.TypeGauge, Target: "fake_value_metric"},
+				})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("only returns the unmapped metrics", func() {
+				Eventually(valueMetricsChan).Should(Receive(PrometheusMetric(valueMetric2)))
+				Consistently(valueMetricsChan).ShouldNot(Receive())
+			})
+		})
+
 		Context("when there is no value metrics", func() {
 			BeforeEach(func() {
 				metricsStore.FlushValueMetrics()
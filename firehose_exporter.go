@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/cloudfoundry-incubator/uaago"
 	"github.com/prometheus/client_golang/prometheus"
@@ -13,13 +16,24 @@ import (
 	"github.com/prometheus/common/version"
 	"gopkg.in/alecthomas/kingpin.v2"
 
+	"github.com/bosh-prometheus/firehose_exporter/aggregation"
+	"github.com/bosh-prometheus/firehose_exporter/api"
 	"github.com/bosh-prometheus/firehose_exporter/authclient"
+	"github.com/bosh-prometheus/firehose_exporter/boshdirector"
+	"github.com/bosh-prometheus/firehose_exporter/cloudcontroller"
 	"github.com/bosh-prometheus/firehose_exporter/collectors"
 	"github.com/bosh-prometheus/firehose_exporter/filters"
 	"github.com/bosh-prometheus/firehose_exporter/firehosenozzle"
 	"github.com/bosh-prometheus/firehose_exporter/logstream"
+	"github.com/bosh-prometheus/firehose_exporter/mappings"
+	"github.com/bosh-prometheus/firehose_exporter/metadata"
 	"github.com/bosh-prometheus/firehose_exporter/metrics"
+	"github.com/bosh-prometheus/firehose_exporter/naming"
+	"github.com/bosh-prometheus/firehose_exporter/relabel"
+	"github.com/bosh-prometheus/firehose_exporter/routes"
+	"github.com/bosh-prometheus/firehose_exporter/tagpolicy"
 	"github.com/bosh-prometheus/firehose_exporter/uaatokenrefresher"
+	"github.com/bosh-prometheus/firehose_exporter/utils"
 )
 
 var (
@@ -87,6 +101,154 @@ var (
 		"metrics.cleanup-interval", "Metrics clean up interval ($FIREHOSE_EXPORTER_METRICS_CLEANUP_INTERVAL)",
 	).Envar("FIREHOSE_EXPORTER_METRICS_CLEANUP_INTERVAL").Default("2m").Duration()
 
+	metricsMaxCachedSeries = kingpin.Flag(
+		"metrics.max-cached-series", "Maximum number of series cached per event type, 0 means unlimited ($FIREHOSE_EXPORTER_METRICS_MAX_CACHED_SERIES)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_MAX_CACHED_SERIES").Default("0").Int()
+
+	metricsDedupWindow = kingpin.Flag(
+		"metrics.dedup-window", "Number of recent envelopes remembered to suppress duplicates after reconnects, 0 disables deduplication ($FIREHOSE_EXPORTER_METRICS_DEDUP_WINDOW)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_DEDUP_WINDOW").Default("0").Int()
+
+	metricsDiscardStaleEnvelopes = kingpin.Flag(
+		"metrics.discard-stale-envelopes", "Discard envelopes older than the sample already cached for the same series ($FIREHOSE_EXPORTER_METRICS_DISCARD_STALE_ENVELOPES)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_DISCARD_STALE_ENVELOPES").Default("false").Bool()
+
+	metricsStaleEnvelopesTolerance = kingpin.Flag(
+		"metrics.stale-envelopes-tolerance", "How much older than the cached sample an envelope can be before being discarded ($FIREHOSE_EXPORTER_METRICS_STALE_ENVELOPES_TOLERANCE)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_STALE_ENVELOPES_TOLERANCE").Default("0s").Duration()
+
+	metricsHttpStartStopDurationBuckets = kingpin.Flag(
+		"metrics.http-start-stop-duration-buckets", "Comma separated buckets, in seconds, of the http start stop request duration histograms ($FIREHOSE_EXPORTER_METRICS_HTTP_START_STOP_DURATION_BUCKETS)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_HTTP_START_STOP_DURATION_BUCKETS").Default("0.005,0.01,0.025,0.05,0.1,0.25,0.5,1,2.5,5,10").String()
+
+	metricsHttpStartStopResponseSizeBuckets = kingpin.Flag(
+		"metrics.http-start-stop-response-size-buckets", "Comma separated buckets, in bytes, of the http start stop response size histogram ($FIREHOSE_EXPORTER_METRICS_HTTP_START_STOP_RESPONSE_SIZE_BUCKETS)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_HTTP_START_STOP_RESPONSE_SIZE_BUCKETS").Default("100,1000,10000,100000,1000000,10000000").String()
+
+	metricsHttpStartStopSeriesExpiration = kingpin.Flag(
+		"metrics.http-start-stop-series-expiration", "How long http start stop series of an application are kept after its last request ($FIREHOSE_EXPORTER_METRICS_HTTP_START_STOP_SERIES_EXPIRATION)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_HTTP_START_STOP_SERIES_EXPIRATION").Default("1h").Duration()
+
+	metricsHttpStartStopRoutesConfig = kingpin.Flag(
+		"metrics.http-start-stop-routes-config", "Path to a YAML file with the rules mapping http start stop request paths to route templates ($FIREHOSE_EXPORTER_METRICS_HTTP_START_STOP_ROUTES_CONFIG)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_HTTP_START_STOP_ROUTES_CONFIG").Default("").String()
+
+	metricsHttpStartStopMaxRoutesPerApp = kingpin.Flag(
+		"metrics.http-start-stop-max-routes-per-app", "Maximum number of routes reported per application, further routes are reported as `other`. 0 means unlimited ($FIREHOSE_EXPORTER_METRICS_HTTP_START_STOP_MAX_ROUTES_PER_APP)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_HTTP_START_STOP_MAX_ROUTES_PER_APP").Default("100").Int()
+
+	metricsHttpStartStopDropLabels = kingpin.Flag(
+		"metrics.http-start-stop-drop-labels", "Comma separated labels to drop from http start stop metrics, aggregating their series (`application_name`, `space_name`, `organization_name`, `instance_id`, `method`, `scheme`, `host`, `route`, `status_code`) ($FIREHOSE_EXPORTER_METRICS_HTTP_START_STOP_DROP_LABELS)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_HTTP_START_STOP_DROP_LABELS").Default("").String()
+
+	metricsHttpStartStopStatusCodeClasses = kingpin.Flag(
+		"metrics.http-start-stop-status-code-classes", "Collapse http start stop status codes into classes (2xx, 4xx, 5xx, ...) ($FIREHOSE_EXPORTER_METRICS_HTTP_START_STOP_STATUS_CODE_CLASSES)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_HTTP_START_STOP_STATUS_CODE_CLASSES").Default("false").Bool()
+
+	metricsHttpStartStopTopApplications = kingpin.Flag(
+		"metrics.http-start-stop-top-applications", "Number of top applications by requests, and by server errors, keeping full http start stop detail, the others being folded into `other`. `0` keeps all applications ($FIREHOSE_EXPORTER_METRICS_HTTP_START_STOP_TOP_APPLICATIONS)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_HTTP_START_STOP_TOP_APPLICATIONS").Default("0").Int()
+
+	metricsHttpStartStopTopApplicationsWindow = kingpin.Flag(
+		"metrics.http-start-stop-top-applications-window", "Rolling window the top applications are computed over ($FIREHOSE_EXPORTER_METRICS_HTTP_START_STOP_TOP_APPLICATIONS_WINDOW)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_HTTP_START_STOP_TOP_APPLICATIONS_WINDOW").Default("1h").Duration()
+
+	metricsHttpStartStopJoinTimeout = kingpin.Flag(
+		"metrics.http-start-stop-join-timeout", "How long an http start stop client or server peer waits for the other one before being flushed, 0 keeps it until it expires ($FIREHOSE_EXPORTER_METRICS_HTTP_START_STOP_JOIN_TIMEOUT)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_HTTP_START_STOP_JOIN_TIMEOUT").Default("0s").Duration()
+
+	metricsValueMetricsConvertUnits = kingpin.Flag(
+		"metrics.value-metrics-convert-units", "Convert value metrics reported in known units to Prometheus base units, appending the unit suffix to the metric name ($FIREHOSE_EXPORTER_METRICS_VALUE_METRICS_CONVERT_UNITS)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_VALUE_METRICS_CONVERT_UNITS").Default("false").Bool()
+
+	metricsValueMetricsUnitConversionsConfig = kingpin.Flag(
+		"metrics.value-metrics-unit-conversions-config", "Path to a YAML file with unit conversions extending or overriding the built-in ones. Requires metrics.value-metrics-convert-units ($FIREHOSE_EXPORTER_METRICS_VALUE_METRICS_UNIT_CONVERSIONS_CONFIG)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_VALUE_METRICS_UNIT_CONVERSIONS_CONFIG").Default("").String()
+
+	metricsMappingsConfig = kingpin.Flag(
+		"metrics.mappings-config", "Path to a YAML file declaring the Prometheus type, name and labels of matching counter events and value metrics ($FIREHOSE_EXPORTER_METRICS_MAPPINGS_CONFIG)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_MAPPINGS_CONFIG").Default("").String()
+
+	metricsMappingsSeriesExpiration = kingpin.Flag(
+		"metrics.mappings-series-expiration", "How long mapped summary and histogram series are kept after their last observation ($FIREHOSE_EXPORTER_METRICS_MAPPINGS_SERIES_EXPIRATION)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_MAPPINGS_SERIES_EXPIRATION").Default("1h").Duration()
+
+	metricsNamingConfig = kingpin.Flag(
+		"metrics.naming-config", "Path to a YAML file with naming templates for the counter events and value metrics of matching origins ($FIREHOSE_EXPORTER_METRICS_NAMING_CONFIG)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_NAMING_CONFIG").Default("").String()
+
+	metricsRelabelConfig = kingpin.Flag(
+		"metrics.relabel-config", "Path to a YAML file with Prometheus `relabel_configs` applied to all series before exposition ($FIREHOSE_EXPORTER_METRICS_RELABEL_CONFIG)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_RELABEL_CONFIG").Default("").String()
+
+	metricsAggregationConfig = kingpin.Flag(
+		"metrics.aggregation-config", "Path to a YAML file with aggregation rules (sum, avg, min, max or count by labels) evaluated over the series before exposition ($FIREHOSE_EXPORTER_METRICS_AGGREGATION_CONFIG)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_AGGREGATION_CONFIG").Default("").String()
+
+	metricsDescribeCatalog = kingpin.Flag(
+		"metrics.describe-catalog", "Describe the counter events and value metrics families observed so far instead of a placeholder descriptor ($FIREHOSE_EXPORTER_METRICS_DESCRIBE_CATALOG)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_DESCRIBE_CATALOG").Default("false").Bool()
+
+	metricsTagsAllowlist = kingpin.Flag(
+		"metrics.tags-allowlist", "Comma separated envelope tags reported as labels. Container and http start stop metrics only report these tags ($FIREHOSE_EXPORTER_METRICS_TAGS_ALLOWLIST)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_TAGS_ALLOWLIST").Default("").String()
+
+	metricsTagsDenylist = kingpin.Flag(
+		"metrics.tags-denylist", "Comma separated envelope tags not reported as labels ($FIREHOSE_EXPORTER_METRICS_TAGS_DENYLIST)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_TAGS_DENYLIST").Default("").String()
+
+	metricsTagsRenames = kingpin.Flag(
+		"metrics.tags-renames", "Comma separated `tag=label` envelope tags renames ($FIREHOSE_EXPORTER_METRICS_TAGS_RENAMES)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_TAGS_RENAMES").Default("").String()
+
+	metricsTagsMaxValueLength = kingpin.Flag(
+		"metrics.tags-max-value-length", "Maximum length in bytes of envelope tags label values, longer values are truncated. 0 means unlimited ($FIREHOSE_EXPORTER_METRICS_TAGS_MAX_VALUE_LENGTH)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_TAGS_MAX_VALUE_LENGTH").Default("0").Int()
+
+	metricsSnapshotPath = kingpin.Flag(
+		"metrics.snapshot-path", "Path to a file where the metrics store is periodically saved and restored from at startup ($FIREHOSE_EXPORTER_METRICS_SNAPSHOT_PATH)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_SNAPSHOT_PATH").Default("").String()
+
+	metricsSnapshotInterval = kingpin.Flag(
+		"metrics.snapshot-interval", "Metrics store snapshot interval ($FIREHOSE_EXPORTER_METRICS_SNAPSHOT_INTERVAL)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_SNAPSHOT_INTERVAL").Default("1m").Duration()
+
+	metricsCounterEventsMonotonic = kingpin.Flag(
+		"metrics.counter-events-monotonic", "Report counter events totals as exporter-side monotonic counters built from deltas ($FIREHOSE_EXPORTER_METRICS_COUNTER_EVENTS_MONOTONIC)",
+	).Envar("FIREHOSE_EXPORTER_METRICS_COUNTER_EVENTS_MONOTONIC").Default("false").Bool()
+
+	cfAPIURL = kingpin.Flag(
+		"cf.api-url", "Cloud Foundry API URL used to enrich metrics with application, space and organization names. If not set, metrics are not enriched ($FIREHOSE_EXPORTER_CF_API_URL)",
+	).Envar("FIREHOSE_EXPORTER_CF_API_URL").Default("").String()
+
+	cfApplicationsRefreshInterval = kingpin.Flag(
+		"cf.applications-refresh-interval", "Cloud Foundry applications refresh interval ($FIREHOSE_EXPORTER_CF_APPLICATIONS_REFRESH_INTERVAL)",
+	).Envar("FIREHOSE_EXPORTER_CF_APPLICATIONS_REFRESH_INTERVAL").Default("5m").Duration()
+
+	boshURL = kingpin.Flag(
+		"bosh.url", "BOSH director URL used to enrich metrics with BOSH instance names, AZs, VM CIDs and stemcells. If not set, metrics are not enriched ($FIREHOSE_EXPORTER_BOSH_URL)",
+	).Envar("FIREHOSE_EXPORTER_BOSH_URL").Default("").String()
+
+	boshUAAURL = kingpin.Flag(
+		"bosh.uaa-url", "BOSH director UAA URL ($FIREHOSE_EXPORTER_BOSH_UAA_URL)",
+	).Envar("FIREHOSE_EXPORTER_BOSH_UAA_URL").Default("").String()
+
+	boshUAAClientID = kingpin.Flag(
+		"bosh.uaa-client-id", "BOSH director UAA Client ID ($FIREHOSE_EXPORTER_BOSH_UAA_CLIENT_ID)",
+	).Envar("FIREHOSE_EXPORTER_BOSH_UAA_CLIENT_ID").Default("").String()
+
+	boshUAAClientSecret = kingpin.Flag(
+		"bosh.uaa-client-secret", "BOSH director UAA Client Secret ($FIREHOSE_EXPORTER_BOSH_UAA_CLIENT_SECRET)",
+	).Envar("FIREHOSE_EXPORTER_BOSH_UAA_CLIENT_SECRET").Default("").String()
+
+	boshInstancesRefreshInterval = kingpin.Flag(
+		"bosh.instances-refresh-interval", "BOSH director instances refresh interval ($FIREHOSE_EXPORTER_BOSH_INSTANCES_REFRESH_INTERVAL)",
+	).Envar("FIREHOSE_EXPORTER_BOSH_INSTANCES_REFRESH_INTERVAL").Default("5m").Duration()
+
+	boshInstanceLabels = kingpin.Flag(
+		"bosh.instance-labels", "Add the BOSH instance name, AZ, VM CID and stemcell as labels to the value metrics and counter events, besides the bosh_instance_info metric. Requires bosh.url ($FIREHOSE_EXPORTER_BOSH_INSTANCE_LABELS)",
+	).Envar("FIREHOSE_EXPORTER_BOSH_INSTANCE_LABELS").Default("false").Bool()
+
 	skipSSLValidation = kingpin.Flag(
 		"skip-ssl-verify", "Disable SSL Verify ($FIREHOSE_EXPORTER_SKIP_SSL_VERIFY)",
 	).Envar("FIREHOSE_EXPORTER_SKIP_SSL_VERIFY").Default("false").Bool()
@@ -144,20 +306,9 @@ func (h *basicAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
-func prometheusHandler() http.Handler {
-	handler := promhttp.InstrumentMetricHandler(
-		prometheus.DefaultRegisterer,
-		promhttp.HandlerFor(
-			prometheus.DefaultGatherer,
-			promhttp.HandlerOpts{
-				ErrorLog:      logger{},
-				ErrorHandling: promhttp.ContinueOnError,
-			},
-		),
-	)
-
+func authHandler(handler http.Handler) http.Handler {
 	if *authUsername != "" && *authPassword != "" {
-		handler = &basicAuthHandler{
+		return &basicAuthHandler{
 			handler:  handler.ServeHTTP,
 			username: *authUsername,
 			password: *authPassword,
@@ -167,6 +318,49 @@ func prometheusHandler() http.Handler {
 	return handler
 }
 
+func newTagPolicy() (*tagpolicy.Policy, error) {
+	var allowlist, denylist []string
+	if *metricsTagsAllowlist != "" {
+		for _, tag := range strings.Split(*metricsTagsAllowlist, ",") {
+			allowlist = append(allowlist, strings.TrimSpace(tag))
+		}
+	}
+	if *metricsTagsDenylist != "" {
+		for _, tag := range strings.Split(*metricsTagsDenylist, ",") {
+			denylist = append(denylist, strings.TrimSpace(tag))
+		}
+	}
+
+	renames := map[string]string{}
+	if *metricsTagsRenames != "" {
+		for _, rename := range strings.Split(*metricsTagsRenames, ",") {
+			parts := strings.SplitN(rename, "=", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("Invalid tag rename `%s`, expected `tag=label`", rename)
+			}
+			renames[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	return tagpolicy.NewPolicy(allowlist, denylist, renames, *metricsTagsMaxValueLength)
+}
+
+func prometheusHandler(gatherer prometheus.Gatherer) http.Handler {
+	handler := promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(
+			gatherer,
+			promhttp.HandlerOpts{
+				ErrorLog:          logger{},
+				ErrorHandling:     promhttp.ContinueOnError,
+				EnableOpenMetrics: true,
+			},
+		),
+	)
+
+	return authHandler(handler)
+}
+
 func main() {
 	log.AddFlags(kingpin.CommandLine)
 	kingpin.Version(version.Print("firehose_exporter"))
@@ -193,30 +387,216 @@ func main() {
 	}
 
 	metricsStore := metrics.NewStore(*dopplerMetricExpiration, *metricsCleanupInterval, deploymentFilter, eventFilter)
+	metricsStore.SetMaxCachedSeries(*metricsMaxCachedSeries)
+	metricsStore.SetDedupWindow(*metricsDedupWindow)
+	metricsStore.SetHttpStartStopJoinTimeout(*metricsHttpStartStopJoinTimeout)
+	if *metricsDiscardStaleEnvelopes {
+		metricsStore.DiscardStaleEnvelopes(*metricsStaleEnvelopesTolerance)
+	}
 
-	if *useLegacyFirehose {
-		startLegacyFirehose(metricsStore)
-	} else {
-		startLogStream(metricsStore)
+	if *metricsSnapshotPath != "" {
+		restoreSnapshot(metricsStore)
+		startSnapshots(metricsStore)
+	}
+
+	uaa, err := uaago.NewClient(*uaaUrl)
+	if err != nil {
+		log.Errorln(fmt.Sprint("Failed connecting to Get token from UAA..", err), "")
+	}
+	authClient := authclient.NewHttp(uaa, *uaaClientID, *uaaClientSecret, *skipSSLValidation)
+
+	var applications *cloudcontroller.Cache
+	if *cfAPIURL != "" {
+		applications = cloudcontroller.NewCache(cloudcontroller.NewClient(*cfAPIURL, authClient), *cfApplicationsRefreshInterval)
+		applications.Start()
+	}
+
+	var boshInstances *boshdirector.Cache
+	if *boshURL != "" {
+		if *boshUAAURL == "" {
+			log.Error("BOSH director UAA URL is required to enrich metrics with BOSH instances")
+			os.Exit(1)
+		}
+		boshUAA, err := uaago.NewClient(*boshUAAURL)
+		if err != nil {
+			log.Errorf("Error creating BOSH director UAA client: %s", err.Error())
+			os.Exit(1)
+		}
+		boshAuthClient := authclient.NewHttp(boshUAA, *boshUAAClientID, *boshUAAClientSecret, *skipSSLValidation)
+		boshInstances = boshdirector.NewCache(boshdirector.NewClient(*boshURL, boshAuthClient), *boshInstancesRefreshInterval)
+		boshInstances.Start()
+
+		boshInstancesCollector := collectors.NewBoshInstancesCollector(*metricsNamespace, *metricsEnvironment, boshInstances)
+		prometheus.MustRegister(boshInstancesCollector)
+	}
+
+	var boshInstanceLabelsCache *boshdirector.Cache
+	if *boshInstanceLabels {
+		if *boshURL == "" {
+			log.Error("BOSH director URL is required to add BOSH instance labels")
+			os.Exit(1)
+		}
+		boshInstanceLabelsCache = boshInstances
 	}
 
 	internalMetricsCollector := collectors.NewInternalMetricsCollector(*metricsNamespace, *metricsEnvironment, metricsStore)
 	prometheus.MustRegister(internalMetricsCollector)
 
-	containerMetricsCollector := collectors.NewContainerMetricsCollector(*metricsNamespace, *metricsEnvironment, metricsStore)
+	tagPolicy, err := newTagPolicy()
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+
+	var namer *naming.Namer
+	if *metricsNamingConfig != "" {
+		namingConfig, err := naming.LoadConfig(*metricsNamingConfig)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		namer, err = naming.NewNamer(*metricsNamespace, namingConfig.NamingTemplates)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+	}
+
+	catalog := metadata.NewCatalog()
+
+	var mapper *mappings.Mapper
+	if *metricsMappingsConfig != "" {
+		mappingsConfig, err := mappings.LoadConfig(*metricsMappingsConfig)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		mapper, err = mappings.NewMapper(mappingsConfig.Mappings)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		mappingsCollector := collectors.NewMappingsCollector(*metricsNamespace, *metricsEnvironment, metricsStore, mapper, *metricsMappingsSeriesExpiration)
+		prometheus.MustRegister(mappingsCollector)
+	}
+
+	containerMetricsCollector, err := collectors.NewContainerMetricsCollector(*metricsNamespace, *metricsEnvironment, metricsStore, applications, tagPolicy)
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
 	prometheus.MustRegister(containerMetricsCollector)
 
-	counterEventsCollector := collectors.NewCounterEventsCollector(*metricsNamespace, *metricsEnvironment, metricsStore)
+	counterEventsCollector := collectors.NewCounterEventsCollector(*metricsNamespace, *metricsEnvironment, metricsStore, *metricsCounterEventsMonotonic, mapper, tagPolicy, namer, boshInstanceLabelsCache, catalog, *metricsDescribeCatalog)
 	prometheus.MustRegister(counterEventsCollector)
 
-	httpStartStopCollector := collectors.NewHttpStartStopCollector(*metricsNamespace, *metricsEnvironment, metricsStore)
+	httpStartStopDurationBuckets, err := utils.ParseBuckets(*metricsHttpStartStopDurationBuckets)
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+	httpStartStopResponseSizeBuckets, err := utils.ParseBuckets(*metricsHttpStartStopResponseSizeBuckets)
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+	var routeMatcher *routes.Matcher
+	if *metricsHttpStartStopRoutesConfig != "" {
+		routesConfig, err := routes.LoadConfig(*metricsHttpStartStopRoutesConfig)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		routeMatcher, err = routes.NewMatcher(routesConfig.Rules, *metricsHttpStartStopMaxRoutesPerApp)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+	}
+	var httpStartStopDropLabels []string
+	if *metricsHttpStartStopDropLabels != "" {
+		for _, label := range strings.Split(*metricsHttpStartStopDropLabels, ",") {
+			httpStartStopDropLabels = append(httpStartStopDropLabels, strings.TrimSpace(label))
+		}
+	}
+	httpStartStopCollector, err := collectors.NewHttpStartStopCollector(
+		*metricsNamespace,
+		*metricsEnvironment,
+		metricsStore,
+		httpStartStopDurationBuckets,
+		httpStartStopResponseSizeBuckets,
+		*metricsHttpStartStopSeriesExpiration,
+		routeMatcher,
+		applications,
+		httpStartStopDropLabels,
+		*metricsHttpStartStopStatusCodeClasses,
+		tagPolicy,
+		*metricsHttpStartStopTopApplications,
+		*metricsHttpStartStopTopApplicationsWindow,
+	)
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
 	prometheus.MustRegister(httpStartStopCollector)
 
-	valueMetricsCollector := collectors.NewValueMetricsCollector(*metricsNamespace, *metricsEnvironment, metricsStore)
+	var unitConversions map[string]utils.UnitConversion
+	if *metricsValueMetricsConvertUnits {
+		unitConversions = utils.UnitConversions
+		if *metricsValueMetricsUnitConversionsConfig != "" {
+			unitConversions, err = utils.LoadUnitConversions(*metricsValueMetricsUnitConversionsConfig)
+			if err != nil {
+				log.Error(err)
+				os.Exit(1)
+			}
+		}
+	} else if *metricsValueMetricsUnitConversionsConfig != "" {
+		log.Error("Unit conversions config requires value metrics unit conversion to be enabled")
+		os.Exit(1)
+	}
+	valueMetricsCollector := collectors.NewValueMetricsCollector(*metricsNamespace, *metricsEnvironment, metricsStore, unitConversions, mapper, tagPolicy, namer, boshInstanceLabelsCache, catalog, *metricsDescribeCatalog)
 	prometheus.MustRegister(valueMetricsCollector)
 
-	handler := prometheusHandler()
+	var gatherer prometheus.Gatherer = prometheus.DefaultGatherer
+	if *metricsRelabelConfig != "" {
+		relabelConfig, err := relabel.LoadConfig(*metricsRelabelConfig)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		relabeler, err := relabel.NewRelabeler(relabelConfig.RelabelConfigs)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		gatherer = relabel.NewGatherer(prometheus.DefaultGatherer, relabeler)
+	}
+	if *metricsAggregationConfig != "" {
+		aggregationConfig, err := aggregation.LoadConfig(*metricsAggregationConfig)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		aggregator, err := aggregation.NewAggregator(aggregationConfig.AggregationRules)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		gatherer = aggregation.NewGatherer(gatherer, aggregator)
+	}
+	gatherErrorsCollector := collectors.NewGatherErrorsCollector(*metricsNamespace, *metricsEnvironment, gatherer)
+	prometheus.MustRegister(gatherErrorsCollector)
+
+	if *useLegacyFirehose {
+		startLegacyFirehose(metricsStore)
+	} else {
+		startLogStream(metricsStore, authClient)
+	}
+
+	handler := prometheusHandler(gatherErrorsCollector)
 	http.Handle(*metricsPath, handler)
+	http.Handle("/api/v1/series", authHandler(api.NewSeriesHandler(metricsStore)))
+	http.Handle("/api/v1/metadata", authHandler(api.NewMetadataHandler(catalog)))
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
 				             <head><title>Cloud Foundry Firehose Exporter</title></head>
@@ -236,18 +616,13 @@ func main() {
 	}
 }
 
-func startLogStream(metricsStore *metrics.Store) {
-	uaa, err := uaago.NewClient(*uaaUrl)
-	if err != nil {
-		log.Errorln(fmt.Sprint("Failed connecting to Get token from UAA..", err), "")
-	}
-	ac := authclient.NewHttp(uaa, *uaaClientID, *uaaClientSecret, *skipSSLValidation)
+func startLogStream(metricsStore *metrics.Store, authClient *authclient.AuthClient) {
 	ls := logstream.New(
 		*loggingURL,
 		*skipSSLValidation,
 		*dopplerSubscriptionID,
 		metricsStore,
-		ac,
+		authClient,
 	)
 	go func() {
 		ls.Start()
@@ -282,3 +657,40 @@ func startLegacyFirehose(metricsStore *metrics.Store) {
 		os.Exit(1)
 	}()
 }
+
+func restoreSnapshot(metricsStore *metrics.Store) {
+	err := metricsStore.LoadSnapshot(*metricsSnapshotPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf("Error restoring metrics snapshot from `%s`: %s", *metricsSnapshotPath, err.Error())
+		}
+		return
+	}
+	log.Infoln("Restored metrics snapshot from", *metricsSnapshotPath)
+}
+
+func startSnapshots(metricsStore *metrics.Store) {
+	saveSnapshot := func() {
+		if err := metricsStore.SaveSnapshot(*metricsSnapshotPath); err != nil {
+			log.Errorf("Error saving metrics snapshot to `%s`: %s", *metricsSnapshotPath, err.Error())
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		ticker := time.NewTicker(*metricsSnapshotInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				saveSnapshot()
+			case <-signals:
+				saveSnapshot()
+				os.Exit(0)
+			}
+		}
+	}()
+}
@@ -52,7 +52,7 @@ func (a *V2Adapter) Firehose(subscriptionID string) chan *events.Envelope {
 	go func() {
 		for ctx.Err() == nil {
 			for _, e := range es() {
-				for _, v1e := range conversion.ToV1(e) {
+				for _, v1e := range toV1(e) {
 					msgs <- v1e
 				}
 			}
@@ -66,3 +66,48 @@ func (a *V2Adapter) Firehose(subscriptionID string) chan *events.Envelope {
 func (a *V2Adapter) Close() {
 	a.cancel()
 }
+
+// containerMetricGauges are the gauge metrics carried by v1 ContainerMetric
+// envelopes.
+var containerMetricGauges = map[string]bool{
+	"cpu":            true,
+	"memory":         true,
+	"disk":           true,
+	"memory_quota":   true,
+	"disk_quota":     true,
+	"instance_index": true,
+}
+
+// toV1 converts a v2 envelope to v1 envelopes. The gauge metrics of container
+// metric envelopes the v1 ContainerMetric envelope can not carry (such as
+// cpu_entitlement or log_rate) are converted to value metrics instead of
+// being lost.
+func toV1(e *loggregator_v2.Envelope) []*events.Envelope {
+	v1es := conversion.ToV1(e)
+	if len(v1es) != 1 || v1es[0].GetEventType() != events.Envelope_ContainerMetric {
+		return v1es
+	}
+
+	extraMetrics := map[string]*loggregator_v2.GaugeValue{}
+	for name, value := range e.GetGauge().GetMetrics() {
+		if !containerMetricGauges[name] {
+			extraMetrics[name] = value
+		}
+	}
+	if len(extraMetrics) == 0 {
+		return v1es
+	}
+
+	return append(v1es, conversion.ToV1(&loggregator_v2.Envelope{
+		Timestamp:      e.Timestamp,
+		SourceId:       e.SourceId,
+		InstanceId:     e.InstanceId,
+		DeprecatedTags: e.DeprecatedTags,
+		Tags:           e.Tags,
+		Message: &loggregator_v2.Envelope_Gauge{
+			Gauge: &loggregator_v2.Gauge{
+				Metrics: extraMetrics,
+			},
+		},
+	})...)
+}
@@ -6,6 +6,7 @@ import (
 	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
 	"context"
 	"github.com/bosh-prometheus/firehose_exporter/logstream"
+	"github.com/cloudfoundry/sonde-go/events"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -57,6 +58,53 @@ var _ = Describe("V2adapter", func() {
 		Eventually(messages).Should(Receive(Equal(expected[0])))
 	})
 
+	It("converts the extra metrics of container metric envelopes to value metrics", func() {
+		v2Env := &loggregator_v2.Envelope{
+			Timestamp:  time.Now().Unix(),
+			SourceId:   "test-source",
+			InstanceId: "1",
+			Tags:       map[string]string{"origin": "rep"},
+			Message: &loggregator_v2.Envelope_Gauge{
+				Gauge: &loggregator_v2.Gauge{
+					Metrics: map[string]*loggregator_v2.GaugeValue{
+						"cpu":             {Unit: "percentage", Value: 10},
+						"memory":          {Unit: "bytes", Value: 1024},
+						"disk":            {Unit: "bytes", Value: 2048},
+						"memory_quota":    {Unit: "bytes", Value: 4096},
+						"disk_quota":      {Unit: "bytes", Value: 8192},
+						"cpu_entitlement": {Unit: "percentage", Value: 50},
+						"log_rate":        {Unit: "B/s", Value: 100},
+					},
+				},
+			},
+		}
+
+		stubStreamer := newStubStreamer()
+		stubStreamer.envs = []*loggregator_v2.Envelope{v2Env}
+
+		firehoseAdapter := logstream.NewV2Adapter(stubStreamer)
+		defer firehoseAdapter.Close()
+		messages := firehoseAdapter.Firehose("test-subscription")
+
+		var containerMetric, valueMetric1, valueMetric2 *events.Envelope
+		Eventually(messages).Should(Receive(&containerMetric))
+		Eventually(messages).Should(Receive(&valueMetric1))
+		Eventually(messages).Should(Receive(&valueMetric2))
+
+		Expect(containerMetric.GetEventType()).To(Equal(events.Envelope_ContainerMetric))
+		Expect(containerMetric.GetContainerMetric().GetCpuPercentage()).To(Equal(float64(10)))
+
+		valueMetrics := map[string]float64{}
+		for _, valueMetric := range []*events.Envelope{valueMetric1, valueMetric2} {
+			Expect(valueMetric.GetEventType()).To(Equal(events.Envelope_ValueMetric))
+			Expect(valueMetric.GetOrigin()).To(Equal("rep"))
+			Expect(valueMetric.GetTags()).To(HaveKeyWithValue("source_id", "test-source"))
+			Expect(valueMetric.GetTags()).To(HaveKeyWithValue("instance_id", "1"))
+			valueMetrics[valueMetric.GetValueMetric().GetName()] = valueMetric.GetValueMetric().GetValue()
+		}
+		Expect(valueMetrics).To(Equal(map[string]float64{"cpu_entitlement": 50, "log_rate": 100}))
+	})
+
 	It("stops sending after close", func() {
 		v2Env := &loggregator_v2.Envelope{
 			Timestamp:  time.Now().Unix(),
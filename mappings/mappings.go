@@ -0,0 +1,249 @@
+package mappings
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	TypeGauge     = "gauge"
+	TypeCounter   = "counter"
+	TypeSummary   = "summary"
+	TypeHistogram = "histogram"
+
+	EventTypeCounterEvent = "CounterEvent"
+	EventTypeValueMetric  = "ValueMetric"
+
+	AggregationSum = "sum"
+	AggregationMax = "max"
+	AggregationMin = "min"
+)
+
+// LabelSources are the envelope fields a label can be mapped from, besides
+// tags (`tags.<tag name>`).
+var LabelSources = []string{"origin", "bosh_deployment", "bosh_job_name", "bosh_job_id", "bosh_job_ip", "unit"}
+
+const tagLabelSourcePrefix = "tags."
+
+type Config struct {
+	Mappings []Mapping `yaml:"mappings"`
+}
+
+// Mapping publishes the counter events and value metrics matching its
+// origin and name regular expressions as a typed Prometheus metric family.
+type Mapping struct {
+	EventType   string            `yaml:"event_type"`
+	Origin      string            `yaml:"origin"`
+	Name        string            `yaml:"name"`
+	Type        string            `yaml:"type"`
+	Target      string            `yaml:"target"`
+	Help        string            `yaml:"help"`
+	Labels      map[string]string `yaml:"labels"`
+	Buckets     []float64         `yaml:"buckets"`
+	Aggregation string            `yaml:"aggregation"`
+
+	originRegex *regexp.Regexp
+	nameRegex   *regexp.Regexp
+	labelNames  []string
+}
+
+type Mapper struct {
+	mappings []*Mapping
+}
+
+func LoadConfig(path string) (*Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(content, config); err != nil {
+		return nil, fmt.Errorf("Error parsing mappings config `%s`: %v", path, err)
+	}
+
+	return config, nil
+}
+
+func NewMapper(mappings []Mapping) (*Mapper, error) {
+	mapper := &Mapper{}
+	targets := map[string]*Mapping{}
+
+	for i := range mappings {
+		mapping := mappings[i]
+		if err := mapping.compile(); err != nil {
+			return nil, fmt.Errorf("Mapping %d: %v", i, err)
+		}
+
+		if previous, ok := targets[mapping.Target]; ok {
+			if previous.Type != mapping.Type || !reflect.DeepEqual(previous.labelNames, mapping.labelNames) || !reflect.DeepEqual(previous.Buckets, mapping.Buckets) || previous.Aggregation != mapping.Aggregation {
+				return nil, fmt.Errorf("Mapping %d: target `%s` is already mapped with a different type, labels, buckets or aggregation", i, mapping.Target)
+			}
+		}
+		targets[mapping.Target] = &mapping
+
+		mapper.mappings = append(mapper.mappings, &mapping)
+	}
+
+	return mapper, nil
+}
+
+func (m *Mapping) compile() error {
+	switch m.EventType {
+	case "", EventTypeCounterEvent, EventTypeValueMetric:
+	default:
+		return fmt.Errorf("event type `%s` is not supported", m.EventType)
+	}
+
+	switch m.Type {
+	case TypeGauge, TypeCounter, TypeSummary, TypeHistogram:
+	default:
+		return fmt.Errorf("type `%s` is not supported", m.Type)
+	}
+
+	if !model.IsValidMetricName(model.LabelValue(m.Target)) {
+		return fmt.Errorf("target `%s` is not a valid metric name", m.Target)
+	}
+
+	if m.Type != TypeHistogram && len(m.Buckets) > 0 {
+		return fmt.Errorf("buckets are only supported by histograms")
+	}
+	if !sort.Float64sAreSorted(m.Buckets) {
+		return fmt.Errorf("buckets must be in increasing order")
+	}
+
+	switch {
+	case m.Type != TypeGauge && m.Type != TypeCounter:
+		if m.Aggregation != "" {
+			return fmt.Errorf("aggregation is only supported by gauges and counters")
+		}
+	case m.Aggregation == "":
+		m.Aggregation = AggregationSum
+	case m.Type == TypeCounter && m.Aggregation != AggregationSum:
+		return fmt.Errorf("counters can only be aggregated with `%s`", AggregationSum)
+	case m.Aggregation != AggregationSum && m.Aggregation != AggregationMax && m.Aggregation != AggregationMin:
+		return fmt.Errorf("aggregation `%s` is not supported", m.Aggregation)
+	}
+
+	var err error
+	if m.originRegex, err = compileAnchored(m.Origin); err != nil {
+		return fmt.Errorf("invalid origin regex: %v", err)
+	}
+	if m.nameRegex, err = compileAnchored(m.Name); err != nil {
+		return fmt.Errorf("invalid name regex: %v", err)
+	}
+
+	m.labelNames = nil
+	for label, source := range m.Labels {
+		if !model.LabelName(label).IsValid() || label == "environment" {
+			return fmt.Errorf("label `%s` is not a valid label name", label)
+		}
+		if !validLabelSource(source) {
+			return fmt.Errorf("label `%s` source `%s` is not supported", label, source)
+		}
+		m.labelNames = append(m.labelNames, label)
+	}
+	sort.Strings(m.labelNames)
+
+	if m.Help == "" {
+		m.Help = fmt.Sprintf("Cloud Foundry Firehose metrics mapped to '%s'.", m.Target)
+	}
+
+	return nil
+}
+
+func compileAnchored(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+func validLabelSource(source string) bool {
+	if strings.HasPrefix(source, tagLabelSourcePrefix) && source != tagLabelSourcePrefix {
+		return true
+	}
+
+	for _, labelSource := range LabelSources {
+		if source == labelSource {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Mappings returns the mappings in the order they are evaluated.
+func (m *Mapper) Mappings() []*Mapping {
+	if m == nil {
+		return nil
+	}
+
+	return m.mappings
+}
+
+// Match returns the first mapping matching an event, or nil. A nil mapper
+// never matches.
+func (m *Mapper) Match(eventType string, origin string, name string) *Mapping {
+	if m == nil {
+		return nil
+	}
+
+	for _, mapping := range m.mappings {
+		if mapping.EventType != "" && mapping.EventType != eventType {
+			continue
+		}
+		if mapping.originRegex != nil && !mapping.originRegex.MatchString(origin) {
+			continue
+		}
+		if mapping.nameRegex != nil && !mapping.nameRegex.MatchString(name) {
+			continue
+		}
+		return mapping
+	}
+
+	return nil
+}
+
+// Aggregate combines the values of two events mapped to the same series,
+// as events can only be told apart by the labels the mapping keeps.
+func (m *Mapping) Aggregate(a float64, b float64) float64 {
+	switch m.Aggregation {
+	case AggregationMax:
+		return math.Max(a, b)
+	case AggregationMin:
+		return math.Min(a, b)
+	default:
+		return a + b
+	}
+}
+
+// LabelNames returns the names of the labels of the mapped metric, sorted.
+func (m *Mapping) LabelNames() []string {
+	return m.labelNames
+}
+
+// LabelValues returns the values of the labels of the mapped metric, in the
+// LabelNames order, from the envelope fields (keyed by LabelSources) and tags.
+func (m *Mapping) LabelValues(fields map[string]string, tags map[string]string) []string {
+	labelValues := make([]string, len(m.labelNames))
+	for i, label := range m.labelNames {
+		source := m.Labels[label]
+		if strings.HasPrefix(source, tagLabelSourcePrefix) && source != tagLabelSourcePrefix {
+			labelValues[i] = tags[strings.TrimPrefix(source, tagLabelSourcePrefix)]
+		} else {
+			labelValues[i] = fields[source]
+		}
+	}
+
+	return labelValues
+}
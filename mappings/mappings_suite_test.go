@@ -0,0 +1,13 @@
+package mappings_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestMappings(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Mappings Suite")
+}
@@ -0,0 +1,232 @@
+package mappings_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/firehose_exporter/mappings"
+)
+
+var _ = Describe("Mappings", func() {
+	Describe("LoadConfig", func() {
+		var (
+			configDir  string
+			configPath string
+		)
+
+		BeforeEach(func() {
+			var err error
+			configDir, err = ioutil.TempDir("", "mappings")
+			Expect(err).ToNot(HaveOccurred())
+			configPath = filepath.Join(configDir, "mappings.yml")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(configDir)
+		})
+
+		It("loads the mappings", func() {
+			Expect(ioutil.WriteFile(configPath, []byte(`
+mappings:
+- event_type: ValueMetric
+  origin: gorouter
+  name: latency
+  type: histogram
+  target: gorouter_latency_milliseconds
+  buckets: [10, 100, 1000]
+  labels:
+    deployment: bosh_deployment
+    component: tags.component
+`), 0644)).To(Succeed())
+
+			config, err := LoadConfig(configPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.Mappings).To(HaveLen(1))
+			Expect(config.Mappings[0].EventType).To(Equal(EventTypeValueMetric))
+			Expect(config.Mappings[0].Origin).To(Equal("gorouter"))
+			Expect(config.Mappings[0].Name).To(Equal("latency"))
+			Expect(config.Mappings[0].Type).To(Equal(TypeHistogram))
+			Expect(config.Mappings[0].Target).To(Equal("gorouter_latency_milliseconds"))
+			Expect(config.Mappings[0].Buckets).To(Equal([]float64{10, 100, 1000}))
+			Expect(config.Mappings[0].Labels).To(Equal(map[string]string{"deployment": "bosh_deployment", "component": "tags.component"}))
+		})
+
+		It("returns an error on unknown fields", func() {
+			Expect(ioutil.WriteFile(configPath, []byte("mappings:\n- metric: foo\n"), 0644)).To(Succeed())
+
+			_, err := LoadConfig(configPath)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error when the file does not exist", func() {
+			_, err := LoadConfig(filepath.Join(configDir, "missing.yml"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("NewMapper", func() {
+		It("returns an error on an unsupported type", func() {
+			_, err := NewMapper([]Mapping{{Type: "untyped", Target: "foo"}})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error on an unsupported event type", func() {
+			_, err := NewMapper([]Mapping{{EventType: "ContainerMetric", Type: TypeGauge, Target: "foo"}})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error on an invalid target", func() {
+			_, err := NewMapper([]Mapping{{Type: TypeGauge, Target: "foo-bar"}})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error on an invalid regex", func() {
+			_, err := NewMapper([]Mapping{{Name: "(", Type: TypeGauge, Target: "foo"}})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error on buckets for a non histogram", func() {
+			_, err := NewMapper([]Mapping{{Type: TypeSummary, Target: "foo", Buckets: []float64{1, 2}}})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error on unsorted buckets", func() {
+			_, err := NewMapper([]Mapping{{Type: TypeHistogram, Target: "foo", Buckets: []float64{2, 1}}})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error on an unsupported label source", func() {
+			_, err := NewMapper([]Mapping{{Type: TypeGauge, Target: "foo", Labels: map[string]string{"bar": "application_id"}}})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error on the environment label", func() {
+			_, err := NewMapper([]Mapping{{Type: TypeGauge, Target: "foo", Labels: map[string]string{"environment": "origin"}}})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error when a target is mapped with different types", func() {
+			_, err := NewMapper([]Mapping{
+				{Name: "a", Type: TypeGauge, Target: "foo"},
+				{Name: "b", Type: TypeCounter, Target: "foo"},
+			})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error when a target is mapped with different labels", func() {
+			_, err := NewMapper([]Mapping{
+				{Name: "a", Type: TypeGauge, Target: "foo", Labels: map[string]string{"origin": "origin"}},
+				{Name: "b", Type: TypeGauge, Target: "foo"},
+			})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error on an unsupported aggregation", func() {
+			_, err := NewMapper([]Mapping{{Type: TypeGauge, Target: "foo", Aggregation: "avg"}})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error on a counter not aggregated with sum", func() {
+			_, err := NewMapper([]Mapping{{Type: TypeCounter, Target: "foo", Aggregation: AggregationMax}})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error on an aggregation for a histogram", func() {
+			_, err := NewMapper([]Mapping{{Type: TypeHistogram, Target: "foo", Aggregation: AggregationSum}})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error when a target is mapped with different aggregations", func() {
+			_, err := NewMapper([]Mapping{
+				{Name: "a", Type: TypeGauge, Target: "foo", Aggregation: AggregationMax},
+				{Name: "b", Type: TypeGauge, Target: "foo"},
+			})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("accepts a target mapped several times consistently", func() {
+			_, err := NewMapper([]Mapping{
+				{Name: "a", Type: TypeGauge, Target: "foo", Labels: map[string]string{"origin": "origin"}},
+				{Name: "b", Type: TypeGauge, Target: "foo", Labels: map[string]string{"origin": "origin"}},
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Describe("Match", func() {
+		var mapper *Mapper
+
+		BeforeEach(func() {
+			var err error
+			mapper, err = NewMapper([]Mapping{
+				{EventType: EventTypeCounterEvent, Origin: "gorouter", Name: "total_requests", Type: TypeCounter, Target: "gorouter_requests_total"},
+				{Origin: "gorouter", Name: "latency.*", Type: TypeSummary, Target: "gorouter_latency"},
+				{Name: "latency", Type: TypeHistogram, Target: "latency"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("matches on event type, origin and name", func() {
+			mapping := mapper.Match(EventTypeCounterEvent, "gorouter", "total_requests")
+			Expect(mapping).ToNot(BeNil())
+			Expect(mapping.Target).To(Equal("gorouter_requests_total"))
+
+			Expect(mapper.Match(EventTypeValueMetric, "gorouter", "total_requests")).To(BeNil())
+			Expect(mapper.Match(EventTypeCounterEvent, "doppler", "total_requests")).To(BeNil())
+		})
+
+		It("anchors the regular expressions", func() {
+			Expect(mapper.Match(EventTypeCounterEvent, "gorouter", "total_requests_failed")).To(BeNil())
+			Expect(mapper.Match(EventTypeValueMetric, "my_gorouter", "other")).To(BeNil())
+		})
+
+		It("returns the first matching mapping", func() {
+			Expect(mapper.Match(EventTypeValueMetric, "gorouter", "latency").Target).To(Equal("gorouter_latency"))
+			Expect(mapper.Match(EventTypeValueMetric, "doppler", "latency").Target).To(Equal("latency"))
+		})
+
+		It("never matches with a nil mapper", func() {
+			var nilMapper *Mapper
+			Expect(nilMapper.Match(EventTypeValueMetric, "gorouter", "latency")).To(BeNil())
+			Expect(nilMapper.Mappings()).To(BeEmpty())
+		})
+	})
+
+	Describe("Aggregate", func() {
+		It("sums by default", func() {
+			mapper, err := NewMapper([]Mapping{{Type: TypeGauge, Target: "foo"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mapper.Mappings()[0].Aggregate(1, 2)).To(Equal(float64(3)))
+		})
+
+		It("keeps the maximum or the minimum", func() {
+			mapper, err := NewMapper([]Mapping{
+				{Name: "a", Type: TypeGauge, Target: "foo", Aggregation: AggregationMax},
+				{Name: "b", Type: TypeGauge, Target: "bar", Aggregation: AggregationMin},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mapper.Mappings()[0].Aggregate(1, 2)).To(Equal(float64(2)))
+			Expect(mapper.Mappings()[1].Aggregate(1, 2)).To(Equal(float64(1)))
+		})
+	})
+
+	Describe("LabelValues", func() {
+		It("returns the label values in label names order", func() {
+			mapper, err := NewMapper([]Mapping{
+				{Type: TypeGauge, Target: "foo", Labels: map[string]string{"origin": "origin", "component": "tags.component", "deployment": "bosh_deployment"}},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			mapping := mapper.Mappings()[0]
+			Expect(mapping.LabelNames()).To(Equal([]string{"component", "deployment", "origin"}))
+			Expect(mapping.LabelValues(
+				map[string]string{"origin": "fake-origin", "bosh_deployment": "fake-deployment"},
+				map[string]string{"component": "fake-component"},
+			)).To(Equal([]string{"fake-component", "fake-deployment", "fake-origin"}))
+		})
+	})
+})
@@ -0,0 +1,161 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	TypeCounter = "counter"
+	TypeGauge   = "gauge"
+)
+
+// Family is the metadata of an observed metric family.
+type Family struct {
+	Name       string    `json:"name"`
+	Source     string    `json:"source"`
+	Type       string    `json:"type"`
+	Help       string    `json:"help"`
+	Unit       string    `json:"unit,omitempty"`
+	LabelNames []string  `json:"label_names"`
+	Origins    []string  `json:"origins"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// Observation describes a series about to be exposed by a collector.
+type Observation struct {
+	Source     string
+	Name       string
+	Type       string
+	Unit       string
+	Origin     string
+	LabelNames []string
+}
+
+type family struct {
+	Family
+	labelNames map[string]bool
+	origins    map[string]bool
+	descs      map[string]*prometheus.Desc
+	lastDesc   *prometheus.Desc
+}
+
+// Catalog records the metric families exposed by the dynamic collectors, and
+// hands out their descriptors. The help of a family is the one of its first
+// observation, so it is built once and stays stable across scrapes.
+type Catalog struct {
+	mutex    sync.Mutex
+	families map[string]*family
+}
+
+func NewCatalog() *Catalog {
+	return &Catalog{
+		families: map[string]*family{},
+	}
+}
+
+// Desc records an observation and returns the descriptor of its series. It
+// returns an error if the family was already observed with another type or
+// from another source. Without catalog, a new descriptor is built.
+func (c *Catalog) Desc(observation Observation, constLabels prometheus.Labels, help func() string) (*prometheus.Desc, error) {
+	if c == nil {
+		return prometheus.NewDesc(observation.Name, help(), observation.LabelNames, constLabels), nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	f, ok := c.families[observation.Name]
+	if !ok {
+		f = &family{
+			Family: Family{
+				Name:      observation.Name,
+				Source:    observation.Source,
+				Type:      observation.Type,
+				Help:      help(),
+				Unit:      observation.Unit,
+				FirstSeen: now,
+			},
+			labelNames: map[string]bool{},
+			origins:    map[string]bool{},
+			descs:      map[string]*prometheus.Desc{},
+		}
+		c.families[observation.Name] = f
+	}
+
+	if f.Type != observation.Type || f.Source != observation.Source {
+		return nil, fmt.Errorf("metric family `%s` was already observed as a %s %s", observation.Name, f.Source, f.Type)
+	}
+
+	f.LastSeen = now
+	for _, label := range observation.LabelNames {
+		if !f.labelNames[label] {
+			f.labelNames[label] = true
+			f.LabelNames = append(f.LabelNames, label)
+			sort.Strings(f.LabelNames)
+		}
+	}
+	if observation.Origin != "" && !f.origins[observation.Origin] {
+		f.origins[observation.Origin] = true
+		f.Origins = append(f.Origins, observation.Origin)
+		sort.Strings(f.Origins)
+	}
+
+	descKey := strings.Join(observation.LabelNames, "\xff")
+	desc, ok := f.descs[descKey]
+	if !ok {
+		desc = prometheus.NewDesc(observation.Name, f.Help, observation.LabelNames, constLabels)
+		f.descs[descKey] = desc
+	}
+	f.lastDesc = desc
+
+	return desc, nil
+}
+
+// Descs sends the last descriptor handed out for each family of a source.
+func (c *Catalog) Descs(source string, ch chan<- *prometheus.Desc) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	descs := make([]*prometheus.Desc, 0, len(c.families))
+	for _, f := range c.families {
+		if f.Source == source {
+			descs = append(descs, f.lastDesc)
+		}
+	}
+	c.mutex.Unlock()
+
+	for _, desc := range descs {
+		ch <- desc
+	}
+}
+
+// Families returns a copy of the observed families, sorted by name.
+func (c *Catalog) Families() []Family {
+	if c == nil {
+		return []Family{}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	families := make([]Family, 0, len(c.families))
+	for _, f := range c.families {
+		family := f.Family
+		family.LabelNames = append([]string{}, f.LabelNames...)
+		family.Origins = append([]string{}, f.Origins...)
+		families = append(families, family)
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].Name < families[j].Name })
+
+	return families
+}
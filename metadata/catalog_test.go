@@ -0,0 +1,126 @@
+package metadata_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	. "github.com/bosh-prometheus/firehose_exporter/metadata"
+)
+
+var _ = Describe("Catalog", func() {
+	var (
+		catalog     *Catalog
+		constLabels prometheus.Labels
+		observation Observation
+		helpCalls   int
+		help        func() string
+	)
+
+	BeforeEach(func() {
+		catalog = NewCatalog()
+		constLabels = prometheus.Labels{"environment": "test_environment"}
+		observation = Observation{
+			Source:     "value_metric",
+			Name:       "firehose_value_metric_fake_origin_fake_value_metric",
+			Type:       TypeGauge,
+			Unit:       "ms",
+			Origin:     "fake-origin",
+			LabelNames: []string{"origin", "bosh_job_name"},
+		}
+		helpCalls = 0
+		help = func() string {
+			helpCalls++
+			return "Fake help."
+		}
+	})
+
+	Describe("Desc", func() {
+		It("returns a descriptor and records the family", func() {
+			desc, err := catalog.Desc(observation, constLabels, help)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(desc).To(Equal(prometheus.NewDesc(observation.Name, "Fake help.", observation.LabelNames, constLabels)))
+
+			families := catalog.Families()
+			Expect(families).To(HaveLen(1))
+			Expect(families[0].Name).To(Equal(observation.Name))
+			Expect(families[0].Source).To(Equal("value_metric"))
+			Expect(families[0].Type).To(Equal(TypeGauge))
+			Expect(families[0].Help).To(Equal("Fake help."))
+			Expect(families[0].Unit).To(Equal("ms"))
+			Expect(families[0].LabelNames).To(Equal([]string{"bosh_job_name", "origin"}))
+			Expect(families[0].Origins).To(Equal([]string{"fake-origin"}))
+			Expect(families[0].FirstSeen).ToNot(BeZero())
+			Expect(families[0].LastSeen).To(Equal(families[0].FirstSeen))
+		})
+
+		It("builds the help once and reuses the descriptor", func() {
+			first, err := catalog.Desc(observation, constLabels, help)
+			Expect(err).ToNot(HaveOccurred())
+			second, err := catalog.Desc(observation, constLabels, help)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(second).To(BeIdenticalTo(first))
+			Expect(helpCalls).To(Equal(1))
+		})
+
+		It("merges the label names and origins of later observations", func() {
+			_, err := catalog.Desc(observation, constLabels, help)
+			Expect(err).ToNot(HaveOccurred())
+
+			observation.Origin = "other-origin"
+			observation.LabelNames = []string{"origin", "tag"}
+			_, err = catalog.Desc(observation, constLabels, help)
+			Expect(err).ToNot(HaveOccurred())
+
+			families := catalog.Families()
+			Expect(families[0].LabelNames).To(Equal([]string{"bosh_job_name", "origin", "tag"}))
+			Expect(families[0].Origins).To(Equal([]string{"fake-origin", "other-origin"}))
+			Expect(families[0].LastSeen).ToNot(BeTemporally("<", families[0].FirstSeen))
+		})
+
+		It("returns an error when the family was observed with another type", func() {
+			_, err := catalog.Desc(observation, constLabels, help)
+			Expect(err).ToNot(HaveOccurred())
+
+			observation.Type = TypeCounter
+			_, err = catalog.Desc(observation, constLabels, help)
+			Expect(err).To(MatchError(ContainSubstring("already observed as a value_metric gauge")))
+		})
+
+		Context("when there is no catalog", func() {
+			BeforeEach(func() {
+				catalog = nil
+			})
+
+			It("returns a new descriptor", func() {
+				desc, err := catalog.Desc(observation, constLabels, help)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(desc).To(Equal(prometheus.NewDesc(observation.Name, "Fake help.", observation.LabelNames, constLabels)))
+				Expect(catalog.Families()).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Descs", func() {
+		It("sends the last descriptor of each family of a source", func() {
+			_, err := catalog.Desc(observation, constLabels, help)
+			Expect(err).ToNot(HaveOccurred())
+
+			observation.LabelNames = []string{"origin"}
+			lastDesc, err := catalog.Desc(observation, constLabels, help)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = catalog.Desc(Observation{Source: "counter_event", Name: "firehose_counter_event_fake_total", Type: TypeCounter}, constLabels, help)
+			Expect(err).ToNot(HaveOccurred())
+
+			descs := make(chan *prometheus.Desc, 10)
+			catalog.Descs("value_metric", descs)
+			close(descs)
+
+			Expect(descs).To(Receive(Equal(lastDesc)))
+			Expect(descs).ToNot(Receive())
+		})
+	})
+})
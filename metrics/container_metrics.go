@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+	"github.com/patrickmn/go-cache"
+)
+
+// Loggregator v2 container metrics the v1 ContainerMetric envelope can not
+// carry. They reach the store as value metrics tagged with the application
+// (`source_id`) and instance index (`instance_id`) of the container.
+const (
+	ContainerMetricCpuEntitlement      = "cpu_entitlement"
+	ContainerMetricAbsoluteUsage       = "absolute_usage"
+	ContainerMetricAbsoluteEntitlement = "absolute_entitlement"
+	ContainerMetricContainerAge        = "container_age"
+	ContainerMetricLogRate             = "log_rate"
+	ContainerMetricLogRateLimit        = "log_rate_limit"
+)
+
+func (c *ContainerMetric) setV2Metric(name string, value float64) bool {
+	switch name {
+	case ContainerMetricCpuEntitlement:
+		c.CpuEntitlement = &value
+	case ContainerMetricAbsoluteUsage:
+		c.AbsoluteUsage = &value
+	case ContainerMetricAbsoluteEntitlement:
+		c.AbsoluteEntitlement = &value
+	case ContainerMetricContainerAge:
+		c.ContainerAge = &value
+	case ContainerMetricLogRate:
+		c.LogRate = &value
+	case ContainerMetricLogRateLimit:
+		c.LogRateLimit = &value
+	default:
+		return false
+	}
+
+	return true
+}
+
+func (c *ContainerMetric) mergeV2Metrics(from *ContainerMetric) {
+	if from.CpuEntitlement != nil {
+		c.CpuEntitlement = from.CpuEntitlement
+	}
+	if from.AbsoluteUsage != nil {
+		c.AbsoluteUsage = from.AbsoluteUsage
+	}
+	if from.AbsoluteEntitlement != nil {
+		c.AbsoluteEntitlement = from.AbsoluteEntitlement
+	}
+	if from.ContainerAge != nil {
+		c.ContainerAge = from.ContainerAge
+	}
+	if from.LogRate != nil {
+		c.LogRate = from.LogRate
+	}
+	if from.LogRateLimit != nil {
+		c.LogRateLimit = from.LogRateLimit
+	}
+}
+
+// containerMetricV2Key returns the key of the container metric a v2
+// container value metric belongs to.
+func (s *Store) containerMetricV2Key(envelope *events.Envelope) (string, bool) {
+	applicationId := envelope.GetTags()["source_id"]
+	instanceIndex, err := strconv.Atoi(envelope.GetTags()["instance_id"])
+	if applicationId == "" || err != nil {
+		return "", false
+	}
+
+	return s.metricKey(&events.Envelope{
+		Origin:     envelope.Origin,
+		EventType:  events.Envelope_ContainerMetric.Enum(),
+		Deployment: envelope.Deployment,
+		Job:        envelope.Job,
+		Index:      envelope.Index,
+		Ip:         envelope.Ip,
+		ContainerMetric: &events.ContainerMetric{
+			ApplicationId: proto.String(applicationId),
+			InstanceIndex: proto.Int32(int32(instanceIndex)),
+		},
+	}), true
+}
+
+// addContainerMetricV2 records a v2 container value metric, and merges it
+// into the cached container metric, if any. Container metrics received later
+// get the v2 metrics recorded so far. It returns false when the envelope is
+// not a v2 container metric, and must be added as a value metric.
+func (s *Store) addContainerMetricV2(envelope *events.Envelope) bool {
+	update := &ContainerMetric{}
+	if !update.setV2Metric(envelope.GetValueMetric().GetName(), envelope.GetValueMetric().GetValue()) {
+		return false
+	}
+
+	metricKey, ok := s.containerMetricV2Key(envelope)
+	if !ok {
+		return false
+	}
+
+	s.internalMetrics.IncrementInt64(TotalMetricsReceivedKey, 1)
+	s.internalMetrics.Set(LastMetricReceivedTimestampKey, time.Now().Unix(), cache.NoExpiration)
+	s.internalMetrics.IncrementInt64(TotalContainerMetricsReceivedKey, 1)
+	s.internalMetrics.Set(LastContainerMetricReceivedTimestampKey, time.Now().Unix(), cache.NoExpiration)
+
+	if _, ok := s.admitAt(envelope, s.containerMetricsV2, metricKey); !ok {
+		return true
+	}
+	s.internalMetrics.IncrementInt64(TotalContainerMetricsProcessedKey, 1)
+
+	containerMetricV2 := &ContainerMetric{}
+	if storeContainerMetricV2, ok := s.containerMetricsV2.Get(metricKey); ok {
+		*containerMetricV2 = *storeContainerMetricV2.(*ContainerMetric)
+	}
+	containerMetricV2.mergeV2Metrics(update)
+	if envelope.GetTimestamp() > containerMetricV2.Timestamp {
+		containerMetricV2.Timestamp = envelope.GetTimestamp()
+	}
+	s.containerMetricsV2.Set(metricKey, containerMetricV2, cache.DefaultExpiration)
+
+	if storeContainerMetric, expiration, ok := s.containerMetrics.GetWithExpiration(metricKey); ok {
+		containerMetric := *storeContainerMetric.(*ContainerMetric)
+		containerMetric.mergeV2Metrics(update)
+		containerMetric.ReceivedAt = time.Now().UnixNano()
+
+		ttl := cache.NoExpiration
+		if !expiration.IsZero() {
+			ttl = time.Until(expiration)
+			if ttl <= 0 {
+				return true
+			}
+		}
+		s.containerMetrics.Set(metricKey, &containerMetric, ttl)
+	}
+
+	return true
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+// dedupWindow remembers the last size envelope identities seen, evicting
+// the oldest one when full.
+type dedupWindow struct {
+	mutex sync.Mutex
+	keys  map[string]struct{}
+	ring  []string
+	next  int
+}
+
+func newDedupWindow(size int) *dedupWindow {
+	return &dedupWindow{
+		keys: make(map[string]struct{}, size),
+		ring: make([]string, size),
+	}
+}
+
+// seen reports whether key is already in the window, adding it otherwise.
+func (w *dedupWindow) seen(key string) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if _, ok := w.keys[key]; ok {
+		return true
+	}
+
+	if oldest := w.ring[w.next]; oldest != "" {
+		delete(w.keys, oldest)
+	}
+	w.ring[w.next] = key
+	w.keys[key] = struct{}{}
+	w.next = (w.next + 1) % len(w.ring)
+
+	return false
+}
+
+func (s *Store) SetDedupWindow(size int) {
+	if size <= 0 {
+		s.dedupWindow = nil
+		return
+	}
+
+	s.dedupWindow = newDedupWindow(size)
+}
+
+func (s *Store) duplicated(envelope *events.Envelope, metricKey string) bool {
+	if s.dedupWindow == nil {
+		return false
+	}
+
+	key := metricKey + "|" + strconv.FormatInt(envelope.GetTimestamp(), 10)
+	if envelope.GetEventType() == events.Envelope_HttpStartStop {
+		key += "|" + envelope.GetHttpStartStop().GetPeerType().String()
+	}
+
+	return s.dedupWindow.seen(key)
+}
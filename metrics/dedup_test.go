@@ -0,0 +1,114 @@
+package metrics_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bosh-prometheus/firehose_exporter/filters"
+	"github.com/bosh-prometheus/firehose_exporter/utils"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/bosh-prometheus/firehose_exporter/metrics"
+)
+
+var _ = Describe("Dedup", func() {
+	var (
+		metricsStore *Store
+		dedupWindow  int
+
+		envelopeTimestamp     = time.Now().UnixNano()
+		httpStartStopPeerType = events.PeerType_Client
+	)
+
+	counterEventEnvelope := func(timestamp int64) *events.Envelope {
+		return &events.Envelope{
+			Origin:     proto.String("fake-origin"),
+			EventType:  events.Envelope_CounterEvent.Enum(),
+			Timestamp:  proto.Int64(timestamp),
+			Deployment: proto.String("fake-deployment-name"),
+			CounterEvent: &events.CounterEvent{
+				Name:  proto.String("FakeCounterEvent"),
+				Delta: proto.Uint64(5),
+				Total: proto.Uint64(1000),
+			},
+		}
+	}
+
+	httpStartStopEnvelope := func(peerType events.PeerType) *events.Envelope {
+		return &events.Envelope{
+			Origin:     proto.String("fake-origin"),
+			EventType:  events.Envelope_HttpStartStop.Enum(),
+			Timestamp:  proto.Int64(envelopeTimestamp),
+			Deployment: proto.String("fake-deployment-name"),
+			HttpStartStop: &events.HttpStartStop{
+				StartTimestamp: proto.Int64(1),
+				StopTimestamp:  proto.Int64(10),
+				RequestId:      utils.StringToUUID("1beb4072-acaa-483f-5a8b-425dc080af13"),
+				PeerType:       &peerType,
+				Method:         events.Method_GET.Enum(),
+				Uri:            proto.String("https://fake.example.com/"),
+				StatusCode:     proto.Int32(200),
+				ContentLength:  proto.Int64(32),
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		dedupWindow = 10
+	})
+
+	JustBeforeEach(func() {
+		deploymentFilter := filters.NewDeploymentFilter([]string{})
+		eventFilter, _ := filters.NewEventFilter([]string{})
+		metricsStore = NewStore(time.Minute, time.Minute, deploymentFilter, eventFilter)
+		metricsStore.SetDedupWindow(dedupWindow)
+
+		metricsStore.AddMetric(counterEventEnvelope(envelopeTimestamp))
+		metricsStore.AddMetric(counterEventEnvelope(envelopeTimestamp))
+	})
+
+	It("suppresses duplicate envelopes", func() {
+		Expect(metricsStore.GetCounterEvents()[0].MonotonicTotal).To(Equal(uint64(5)))
+		Expect(metricsStore.GetInternalMetrics().TotalDuplicateEnvelopesSuppressed).To(Equal(int64(1)))
+		Expect(metricsStore.GetIngestionStats()).To(HaveKeyWithValue(IngestionKey{Origin: "fake-origin", EventType: "CounterEvent", Outcome: IngestionOutcomeDuplicate}, int64(1)))
+	})
+
+	It("does not suppress envelopes with a different timestamp", func() {
+		metricsStore.AddMetric(counterEventEnvelope(envelopeTimestamp + 1))
+		Expect(metricsStore.GetCounterEvents()[0].MonotonicTotal).To(Equal(uint64(10)))
+	})
+
+	It("does not suppress both halves of an http start stop", func() {
+		metricsStore.AddMetric(httpStartStopEnvelope(httpStartStopPeerType))
+		metricsStore.AddMetric(httpStartStopEnvelope(events.PeerType_Server))
+		metricsStore.AddMetric(httpStartStopEnvelope(httpStartStopPeerType))
+		Expect(metricsStore.GetInternalMetrics().TotalDuplicateEnvelopesSuppressed).To(Equal(int64(2)))
+		Expect(metricsStore.GetInternalMetrics().TotalHttpStartStopProcessed).To(Equal(int64(2)))
+	})
+
+	Context("when the window is full", func() {
+		BeforeEach(func() {
+			dedupWindow = 1
+		})
+
+		It("forgets the oldest envelopes", func() {
+			metricsStore.AddMetric(counterEventEnvelope(envelopeTimestamp + 1))
+			metricsStore.AddMetric(counterEventEnvelope(envelopeTimestamp))
+			Expect(metricsStore.GetCounterEvents()[0].MonotonicTotal).To(Equal(uint64(15)))
+		})
+	})
+
+	Context("when the window is disabled", func() {
+		BeforeEach(func() {
+			dedupWindow = 0
+		})
+
+		It("does not suppress any envelope", func() {
+			Expect(metricsStore.GetCounterEvents()[0].MonotonicTotal).To(Equal(uint64(10)))
+			Expect(metricsStore.GetInternalMetrics().TotalDuplicateEnvelopesSuppressed).To(Equal(int64(0)))
+		})
+	})
+})
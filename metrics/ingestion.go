@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	IngestionOutcomeProcessed            = "processed"
+	IngestionOutcomeFilteredByDeployment = "filtered_by_deployment"
+	IngestionOutcomeFilteredByEvent      = "filtered_by_event"
+	IngestionOutcomeMalformed            = "malformed"
+	IngestionOutcomeOverCardinality      = "over_cardinality"
+	IngestionOutcomeDuplicate            = "duplicate"
+	IngestionOutcomeStale                = "stale"
+)
+
+type IngestionKey struct {
+	Origin    string
+	EventType string
+	Outcome   string
+}
+
+type IngestionStats map[IngestionKey]int64
+
+func (s *Store) SetMaxCachedSeries(maxCachedSeries int) {
+	s.maxCachedSeries = maxCachedSeries
+}
+
+func (s *Store) DiscardStaleEnvelopes(tolerance time.Duration) {
+	s.discardStaleEnvelopes = true
+	s.staleEnvelopesTolerance = tolerance
+}
+
+func (s *Store) GetIngestionStats() IngestionStats {
+	s.ingestionStatsMutex.Lock()
+	defer s.ingestionStatsMutex.Unlock()
+
+	ingestionStats := IngestionStats{}
+	for key, value := range s.ingestionStats {
+		ingestionStats[key] = value
+	}
+
+	return ingestionStats
+}
+
+func (s *Store) setIngestionStats(ingestionStats IngestionStats) {
+	s.ingestionStatsMutex.Lock()
+	defer s.ingestionStatsMutex.Unlock()
+
+	for key, value := range ingestionStats {
+		s.ingestionStats[key] = value
+	}
+}
+
+func (s *Store) recordIngestion(envelope *events.Envelope, outcome string) {
+	key := IngestionKey{
+		Origin:    envelope.GetOrigin(),
+		EventType: envelope.GetEventType().String(),
+		Outcome:   outcome,
+	}
+
+	s.ingestionStatsMutex.Lock()
+	s.ingestionStats[key]++
+	s.ingestionStatsMutex.Unlock()
+}
+
+// admit decides whether an envelope must be cached at metricsCache,
+// recording the outcome in the ingestion stats. It returns the key the
+// envelope must be cached with.
+func (s *Store) admit(envelope *events.Envelope, metricsCache *cache.Cache) (string, bool) {
+	return s.admitAt(envelope, metricsCache, "")
+}
+
+// admitAt is like admit, but checks the envelope for staleness and
+// cardinality against the entry cached at cacheKey, when not empty, instead
+// of its own metric key.
+func (s *Store) admitAt(envelope *events.Envelope, metricsCache *cache.Cache, cacheKey string) (string, bool) {
+	if malformed(envelope) {
+		s.recordIngestion(envelope, IngestionOutcomeMalformed)
+		return "", false
+	}
+
+	if !s.deploymentFilter.Enabled(envelope.GetDeployment()) {
+		s.recordIngestion(envelope, IngestionOutcomeFilteredByDeployment)
+		return "", false
+	}
+
+	if !s.eventFilter.Enabled(envelope) {
+		s.recordIngestion(envelope, IngestionOutcomeFilteredByEvent)
+		return "", false
+	}
+
+	metricKey := s.metricKey(envelope)
+	if s.duplicated(envelope, metricKey) {
+		s.internalMetrics.IncrementInt64(TotalDuplicateEnvelopesSuppressedKey, 1)
+		s.recordIngestion(envelope, IngestionOutcomeDuplicate)
+		return "", false
+	}
+
+	if cacheKey != "" {
+		metricKey = cacheKey
+	}
+
+	if s.stale(envelope, metricsCache, metricKey) {
+		s.internalMetrics.IncrementInt64(TotalStaleEnvelopesDiscardedKey, 1)
+		s.recordIngestion(envelope, IngestionOutcomeStale)
+		if envelope.GetEventType() == events.Envelope_CounterEvent {
+			s.accumulateStaleCounterEvent(envelope, metricKey)
+		}
+		return "", false
+	}
+
+	if s.maxCachedSeries > 0 && metricsCache.ItemCount() >= s.maxCachedSeries {
+		if _, ok := metricsCache.Get(metricKey); !ok {
+			s.recordIngestion(envelope, IngestionOutcomeOverCardinality)
+			return "", false
+		}
+	}
+
+	s.recordIngestion(envelope, IngestionOutcomeProcessed)
+	return metricKey, true
+}
+
+// stale reports whether the envelope is older, beyond the configured
+// tolerance, than the sample already cached for the same series.
+// HttpStartStop envelopes are never stale, as both peers are merged into a
+// single entry.
+func (s *Store) stale(envelope *events.Envelope, metricsCache *cache.Cache, metricKey string) bool {
+	if !s.discardStaleEnvelopes || envelope.GetTimestamp() == 0 {
+		return false
+	}
+
+	storeMetric, ok := metricsCache.Get(metricKey)
+	if !ok {
+		return false
+	}
+
+	var cachedTimestamp int64
+	switch metric := storeMetric.(type) {
+	case *ContainerMetric:
+		cachedTimestamp = metric.Timestamp
+	case *CounterEvent:
+		cachedTimestamp = metric.Timestamp
+	case *ValueMetric:
+		cachedTimestamp = metric.Timestamp
+	default:
+		return false
+	}
+
+	return envelope.GetTimestamp() < cachedTimestamp-int64(s.staleEnvelopesTolerance)
+}
+
+func malformed(envelope *events.Envelope) bool {
+	switch envelope.GetEventType() {
+	case events.Envelope_ContainerMetric:
+		return envelope.GetContainerMetric() == nil
+	case events.Envelope_CounterEvent:
+		return envelope.GetCounterEvent() == nil
+	case events.Envelope_HttpStartStop:
+		return envelope.GetHttpStartStop() == nil
+	case events.Envelope_ValueMetric:
+		return envelope.GetValueMetric() == nil
+	}
+
+	return true
+}
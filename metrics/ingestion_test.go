@@ -0,0 +1,222 @@
+package metrics_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bosh-prometheus/firehose_exporter/filters"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/bosh-prometheus/firehose_exporter/metrics"
+)
+
+var _ = Describe("Ingestion", func() {
+	var (
+		metricsStore     *Store
+		deploymentFilter *filters.DeploymentFilter
+		eventFilter      *filters.EventFilter
+		maxCachedSeries  int
+
+		origin         = "fake-origin"
+		boshDeployment = "fake-deployment-name"
+
+		ingestionStats IngestionStats
+	)
+
+	valueMetricEnvelope := func(deployment string, name string) *events.Envelope {
+		return &events.Envelope{
+			Origin:     proto.String(origin),
+			EventType:  events.Envelope_ValueMetric.Enum(),
+			Timestamp:  proto.Int64(time.Now().UnixNano()),
+			Deployment: proto.String(deployment),
+			Job:        proto.String("fake-job-name"),
+			Index:      proto.String("0"),
+			Ip:         proto.String("1.2.3.4"),
+			ValueMetric: &events.ValueMetric{
+				Name:  proto.String(name),
+				Value: proto.Float64(1),
+				Unit:  proto.String("count"),
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		deploymentFilter = filters.NewDeploymentFilter([]string{boshDeployment})
+		eventFilter, _ = filters.NewEventFilter([]string{"ValueMetric"})
+		maxCachedSeries = 0
+	})
+
+	JustBeforeEach(func() {
+		metricsStore = NewStore(time.Minute, time.Minute, deploymentFilter, eventFilter)
+		metricsStore.SetMaxCachedSeries(maxCachedSeries)
+
+		metricsStore.AddMetric(valueMetricEnvelope(boshDeployment, "FakeValueMetric1"))
+		metricsStore.AddMetric(valueMetricEnvelope(boshDeployment, "FakeValueMetric2"))
+		metricsStore.AddMetric(valueMetricEnvelope("another-deployment", "FakeValueMetric1"))
+		metricsStore.AddMetric(
+			&events.Envelope{
+				Origin:     proto.String(origin),
+				EventType:  events.Envelope_CounterEvent.Enum(),
+				Timestamp:  proto.Int64(time.Now().UnixNano()),
+				Deployment: proto.String(boshDeployment),
+				CounterEvent: &events.CounterEvent{
+					Name:  proto.String("FakeCounterEvent"),
+					Delta: proto.Uint64(1),
+					Total: proto.Uint64(1),
+				},
+			},
+		)
+		metricsStore.AddMetric(
+			&events.Envelope{
+				Origin:     proto.String(origin),
+				EventType:  events.Envelope_ContainerMetric.Enum(),
+				Timestamp:  proto.Int64(time.Now().UnixNano()),
+				Deployment: proto.String(boshDeployment),
+			},
+		)
+
+		ingestionStats = metricsStore.GetIngestionStats()
+	})
+
+	It("counts processed envelopes", func() {
+		Expect(ingestionStats).To(HaveKeyWithValue(IngestionKey{Origin: origin, EventType: "ValueMetric", Outcome: IngestionOutcomeProcessed}, int64(2)))
+	})
+
+	It("counts envelopes filtered by deployment", func() {
+		Expect(ingestionStats).To(HaveKeyWithValue(IngestionKey{Origin: origin, EventType: "ValueMetric", Outcome: IngestionOutcomeFilteredByDeployment}, int64(1)))
+	})
+
+	It("counts envelopes filtered by event", func() {
+		Expect(ingestionStats).To(HaveKeyWithValue(IngestionKey{Origin: origin, EventType: "CounterEvent", Outcome: IngestionOutcomeFilteredByEvent}, int64(1)))
+	})
+
+	It("counts malformed envelopes", func() {
+		Expect(ingestionStats).To(HaveKeyWithValue(IngestionKey{Origin: origin, EventType: "ContainerMetric", Outcome: IngestionOutcomeMalformed}, int64(1)))
+	})
+
+	It("does not count envelopes over cardinality", func() {
+		Expect(ingestionStats).ToNot(HaveKey(IngestionKey{Origin: origin, EventType: "ValueMetric", Outcome: IngestionOutcomeOverCardinality}))
+	})
+
+	Context("when the max cached series is reached", func() {
+		BeforeEach(func() {
+			maxCachedSeries = 1
+		})
+
+		It("counts envelopes over cardinality", func() {
+			Expect(ingestionStats).To(HaveKeyWithValue(IngestionKey{Origin: origin, EventType: "ValueMetric", Outcome: IngestionOutcomeOverCardinality}, int64(1)))
+			Expect(metricsStore.GetValueMetrics()).To(HaveLen(1))
+		})
+
+		It("keeps updating already cached series", func() {
+			metricsStore.AddMetric(valueMetricEnvelope(boshDeployment, "FakeValueMetric1"))
+			Expect(metricsStore.GetIngestionStats()).To(HaveKeyWithValue(IngestionKey{Origin: origin, EventType: "ValueMetric", Outcome: IngestionOutcomeProcessed}, int64(2)))
+		})
+	})
+})
+
+var _ = Describe("Stale envelopes", func() {
+	var (
+		metricsStore  *Store
+		discardStale  bool
+		tolerance     time.Duration
+		lastTimestamp = time.Now().UnixNano()
+	)
+
+	valueMetricEnvelope := func(timestamp int64, value float64) *events.Envelope {
+		return &events.Envelope{
+			Origin:     proto.String("fake-origin"),
+			EventType:  events.Envelope_ValueMetric.Enum(),
+			Timestamp:  proto.Int64(timestamp),
+			Deployment: proto.String("fake-deployment-name"),
+			ValueMetric: &events.ValueMetric{
+				Name:  proto.String("FakeValueMetric"),
+				Value: proto.Float64(value),
+				Unit:  proto.String("count"),
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		discardStale = true
+		tolerance = 0
+	})
+
+	JustBeforeEach(func() {
+		deploymentFilter := filters.NewDeploymentFilter([]string{})
+		eventFilter, _ := filters.NewEventFilter([]string{})
+		metricsStore = NewStore(time.Minute, time.Minute, deploymentFilter, eventFilter)
+		if discardStale {
+			metricsStore.DiscardStaleEnvelopes(tolerance)
+		}
+
+		metricsStore.AddMetric(valueMetricEnvelope(lastTimestamp, 2))
+		metricsStore.AddMetric(valueMetricEnvelope(lastTimestamp-int64(time.Second), 1))
+	})
+
+	It("keeps the newest value", func() {
+		Expect(metricsStore.GetValueMetrics()[0].Value).To(Equal(float64(2)))
+	})
+
+	It("counts the discarded envelope", func() {
+		Expect(metricsStore.GetInternalMetrics().TotalStaleEnvelopesDiscarded).To(Equal(int64(1)))
+		Expect(metricsStore.GetIngestionStats()).To(HaveKeyWithValue(IngestionKey{Origin: "fake-origin", EventType: "ValueMetric", Outcome: IngestionOutcomeStale}, int64(1)))
+	})
+
+	It("accepts newer envelopes", func() {
+		metricsStore.AddMetric(valueMetricEnvelope(lastTimestamp+int64(time.Second), 3))
+		Expect(metricsStore.GetValueMetrics()[0].Value).To(Equal(float64(3)))
+	})
+
+	Context("when the envelope is within the tolerance", func() {
+		BeforeEach(func() {
+			tolerance = 2 * time.Second
+		})
+
+		It("accepts the envelope", func() {
+			Expect(metricsStore.GetValueMetrics()[0].Value).To(Equal(float64(1)))
+			Expect(metricsStore.GetInternalMetrics().TotalStaleEnvelopesDiscarded).To(Equal(int64(0)))
+		})
+	})
+
+	Context("when stale envelopes are not discarded", func() {
+		BeforeEach(func() {
+			discardStale = false
+		})
+
+		It("overwrites the cached value", func() {
+			Expect(metricsStore.GetValueMetrics()[0].Value).To(Equal(float64(1)))
+		})
+	})
+	Context("when a counter event is stale", func() {
+		counterEventEnvelope := func(timestamp int64, delta uint64, total uint64) *events.Envelope {
+			return &events.Envelope{
+				Origin:     proto.String("fake-origin"),
+				EventType:  events.Envelope_CounterEvent.Enum(),
+				Timestamp:  proto.Int64(timestamp),
+				Deployment: proto.String("fake-deployment-name"),
+				CounterEvent: &events.CounterEvent{
+					Name:  proto.String("FakeCounterEvent"),
+					Delta: proto.Uint64(delta),
+					Total: proto.Uint64(total),
+				},
+			}
+		}
+
+		JustBeforeEach(func() {
+			metricsStore.AddMetric(counterEventEnvelope(lastTimestamp, 5, 15))
+			metricsStore.AddMetric(counterEventEnvelope(lastTimestamp-int64(time.Second), 10, 10))
+		})
+
+		It("accumulates its delta but keeps the newest total", func() {
+			counterEvent := metricsStore.GetCounterEvents()[0]
+			Expect(counterEvent.Total).To(Equal(uint64(15)))
+			Expect(counterEvent.Timestamp).To(Equal(lastTimestamp))
+			Expect(counterEvent.MonotonicTotal).To(Equal(uint64(15)))
+			Expect(counterEvent.Resets).To(Equal(uint64(0)))
+		})
+	})
+})
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+)
+
+// maxInternedEntries bounds the interning tables. Once reached, the tables are
+// started afresh: already cached metrics keep their values, only new ones stop
+// sharing them with the old ones.
+const maxInternedEntries = 1 << 16
+
+// interner deduplicates the strings and tag sets repeated across cached
+// metrics, so that series coming from the same origin, deployment, job or
+// instance share a single copy. Interned tag sets must never be modified.
+type interner struct {
+	mutex   sync.Mutex
+	strings map[string]string
+	tagSets map[string]map[string]string
+
+	tagKeys   []string
+	tagSetKey []byte
+}
+
+func newInterner() *interner {
+	return &interner{
+		strings: map[string]string{},
+		tagSets: map[string]map[string]string{},
+	}
+}
+
+func (i *interner) string(s string) string {
+	if s == "" {
+		return s
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return i.internString(s)
+}
+
+func (i *interner) internString(s string) string {
+	if interned, ok := i.strings[s]; ok {
+		return interned
+	}
+
+	if len(i.strings) >= maxInternedEntries {
+		i.strings = map[string]string{}
+	}
+	i.strings[s] = s
+
+	return s
+}
+
+func (i *interner) tags(tags map[string]string) map[string]string {
+	if len(tags) == 0 {
+		return tags
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.tagKeys = i.tagKeys[:0]
+	for k := range tags {
+		i.tagKeys = append(i.tagKeys, k)
+	}
+	sort.Strings(i.tagKeys)
+
+	i.tagSetKey = i.tagSetKey[:0]
+	for _, k := range i.tagKeys {
+		i.tagSetKey = append(i.tagSetKey, k...)
+		i.tagSetKey = append(i.tagSetKey, 0)
+		i.tagSetKey = append(i.tagSetKey, tags[k]...)
+		i.tagSetKey = append(i.tagSetKey, 0)
+	}
+
+	if tagSet, ok := i.tagSets[string(i.tagSetKey)]; ok {
+		return tagSet
+	}
+
+	tagSet := make(map[string]string, len(tags))
+	for k, v := range tags {
+		tagSet[i.internString(k)] = i.internString(v)
+	}
+
+	if len(i.tagSets) >= maxInternedEntries {
+		i.tagSets = map[string]map[string]string{}
+	}
+	i.tagSets[string(i.tagSetKey)] = tagSet
+
+	return tagSet
+}
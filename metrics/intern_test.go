@@ -0,0 +1,74 @@
+package metrics_test
+
+import (
+	"reflect"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bosh-prometheus/firehose_exporter/filters"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/bosh-prometheus/firehose_exporter/metrics"
+)
+
+var _ = Describe("Interning", func() {
+	var (
+		metricsStore *Store
+		valueMetrics ValueMetrics
+	)
+
+	valueMetricEnvelope := func(name string, tags map[string]string) *events.Envelope {
+		return &events.Envelope{
+			Origin:     proto.String("fake-origin"),
+			EventType:  events.Envelope_ValueMetric.Enum(),
+			Timestamp:  proto.Int64(time.Now().UnixNano()),
+			Deployment: proto.String("fake-deployment-name"),
+			Job:        proto.String("fake-job-name"),
+			Index:      proto.String("0"),
+			Ip:         proto.String("1.2.3.4"),
+			Tags:       tags,
+			ValueMetric: &events.ValueMetric{
+				Name:  proto.String(name),
+				Value: proto.Float64(1),
+				Unit:  proto.String("count"),
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		deploymentFilter := filters.NewDeploymentFilter([]string{})
+		eventFilter, _ := filters.NewEventFilter([]string{})
+		metricsStore = NewStore(time.Minute, time.Minute, deploymentFilter, eventFilter)
+	})
+
+	Context("when metrics have the same tags", func() {
+		BeforeEach(func() {
+			metricsStore.AddMetric(valueMetricEnvelope("FakeValueMetric1", map[string]string{"source_id": "fake-source-id", "zone": "z1"}))
+			metricsStore.AddMetric(valueMetricEnvelope("FakeValueMetric2", map[string]string{"zone": "z1", "source_id": "fake-source-id"}))
+			valueMetrics = metricsStore.GetValueMetrics()
+		})
+
+		It("shares the tag set", func() {
+			Expect(valueMetrics).To(HaveLen(2))
+			Expect(valueMetrics[0].Tags).To(Equal(map[string]string{"source_id": "fake-source-id", "zone": "z1"}))
+			Expect(reflect.ValueOf(valueMetrics[0].Tags).Pointer()).To(Equal(reflect.ValueOf(valueMetrics[1].Tags).Pointer()))
+		})
+	})
+
+	Context("when metrics have different tags", func() {
+		BeforeEach(func() {
+			metricsStore.AddMetric(valueMetricEnvelope("FakeValueMetric1", map[string]string{"zone": "z1"}))
+			metricsStore.AddMetric(valueMetricEnvelope("FakeValueMetric2", map[string]string{"zone": "z2"}))
+			valueMetrics = metricsStore.GetValueMetrics()
+		})
+
+		It("keeps a tag set per metric", func() {
+			Expect(valueMetrics).To(HaveLen(2))
+			Expect(reflect.ValueOf(valueMetrics[0].Tags).Pointer()).ToNot(Equal(reflect.ValueOf(valueMetrics[1].Tags).Pointer()))
+			Expect([]string{valueMetrics[0].Tags["zone"], valueMetrics[1].Tags["zone"]}).To(ConsistOf("z1", "z2"))
+		})
+	})
+})
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+// joinWindow remembers the http start stop halves waiting for their peer,
+// so the ones still alone after the join timeout can be flushed.
+type joinWindow struct {
+	timeout time.Duration
+
+	mutex     sync.Mutex
+	pending   map[string]time.Time
+	nextFlush time.Time
+}
+
+func newJoinWindow(timeout time.Duration) *joinWindow {
+	return &joinWindow{
+		timeout:   timeout,
+		pending:   map[string]time.Time{},
+		nextFlush: time.Now().Add(timeout),
+	}
+}
+
+func (w *joinWindow) wait(key string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if _, ok := w.pending[key]; !ok {
+		w.pending[key] = time.Now()
+	}
+}
+
+func (w *joinWindow) join(key string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	delete(w.pending, key)
+}
+
+// expired removes and returns the keys of the halves waiting for longer than
+// the join timeout. They are only looked for once per join timeout.
+func (w *joinWindow) expired(now time.Time) []string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if now.Before(w.nextFlush) {
+		return nil
+	}
+	w.nextFlush = now.Add(w.timeout)
+
+	var expired []string
+	for key, since := range w.pending {
+		if now.Sub(since) >= w.timeout {
+			expired = append(expired, key)
+			delete(w.pending, key)
+		}
+	}
+
+	return expired
+}
+
+// SetHttpStartStopJoinTimeout sets how long an http start stop half waits for
+// its peer before being flushed from the store. A non positive timeout leaves
+// the halves in the store until they expire. Either way, the halves are
+// accounted as unmatched when they leave the store without their peer.
+func (s *Store) SetHttpStartStopJoinTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		s.joinWindow = nil
+		return
+	}
+
+	s.joinWindow = newJoinWindow(timeout)
+}
+
+// flushUnmatchedHttpStartStops flushes the http start stop halves that did
+// not meet their peer within the join timeout.
+func (s *Store) flushUnmatchedHttpStartStops() {
+	if s.joinWindow == nil {
+		return
+	}
+
+	for _, key := range s.joinWindow.expired(time.Now()) {
+		s.httpStartStops.Delete(key)
+	}
+}
+
+// evictHttpStartStop accounts the http start stop halves leaving the store,
+// flushed or expired, without their peer.
+func (s *Store) evictHttpStartStop(key string, value interface{}) {
+	httpStartStop, ok := value.(*HttpStartStop)
+	if !ok {
+		return
+	}
+
+	hasClient := httpStartStop.ClientStartTimestamp != 0 || httpStartStop.ClientStopTimestamp != 0
+	hasServer := httpStartStop.ServerStartTimestamp != 0 || httpStartStop.ServerStopTimestamp != 0
+	switch {
+	case hasClient && !hasServer:
+		s.internalMetrics.IncrementInt64(TotalHttpStartStopUnmatchedClientKey, 1)
+	case hasServer && !hasClient:
+		s.internalMetrics.IncrementInt64(TotalHttpStartStopUnmatchedServerKey, 1)
+	}
+}
+
+// joinHttpStartStop accounts a peer about to be added to an http start stop.
+// The pair is matched when the peer completes it.
+func (s *Store) joinHttpStartStop(metricKey string, httpStartStop *HttpStartStop, peerType events.PeerType) {
+	hasClient := httpStartStop.ClientStartTimestamp != 0 || httpStartStop.ClientStopTimestamp != 0
+	hasServer := httpStartStop.ServerStartTimestamp != 0 || httpStartStop.ServerStopTimestamp != 0
+
+	var completes bool
+	switch peerType {
+	case events.PeerType_Client:
+		completes = !hasClient && hasServer
+	case events.PeerType_Server:
+		completes = !hasServer && hasClient
+	}
+
+	if completes {
+		s.internalMetrics.IncrementInt64(TotalHttpStartStopMatchedKey, 1)
+		if s.joinWindow != nil {
+			s.joinWindow.join(metricKey)
+		}
+	} else if !hasClient && !hasServer && s.joinWindow != nil {
+		s.joinWindow.wait(metricKey)
+	}
+}
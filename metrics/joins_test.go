@@ -0,0 +1,147 @@
+package metrics_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bosh-prometheus/firehose_exporter/filters"
+	"github.com/bosh-prometheus/firehose_exporter/utils"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/bosh-prometheus/firehose_exporter/metrics"
+)
+
+var _ = Describe("Joins", func() {
+	var (
+		metricsStore           *Store
+		joinTimeout            time.Duration
+		metricsExpiration      time.Duration
+		metricsCleanupInterval time.Duration
+
+		clientRequestId = "1beb4072-acaa-483f-5a8b-425dc080af13"
+		serverRequestId = "2beb4072-acaa-483f-5a8b-425dc080af13"
+		otherRequestId  = "3beb4072-acaa-483f-5a8b-425dc080af13"
+	)
+
+	httpStartStopEnvelope := func(requestId string, peerType events.PeerType) *events.Envelope {
+		return &events.Envelope{
+			Origin:     proto.String("fake-origin"),
+			EventType:  events.Envelope_HttpStartStop.Enum(),
+			Timestamp:  proto.Int64(time.Now().UnixNano()),
+			Deployment: proto.String("fake-deployment-name"),
+			HttpStartStop: &events.HttpStartStop{
+				StartTimestamp: proto.Int64(1),
+				StopTimestamp:  proto.Int64(10),
+				RequestId:      utils.StringToUUID(requestId),
+				PeerType:       &peerType,
+				Method:         events.Method_GET.Enum(),
+				Uri:            proto.String("https://fake.example.com/"),
+				StatusCode:     proto.Int32(200),
+				ContentLength:  proto.Int64(32),
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		joinTimeout = 0
+		metricsExpiration = time.Minute
+		metricsCleanupInterval = time.Minute
+	})
+
+	JustBeforeEach(func() {
+		deploymentFilter := filters.NewDeploymentFilter([]string{})
+		eventFilter, _ := filters.NewEventFilter([]string{})
+		metricsStore = NewStore(metricsExpiration, metricsCleanupInterval, deploymentFilter, eventFilter)
+		metricsStore.SetHttpStartStopJoinTimeout(joinTimeout)
+	})
+
+	It("counts the pairs matched whatever the order of their peers", func() {
+		metricsStore.AddMetric(httpStartStopEnvelope(clientRequestId, events.PeerType_Client))
+		metricsStore.AddMetric(httpStartStopEnvelope(clientRequestId, events.PeerType_Server))
+		metricsStore.AddMetric(httpStartStopEnvelope(serverRequestId, events.PeerType_Server))
+		metricsStore.AddMetric(httpStartStopEnvelope(serverRequestId, events.PeerType_Client))
+
+		Expect(metricsStore.GetInternalMetrics().TotalHttpStartStopMatched).To(Equal(int64(2)))
+	})
+
+	It("does not match a peer with itself", func() {
+		metricsStore.AddMetric(httpStartStopEnvelope(clientRequestId, events.PeerType_Client))
+		metricsStore.AddMetric(httpStartStopEnvelope(clientRequestId, events.PeerType_Client))
+
+		Expect(metricsStore.GetInternalMetrics().TotalHttpStartStopMatched).To(Equal(int64(0)))
+	})
+
+	It("counts the unknown peers as malformed and discards them", func() {
+		metricsStore.AddMetric(httpStartStopEnvelope(clientRequestId, events.PeerType(3)))
+
+		Expect(metricsStore.GetInternalMetrics().TotalHttpStartStopUnknownPeer).To(Equal(int64(1)))
+		Expect(metricsStore.GetInternalMetrics().TotalHttpStartStopProcessed).To(Equal(int64(0)))
+		Expect(metricsStore.GetIngestionStats()).To(HaveKeyWithValue(IngestionKey{Origin: "fake-origin", EventType: "HttpStartStop", Outcome: IngestionOutcomeMalformed}, int64(1)))
+		Expect(metricsStore.GetIngestionStats()).ToNot(HaveKey(IngestionKey{Origin: "fake-origin", EventType: "HttpStartStop", Outcome: IngestionOutcomeProcessed}))
+		Expect(metricsStore.GetHttpStartStops()).To(BeEmpty())
+	})
+
+	Context("when the join timeout is not set", func() {
+		It("keeps the unmatched halves", func() {
+			metricsStore.AddMetric(httpStartStopEnvelope(clientRequestId, events.PeerType_Client))
+			time.Sleep(20 * time.Millisecond)
+
+			Expect(metricsStore.GetHttpStartStops()).To(HaveLen(1))
+			Expect(metricsStore.GetInternalMetrics().TotalHttpStartStopUnmatchedClient).To(Equal(int64(0)))
+		})
+
+		Context("and the unmatched halves expire", func() {
+			BeforeEach(func() {
+				metricsExpiration = 10 * time.Millisecond
+				metricsCleanupInterval = 5 * time.Millisecond
+			})
+
+			It("counts the unmatched halves", func() {
+				metricsStore.AddMetric(httpStartStopEnvelope(clientRequestId, events.PeerType_Client))
+				metricsStore.AddMetric(httpStartStopEnvelope(serverRequestId, events.PeerType_Server))
+				metricsStore.AddMetric(httpStartStopEnvelope(otherRequestId, events.PeerType_Client))
+				metricsStore.AddMetric(httpStartStopEnvelope(otherRequestId, events.PeerType_Server))
+
+				Eventually(func() int64 { return metricsStore.GetInternalMetrics().TotalHttpStartStopUnmatchedClient }).Should(Equal(int64(1)))
+				Eventually(func() int64 { return metricsStore.GetInternalMetrics().TotalHttpStartStopUnmatchedServer }).Should(Equal(int64(1)))
+			})
+		})
+	})
+
+	Context("when the join timeout is set", func() {
+		BeforeEach(func() {
+			joinTimeout = 10 * time.Millisecond
+		})
+
+		It("flushes the halves unmatched after the join timeout when receiving the next http start stop", func() {
+			metricsStore.AddMetric(httpStartStopEnvelope(clientRequestId, events.PeerType_Client))
+			metricsStore.AddMetric(httpStartStopEnvelope(serverRequestId, events.PeerType_Server))
+			time.Sleep(20 * time.Millisecond)
+
+			Expect(metricsStore.GetInternalMetrics().TotalHttpStartStopUnmatchedClient).To(Equal(int64(0)))
+			Expect(metricsStore.GetHttpStartStops()).To(HaveLen(2))
+
+			metricsStore.AddMetric(httpStartStopEnvelope(otherRequestId, events.PeerType_Client))
+
+			internalMetrics := metricsStore.GetInternalMetrics()
+			Expect(internalMetrics.TotalHttpStartStopUnmatchedClient).To(Equal(int64(1)))
+			Expect(internalMetrics.TotalHttpStartStopUnmatchedServer).To(Equal(int64(1)))
+			Expect(metricsStore.GetHttpStartStops()).To(HaveLen(1))
+		})
+
+		It("does not flush the matched pairs", func() {
+			metricsStore.AddMetric(httpStartStopEnvelope(clientRequestId, events.PeerType_Client))
+			metricsStore.AddMetric(httpStartStopEnvelope(clientRequestId, events.PeerType_Server))
+			time.Sleep(20 * time.Millisecond)
+			metricsStore.AddMetric(httpStartStopEnvelope(serverRequestId, events.PeerType(3)))
+
+			internalMetrics := metricsStore.GetInternalMetrics()
+			Expect(internalMetrics.TotalHttpStartStopMatched).To(Equal(int64(1)))
+			Expect(internalMetrics.TotalHttpStartStopUnmatchedClient).To(Equal(int64(0)))
+			Expect(metricsStore.GetHttpStartStops()).To(HaveLen(1))
+		})
+	})
+})
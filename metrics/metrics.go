@@ -14,11 +14,19 @@ const (
 	TotalHttpStartStopReceivedKey           = "TotalHttpStartStopReceived"
 	TotalHttpStartStopProcessedKey          = "TotalHttpStartStopProcessed"
 	LastHttpStartStopReceivedTimestampKey   = "LastHttpStartStopReceivedTimestamp"
+	TotalHttpStartStopMatchedKey            = "TotalHttpStartStopMatched"
+	TotalHttpStartStopUnmatchedClientKey    = "TotalHttpStartStopUnmatchedClient"
+	TotalHttpStartStopUnmatchedServerKey    = "TotalHttpStartStopUnmatchedServer"
+	TotalHttpStartStopUnknownPeerKey        = "TotalHttpStartStopUnknownPeer"
 	TotalValueMetricsReceivedKey            = "TotalValueMetricsReceived"
 	TotalValueMetricsProcessedKey           = "TotalValueMetricsProcessed"
 	LastValueMetricReceivedTimestampKey     = "LastValueMetricReceivedTimestamp"
 	SlowConsumerAlertKey                    = "SlowConsumerAlert"
 	LastSlowConsumerAlertTimestampKey       = "LastSlowConsumerAlertTimestamp"
+	TotalDuplicateEnvelopesSuppressedKey    = "TotalDuplicateEnvelopesSuppressed"
+	TotalStaleEnvelopesDiscardedKey         = "TotalStaleEnvelopesDiscarded"
+	TotalCounterEventsTagLabelCollisionsKey = "TotalCounterEventsTagLabelCollisions"
+	TotalValueMetricsTagLabelCollisionsKey  = "TotalValueMetricsTagLabelCollisions"
 )
 
 type InternalMetrics struct {
@@ -38,12 +46,20 @@ type InternalMetrics struct {
 	TotalHttpStartStopProcessed          int64
 	TotalHttpStartStopCached             int64
 	LastHttpStartStopReceivedTimestamp   int64
+	TotalHttpStartStopMatched            int64
+	TotalHttpStartStopUnmatchedClient    int64
+	TotalHttpStartStopUnmatchedServer    int64
+	TotalHttpStartStopUnknownPeer        int64
 	TotalValueMetricsReceived            int64
 	TotalValueMetricsProcessed           int64
 	TotalValueMetricsCached              int64
 	LastValueMetricReceivedTimestamp     int64
 	SlowConsumerAlert                    bool
 	LastSlowConsumerAlertTimestamp       int64
+	TotalDuplicateEnvelopesSuppressed    int64
+	TotalStaleEnvelopesDiscarded         int64
+	TotalCounterEventsTagLabelCollisions int64
+	TotalValueMetricsTagLabelCollisions  int64
 }
 
 type ContainerMetrics []*ContainerMetric
@@ -63,21 +79,35 @@ type ContainerMetric struct {
 	DiskBytes        uint64
 	MemoryBytesQuota uint64
 	DiskBytesQuota   uint64
+
+	// Loggregator v2 container metrics, nil until reported.
+	CpuEntitlement      *float64
+	AbsoluteUsage       *float64
+	AbsoluteEntitlement *float64
+	ContainerAge        *float64
+	LogRate             *float64
+	LogRateLimit        *float64
+
+	// Time the entry was last updated, in nanoseconds since the epoch.
+	ReceivedAt int64 `json:"-"`
 }
 
 type CounterEvents []*CounterEvent
 
 type CounterEvent struct {
-	Origin     string
-	Timestamp  int64
-	Deployment string
-	Job        string
-	Index      string
-	IP         string
-	Tags       map[string]string
-	Name       string
-	Delta      uint64
-	Total      uint64
+	Origin         string
+	Timestamp      int64
+	Deployment     string
+	Job            string
+	Index          string
+	IP             string
+	Tags           map[string]string
+	Name           string
+	Delta          uint64
+	Total          uint64
+	MonotonicTotal uint64
+	Resets         uint64
+	ReceivedAt     int64 `json:"-"`
 }
 
 type HttpStartStops []*HttpStartStop
@@ -104,6 +134,7 @@ type HttpStartStop struct {
 	ClientStopTimestamp  int64
 	ServerStartTimestamp int64
 	ServerStopTimestamp  int64
+	ReceivedAt           int64 `json:"-"`
 }
 
 type ValueMetrics []*ValueMetric
@@ -119,4 +150,5 @@ type ValueMetric struct {
 	Name       string
 	Value      float64
 	Unit       string
+	ReceivedAt int64 `json:"-"`
 }
@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/patrickmn/go-cache"
+)
+
+type SeriesQuery struct {
+	EventType  string
+	Origin     string
+	Deployment string
+	Name       *regexp.Regexp
+	Tags       map[string]string
+}
+
+type Series struct {
+	EventType  string      `json:"event_type"`
+	ReceivedAt *time.Time  `json:"received_at,omitempty"`
+	TTLSeconds *float64    `json:"ttl_seconds,omitempty"`
+	Metric     interface{} `json:"metric"`
+
+	key string
+}
+
+type seriesAttributes struct {
+	origin     string
+	deployment string
+	name       string
+	tags       map[string]string
+}
+
+// QuerySeries returns copies of the cached entries matching query, sorted so
+// that consecutive calls can be paginated. The name expression matches the
+// metric name for counter events and value metrics, the application ID for
+// container metrics and the URI for http start stop events. The entries are
+// copied while no envelope is being added, as http start stops are merged in
+// place with their peer.
+func (s *Store) QuerySeries(query SeriesQuery) []Series {
+	var series []Series
+
+	s.writeMutex.RLock()
+	defer s.writeMutex.RUnlock()
+
+	now := time.Now()
+	collect := func(eventType events.Envelope_EventType, items map[string]cache.Item, copyEntry func(interface{}) (interface{}, int64, seriesAttributes)) {
+		if query.EventType != "" && query.EventType != eventType.String() {
+			return
+		}
+
+		for key, item := range items {
+			if item.Expired() {
+				continue
+			}
+
+			metric, receivedAt, attributes := copyEntry(item.Object)
+			if !query.matches(attributes) {
+				continue
+			}
+
+			entry := Series{
+				EventType: eventType.String(),
+				Metric:    metric,
+				key:       key,
+			}
+			if receivedAt > 0 {
+				receivedAt := time.Unix(0, receivedAt)
+				entry.ReceivedAt = &receivedAt
+			}
+			if item.Expiration > 0 {
+				ttl := time.Unix(0, item.Expiration).Sub(now).Seconds()
+				entry.TTLSeconds = &ttl
+			}
+			series = append(series, entry)
+		}
+	}
+
+	collect(events.Envelope_ContainerMetric, s.containerMetrics.Items(), func(object interface{}) (interface{}, int64, seriesAttributes) {
+		containerMetric := *object.(*ContainerMetric)
+		return &containerMetric, containerMetric.ReceivedAt, seriesAttributes{containerMetric.Origin, containerMetric.Deployment, containerMetric.ApplicationId, containerMetric.Tags}
+	})
+	collect(events.Envelope_CounterEvent, s.counterEvents.Items(), func(object interface{}) (interface{}, int64, seriesAttributes) {
+		counterEvent := *object.(*CounterEvent)
+		return &counterEvent, counterEvent.ReceivedAt, seriesAttributes{counterEvent.Origin, counterEvent.Deployment, counterEvent.Name, counterEvent.Tags}
+	})
+	collect(events.Envelope_HttpStartStop, s.httpStartStops.Items(), func(object interface{}) (interface{}, int64, seriesAttributes) {
+		httpStartStop := *object.(*HttpStartStop)
+		return &httpStartStop, httpStartStop.ReceivedAt, seriesAttributes{httpStartStop.Origin, httpStartStop.Deployment, httpStartStop.Uri, httpStartStop.Tags}
+	})
+	collect(events.Envelope_ValueMetric, s.valueMetrics.Items(), func(object interface{}) (interface{}, int64, seriesAttributes) {
+		valueMetric := *object.(*ValueMetric)
+		return &valueMetric, valueMetric.ReceivedAt, seriesAttributes{valueMetric.Origin, valueMetric.Deployment, valueMetric.Name, valueMetric.Tags}
+	})
+
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].EventType != series[j].EventType {
+			return series[i].EventType < series[j].EventType
+		}
+		return series[i].key < series[j].key
+	})
+
+	return series
+}
+
+func (q SeriesQuery) matches(attributes seriesAttributes) bool {
+	if q.Origin != "" && q.Origin != attributes.origin {
+		return false
+	}
+
+	if q.Deployment != "" && q.Deployment != attributes.deployment {
+		return false
+	}
+
+	if q.Name != nil && !q.Name.MatchString(attributes.name) {
+		return false
+	}
+
+	for k, v := range q.Tags {
+		if tagValue, ok := attributes.tags[k]; !ok || tagValue != v {
+			return false
+		}
+	}
+
+	return true
+}
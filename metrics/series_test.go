@@ -0,0 +1,161 @@
+package metrics_test
+
+import (
+	"regexp"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bosh-prometheus/firehose_exporter/filters"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/bosh-prometheus/firehose_exporter/metrics"
+)
+
+var _ = Describe("QuerySeries", func() {
+	var (
+		metricsStore *Store
+		query        SeriesQuery
+		series       []Series
+	)
+
+	valueMetricEnvelope := func(origin string, deployment string, name string, tags map[string]string) *events.Envelope {
+		return &events.Envelope{
+			Origin:     proto.String(origin),
+			EventType:  events.Envelope_ValueMetric.Enum(),
+			Timestamp:  proto.Int64(time.Now().UnixNano()),
+			Deployment: proto.String(deployment),
+			Job:        proto.String("fake-job-name"),
+			Index:      proto.String("0"),
+			Ip:         proto.String("1.2.3.4"),
+			Tags:       tags,
+			ValueMetric: &events.ValueMetric{
+				Name:  proto.String(name),
+				Value: proto.Float64(1),
+				Unit:  proto.String("count"),
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		deploymentFilter := filters.NewDeploymentFilter([]string{})
+		eventFilter, _ := filters.NewEventFilter([]string{})
+		metricsStore = NewStore(time.Minute, time.Minute, deploymentFilter, eventFilter)
+
+		metricsStore.AddMetric(valueMetricEnvelope("fake-origin-1", "fake-deployment-1", "FakeValueMetric1", map[string]string{"tag": "a"}))
+		metricsStore.AddMetric(valueMetricEnvelope("fake-origin-1", "fake-deployment-2", "FakeValueMetric2", map[string]string{"tag": "b"}))
+		metricsStore.AddMetric(valueMetricEnvelope("fake-origin-2", "fake-deployment-1", "AnotherMetric", nil))
+		metricsStore.AddMetric(
+			&events.Envelope{
+				Origin:     proto.String("fake-origin-1"),
+				EventType:  events.Envelope_CounterEvent.Enum(),
+				Timestamp:  proto.Int64(time.Now().UnixNano()),
+				Deployment: proto.String("fake-deployment-1"),
+				CounterEvent: &events.CounterEvent{
+					Name:  proto.String("FakeCounterEvent"),
+					Delta: proto.Uint64(1),
+					Total: proto.Uint64(1),
+				},
+			},
+		)
+
+		query = SeriesQuery{}
+	})
+
+	JustBeforeEach(func() {
+		series = metricsStore.QuerySeries(query)
+	})
+
+	It("returns all series", func() {
+		Expect(series).To(HaveLen(4))
+	})
+
+	It("returns the arrival time and remaining TTL", func() {
+		Expect(*series[0].ReceivedAt).To(BeTemporally("~", time.Now(), time.Second))
+		Expect(*series[0].TTLSeconds).To(BeNumerically("~", 60, 1))
+	})
+
+	Context("when an http start stop is merged with its peer", func() {
+		httpStartStopEnvelope := func(peerType events.PeerType) *events.Envelope {
+			return &events.Envelope{
+				Origin:     proto.String("fake-origin-1"),
+				EventType:  events.Envelope_HttpStartStop.Enum(),
+				Timestamp:  proto.Int64(time.Now().UnixNano()),
+				Deployment: proto.String("fake-deployment-1"),
+				HttpStartStop: &events.HttpStartStop{
+					StartTimestamp: proto.Int64(1),
+					StopTimestamp:  proto.Int64(2),
+					RequestId:      &events.UUID{Low: proto.Uint64(1), High: proto.Uint64(2)},
+					PeerType:       peerType.Enum(),
+					Method:         events.Method_GET.Enum(),
+					Uri:            proto.String("/fake"),
+				},
+			}
+		}
+
+		BeforeEach(func() {
+			query.EventType = "HttpStartStop"
+			metricsStore.AddMetric(httpStartStopEnvelope(events.PeerType_Client))
+		})
+
+		It("returns copies of the cached entries", func() {
+			Expect(series).To(HaveLen(1))
+
+			metricsStore.AddMetric(httpStartStopEnvelope(events.PeerType_Server))
+			Expect(series[0].Metric.(*HttpStartStop).ServerStartTimestamp).To(BeZero())
+			Expect(metricsStore.QuerySeries(query)[0].Metric.(*HttpStartStop).ServerStartTimestamp).To(Equal(int64(1)))
+		})
+	})
+
+	It("sorts series by event type", func() {
+		Expect(series[0].EventType).To(Equal("CounterEvent"))
+		Expect(series[1].EventType).To(Equal("ValueMetric"))
+	})
+
+	Context("when filtering by event type", func() {
+		BeforeEach(func() {
+			query.EventType = "CounterEvent"
+		})
+
+		It("returns the matching series", func() {
+			Expect(series).To(HaveLen(1))
+			Expect(series[0].Metric.(*CounterEvent).Name).To(Equal("FakeCounterEvent"))
+		})
+	})
+
+	Context("when filtering by origin and deployment", func() {
+		BeforeEach(func() {
+			query.EventType = "ValueMetric"
+			query.Origin = "fake-origin-1"
+			query.Deployment = "fake-deployment-1"
+		})
+
+		It("returns the matching series", func() {
+			Expect(series).To(HaveLen(1))
+			Expect(series[0].Metric.(*ValueMetric).Name).To(Equal("FakeValueMetric1"))
+		})
+	})
+
+	Context("when filtering by name", func() {
+		BeforeEach(func() {
+			query.Name = regexp.MustCompile("^Fake.*Metric")
+		})
+
+		It("returns the matching series", func() {
+			Expect(series).To(HaveLen(2))
+		})
+	})
+
+	Context("when filtering by tags", func() {
+		BeforeEach(func() {
+			query.Tags = map[string]string{"tag": "b"}
+		})
+
+		It("returns the matching series", func() {
+			Expect(series).To(HaveLen(1))
+			Expect(series[0].Metric.(*ValueMetric).Name).To(Equal("FakeValueMetric2"))
+		})
+	})
+})
@@ -0,0 +1,243 @@
+package metrics
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+const snapshotVersion = 1
+
+type snapshot struct {
+	Version            int
+	Timestamp          int64
+	InternalMetrics    InternalMetrics
+	IngestionStats     IngestionStats
+	ContainerMetrics   []containerMetricItem
+	ContainerMetricsV2 []containerMetricItem
+	CounterEvents      []counterEventItem
+	HttpStartStops     []httpStartStopItem
+	ValueMetrics       []valueMetricItem
+}
+
+type containerMetricItem struct {
+	Key        string
+	Expiration int64
+	Object     *ContainerMetric
+}
+
+type counterEventItem struct {
+	Key        string
+	Expiration int64
+	Object     *CounterEvent
+}
+
+type httpStartStopItem struct {
+	Key        string
+	Expiration int64
+	Object     *HttpStartStop
+}
+
+type valueMetricItem struct {
+	Key        string
+	Expiration int64
+	Object     *ValueMetric
+}
+
+// WriteSnapshot encodes the cached metrics, with their expiration, and the
+// internal metrics into w. The state kept by the collectors (http start stop
+// and mapped summaries and histograms) and the http start stop join timeouts
+// are not part of the snapshot.
+func (s *Store) WriteSnapshot(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(s.takeSnapshot())
+}
+
+// takeSnapshot copies the cached metrics while no envelope is being added, as
+// http start stops and counter and container metrics may be merged in place.
+func (s *Store) takeSnapshot() snapshot {
+	s.writeMutex.RLock()
+	defer s.writeMutex.RUnlock()
+
+	snap := snapshot{
+		Version:         snapshotVersion,
+		Timestamp:       time.Now().UnixNano(),
+		InternalMetrics: s.GetInternalMetrics(),
+		IngestionStats:  s.GetIngestionStats(),
+	}
+
+	for key, item := range s.containerMetrics.Items() {
+		containerMetric := *item.Object.(*ContainerMetric)
+		snap.ContainerMetrics = append(snap.ContainerMetrics, containerMetricItem{Key: key, Expiration: item.Expiration, Object: &containerMetric})
+	}
+	for key, item := range s.containerMetricsV2.Items() {
+		containerMetric := *item.Object.(*ContainerMetric)
+		snap.ContainerMetricsV2 = append(snap.ContainerMetricsV2, containerMetricItem{Key: key, Expiration: item.Expiration, Object: &containerMetric})
+	}
+	for key, item := range s.counterEvents.Items() {
+		counterEvent := *item.Object.(*CounterEvent)
+		snap.CounterEvents = append(snap.CounterEvents, counterEventItem{Key: key, Expiration: item.Expiration, Object: &counterEvent})
+	}
+	for key, item := range s.httpStartStops.Items() {
+		httpStartStop := *item.Object.(*HttpStartStop)
+		snap.HttpStartStops = append(snap.HttpStartStops, httpStartStopItem{Key: key, Expiration: item.Expiration, Object: &httpStartStop})
+	}
+	for key, item := range s.valueMetrics.Items() {
+		valueMetric := *item.Object.(*ValueMetric)
+		snap.ValueMetrics = append(snap.ValueMetrics, valueMetricItem{Key: key, Expiration: item.Expiration, Object: &valueMetric})
+	}
+
+	return snap
+}
+
+// ReadSnapshot decodes a snapshot written by WriteSnapshot from r and
+// restores it into the store. Cached metrics keep their remaining TTL, and
+// those already expired are discarded. Their strings and tag sets are
+// interned, as those of the metrics received.
+func (s *Store) ReadSnapshot(r io.Reader) error {
+	var snap snapshot
+	if err := gob.NewDecoder(r).Decode(&snap); err != nil {
+		return err
+	}
+
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("Snapshot version `%d` is not supported", snap.Version)
+	}
+
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	now := time.Now().UnixNano()
+
+	s.SetInternalMetrics(snap.InternalMetrics)
+	s.setIngestionStats(snap.IngestionStats)
+
+	for _, item := range snap.ContainerMetrics {
+		if ttl, ok := remainingTTL(item.Expiration, now); ok {
+			s.containerMetrics.Set(item.Key, s.internContainerMetric(item.Object), ttl)
+		}
+	}
+	for _, item := range snap.ContainerMetricsV2 {
+		if ttl, ok := remainingTTL(item.Expiration, now); ok {
+			s.containerMetricsV2.Set(item.Key, s.internContainerMetric(item.Object), ttl)
+		}
+	}
+	for _, item := range snap.CounterEvents {
+		if ttl, ok := remainingTTL(item.Expiration, now); ok {
+			s.counterEvents.Set(item.Key, s.internCounterEvent(item.Object), ttl)
+		}
+	}
+	for _, item := range snap.HttpStartStops {
+		if ttl, ok := remainingTTL(item.Expiration, now); ok {
+			s.httpStartStops.Set(item.Key, s.internHttpStartStop(item.Object), ttl)
+		}
+	}
+	for _, item := range snap.ValueMetrics {
+		if ttl, ok := remainingTTL(item.Expiration, now); ok {
+			s.valueMetrics.Set(item.Key, s.internValueMetric(item.Object), ttl)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) internContainerMetric(containerMetric *ContainerMetric) *ContainerMetric {
+	containerMetric.Origin = s.interner.string(containerMetric.Origin)
+	containerMetric.Deployment = s.interner.string(containerMetric.Deployment)
+	containerMetric.Job = s.interner.string(containerMetric.Job)
+	containerMetric.Index = s.interner.string(containerMetric.Index)
+	containerMetric.IP = s.interner.string(containerMetric.IP)
+	containerMetric.Tags = s.interner.tags(containerMetric.Tags)
+	containerMetric.ApplicationId = s.interner.string(containerMetric.ApplicationId)
+
+	return containerMetric
+}
+
+func (s *Store) internCounterEvent(counterEvent *CounterEvent) *CounterEvent {
+	counterEvent.Origin = s.interner.string(counterEvent.Origin)
+	counterEvent.Deployment = s.interner.string(counterEvent.Deployment)
+	counterEvent.Job = s.interner.string(counterEvent.Job)
+	counterEvent.Index = s.interner.string(counterEvent.Index)
+	counterEvent.IP = s.interner.string(counterEvent.IP)
+	counterEvent.Tags = s.interner.tags(counterEvent.Tags)
+	counterEvent.Name = s.interner.string(counterEvent.Name)
+
+	return counterEvent
+}
+
+func (s *Store) internHttpStartStop(httpStartStop *HttpStartStop) *HttpStartStop {
+	httpStartStop.Origin = s.interner.string(httpStartStop.Origin)
+	httpStartStop.Deployment = s.interner.string(httpStartStop.Deployment)
+	httpStartStop.Job = s.interner.string(httpStartStop.Job)
+	httpStartStop.Index = s.interner.string(httpStartStop.Index)
+	httpStartStop.IP = s.interner.string(httpStartStop.IP)
+	httpStartStop.Tags = s.interner.tags(httpStartStop.Tags)
+
+	return httpStartStop
+}
+
+func (s *Store) internValueMetric(valueMetric *ValueMetric) *ValueMetric {
+	valueMetric.Origin = s.interner.string(valueMetric.Origin)
+	valueMetric.Deployment = s.interner.string(valueMetric.Deployment)
+	valueMetric.Job = s.interner.string(valueMetric.Job)
+	valueMetric.Index = s.interner.string(valueMetric.Index)
+	valueMetric.IP = s.interner.string(valueMetric.IP)
+	valueMetric.Tags = s.interner.tags(valueMetric.Tags)
+	valueMetric.Name = s.interner.string(valueMetric.Name)
+	valueMetric.Unit = s.interner.string(valueMetric.Unit)
+
+	return valueMetric
+}
+
+// SaveSnapshot writes a snapshot of the store to path. The snapshot is
+// written to a temporary file first and renamed, so a crash never leaves
+// a truncated snapshot behind.
+func (s *Store) SaveSnapshot(path string) error {
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if err := s.WriteSnapshot(tmpFile); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), path)
+}
+
+// LoadSnapshot restores the store from the snapshot at path.
+func (s *Store) LoadSnapshot(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return s.ReadSnapshot(file)
+}
+
+func remainingTTL(expiration int64, now int64) (time.Duration, bool) {
+	if expiration <= 0 {
+		return cache.NoExpiration, true
+	}
+
+	if expiration <= now {
+		return 0, false
+	}
+
+	return time.Duration(expiration - now), true
+}
@@ -0,0 +1,279 @@
+package metrics_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/bosh-prometheus/firehose_exporter/filters"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/bosh-prometheus/firehose_exporter/metrics"
+)
+
+var _ = Describe("Snapshot", func() {
+	var (
+		metricsStore      *Store
+		restoredStore     *Store
+		metricsExpiration time.Duration
+		deploymentFilter  *filters.DeploymentFilter
+		eventFilter       *filters.EventFilter
+	)
+
+	BeforeEach(func() {
+		metricsExpiration = 10 * time.Minute
+		deploymentFilter = filters.NewDeploymentFilter([]string{})
+		eventFilter, _ = filters.NewEventFilter([]string{})
+	})
+
+	JustBeforeEach(func() {
+		metricsStore = NewStore(metricsExpiration, time.Minute, deploymentFilter, eventFilter)
+		restoredStore = NewStore(metricsExpiration, time.Minute, deploymentFilter, eventFilter)
+
+		metricsStore.AddMetric(
+			&events.Envelope{
+				Origin:     proto.String("fake-origin"),
+				EventType:  events.Envelope_ValueMetric.Enum(),
+				Timestamp:  proto.Int64(time.Now().UnixNano()),
+				Deployment: proto.String("fake-deployment-name"),
+				Job:        proto.String("fake-job-name"),
+				Index:      proto.String("0"),
+				Ip:         proto.String("1.2.3.4"),
+				ValueMetric: &events.ValueMetric{
+					Name:  proto.String("FakeValueMetric"),
+					Value: proto.Float64(2000),
+					Unit:  proto.String("kb"),
+				},
+				Tags: map[string]string{"tag": "fake-tag"},
+			},
+		)
+		metricsStore.AddMetric(
+			&events.Envelope{
+				Origin:     proto.String("fake-origin"),
+				EventType:  events.Envelope_CounterEvent.Enum(),
+				Timestamp:  proto.Int64(time.Now().UnixNano()),
+				Deployment: proto.String("fake-deployment-name"),
+				Job:        proto.String("fake-job-name"),
+				Index:      proto.String("0"),
+				Ip:         proto.String("1.2.3.4"),
+				CounterEvent: &events.CounterEvent{
+					Name:  proto.String("FakeCounterEvent"),
+					Delta: proto.Uint64(5),
+					Total: proto.Uint64(1000),
+				},
+			},
+		)
+		metricsStore.AlertSlowConsumerError()
+	})
+
+	Describe("WriteSnapshot and ReadSnapshot", func() {
+		var (
+			buffer *bytes.Buffer
+			err    error
+		)
+
+		JustBeforeEach(func() {
+			buffer = &bytes.Buffer{}
+			Expect(metricsStore.WriteSnapshot(buffer)).To(Succeed())
+			err = restoredStore.ReadSnapshot(buffer)
+		})
+
+		It("restores the cached metrics", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(restoredStore.GetValueMetrics()).To(Equal(metricsStore.GetValueMetrics()))
+			Expect(restoredStore.GetCounterEvents()).To(Equal(metricsStore.GetCounterEvents()))
+		})
+
+		It("shares the restored tag sets with the metrics received later", func() {
+			restoredStore.AddMetric(
+				&events.Envelope{
+					Origin:     proto.String("fake-origin"),
+					EventType:  events.Envelope_ValueMetric.Enum(),
+					Timestamp:  proto.Int64(time.Now().UnixNano()),
+					Deployment: proto.String("fake-deployment-name"),
+					Job:        proto.String("fake-job-name"),
+					Index:      proto.String("1"),
+					Ip:         proto.String("1.2.3.5"),
+					ValueMetric: &events.ValueMetric{
+						Name:  proto.String("FakeValueMetric"),
+						Value: proto.Float64(1000),
+						Unit:  proto.String("kb"),
+					},
+					Tags: map[string]string{"tag": "fake-tag"},
+				},
+			)
+
+			valueMetrics := restoredStore.GetValueMetrics()
+			Expect(valueMetrics).To(HaveLen(2))
+			Expect(reflect.ValueOf(valueMetrics[0].Tags).Pointer()).To(Equal(reflect.ValueOf(valueMetrics[1].Tags).Pointer()))
+		})
+
+		It("restores the v2 container metrics merged into the container metrics received later", func() {
+			metricsStore.AddMetric(
+				&events.Envelope{
+					Origin:     proto.String("fake-origin"),
+					EventType:  events.Envelope_ValueMetric.Enum(),
+					Timestamp:  proto.Int64(time.Now().UnixNano()),
+					Deployment: proto.String("fake-deployment-name"),
+					Job:        proto.String("fake-job-name"),
+					Index:      proto.String("0"),
+					Ip:         proto.String("1.2.3.4"),
+					ValueMetric: &events.ValueMetric{
+						Name:  proto.String(ContainerMetricLogRate),
+						Value: proto.Float64(100),
+						Unit:  proto.String("B/s"),
+					},
+					Tags: map[string]string{"source_id": "fake-application-id", "instance_id": "1"},
+				},
+			)
+			buffer = &bytes.Buffer{}
+			Expect(metricsStore.WriteSnapshot(buffer)).To(Succeed())
+			restoredStore = NewStore(metricsExpiration, time.Minute, deploymentFilter, eventFilter)
+			Expect(restoredStore.ReadSnapshot(buffer)).To(Succeed())
+
+			restoredStore.AddMetric(
+				&events.Envelope{
+					Origin:     proto.String("fake-origin"),
+					EventType:  events.Envelope_ContainerMetric.Enum(),
+					Timestamp:  proto.Int64(time.Now().UnixNano()),
+					Deployment: proto.String("fake-deployment-name"),
+					Job:        proto.String("fake-job-name"),
+					Index:      proto.String("0"),
+					Ip:         proto.String("1.2.3.4"),
+					ContainerMetric: &events.ContainerMetric{
+						ApplicationId: proto.String("fake-application-id"),
+						InstanceIndex: proto.Int32(1),
+					},
+				},
+			)
+
+			containerMetrics := restoredStore.GetContainerMetrics()
+			Expect(containerMetrics).To(HaveLen(1))
+			Expect(containerMetrics[0].LogRate).ToNot(BeNil())
+			Expect(*containerMetrics[0].LogRate).To(Equal(float64(100)))
+		})
+
+		It("restores the internal metrics", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(restoredStore.GetInternalMetrics()).To(Equal(metricsStore.GetInternalMetrics()))
+		})
+
+		Context("when the cached metrics have expired", func() {
+			BeforeEach(func() {
+				metricsExpiration = 50 * time.Millisecond
+			})
+
+			JustBeforeEach(func() {
+				buffer = &bytes.Buffer{}
+				Expect(metricsStore.WriteSnapshot(buffer)).To(Succeed())
+				time.Sleep(100 * time.Millisecond)
+				err = restoredStore.ReadSnapshot(buffer)
+			})
+
+			It("discards them", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(restoredStore.GetValueMetrics()).To(BeEmpty())
+				Expect(restoredStore.GetCounterEvents()).To(BeEmpty())
+			})
+
+			It("still restores the internal totals", func() {
+				Expect(restoredStore.GetInternalMetrics().TotalEnvelopesReceived).To(Equal(int64(2)))
+			})
+		})
+	})
+
+	Describe("SaveSnapshot and LoadSnapshot", func() {
+		var (
+			snapshotDir  string
+			snapshotPath string
+		)
+
+		BeforeEach(func() {
+			var err error
+			snapshotDir, err = ioutil.TempDir("", "firehose_exporter")
+			Expect(err).ToNot(HaveOccurred())
+			snapshotPath = filepath.Join(snapshotDir, "store.snapshot")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(snapshotDir)
+		})
+
+		It("round-trips the store through a file", func() {
+			Expect(metricsStore.SaveSnapshot(snapshotPath)).To(Succeed())
+			Expect(restoredStore.LoadSnapshot(snapshotPath)).To(Succeed())
+			Expect(restoredStore.GetValueMetrics()).To(Equal(metricsStore.GetValueMetrics()))
+		})
+
+		It("does not leave temporary files behind", func() {
+			Expect(metricsStore.SaveSnapshot(snapshotPath)).To(Succeed())
+			files, err := ioutil.ReadDir(snapshotDir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(files).To(HaveLen(1))
+		})
+
+		It("saves consistent entries while envelopes are added", func() {
+			httpStartStopEnvelope := func(requestID uint64, peerType events.PeerType) *events.Envelope {
+				return &events.Envelope{
+					Origin:     proto.String("fake-origin"),
+					EventType:  events.Envelope_HttpStartStop.Enum(),
+					Timestamp:  proto.Int64(time.Now().UnixNano()),
+					Deployment: proto.String("fake-deployment-name"),
+					HttpStartStop: &events.HttpStartStop{
+						StartTimestamp: proto.Int64(1),
+						StopTimestamp:  proto.Int64(2),
+						RequestId:      &events.UUID{Low: proto.Uint64(requestID), High: proto.Uint64(1)},
+						PeerType:       peerType.Enum(),
+						Method:         events.Method_GET.Enum(),
+						Uri:            proto.String("/fake"),
+					},
+				}
+			}
+
+			stop := make(chan struct{})
+			done := make(chan uint64)
+			go func() {
+				defer GinkgoRecover()
+				requestID := uint64(0)
+				for {
+					select {
+					case <-stop:
+						done <- requestID
+						return
+					default:
+					}
+					metricsStore.AddMetric(httpStartStopEnvelope(requestID, events.PeerType_Client))
+					metricsStore.AddMetric(httpStartStopEnvelope(requestID, events.PeerType_Server))
+					requestID++
+				}
+			}()
+
+			for i := 0; i < 20; i++ {
+				Expect(metricsStore.SaveSnapshot(snapshotPath)).To(Succeed())
+			}
+			close(stop)
+			requests := <-done
+
+			Expect(metricsStore.SaveSnapshot(snapshotPath)).To(Succeed())
+			Expect(restoredStore.LoadSnapshot(snapshotPath)).To(Succeed())
+			Expect(restoredStore.GetHttpStartStops()).To(HaveLen(int(requests)))
+			for _, httpStartStop := range restoredStore.GetHttpStartStops() {
+				Expect(httpStartStop.ServerStartTimestamp).ToNot(BeZero())
+			}
+		})
+
+		Context("when the snapshot does not exist", func() {
+			It("returns a not exist error", func() {
+				err := restoredStore.LoadSnapshot(snapshotPath)
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+		})
+	})
+})
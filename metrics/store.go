@@ -3,6 +3,7 @@ package metrics
 import (
 	"bytes"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/cloudfoundry/sonde-go/events"
@@ -13,15 +14,47 @@ import (
 )
 
 type Store struct {
-	metricsExpiration      time.Duration
-	metricsCleanupInterval time.Duration
-	deploymentFilter       *filters.DeploymentFilter
-	eventFilter            *filters.EventFilter
-	internalMetrics        *cache.Cache
-	containerMetrics       *cache.Cache
-	counterEvents          *cache.Cache
-	httpStartStops         *cache.Cache
-	valueMetrics           *cache.Cache
+	metricsExpiration       time.Duration
+	metricsCleanupInterval  time.Duration
+	deploymentFilter        *filters.DeploymentFilter
+	eventFilter             *filters.EventFilter
+	internalMetrics         *cache.Cache
+	containerMetrics        *cache.Cache
+	containerMetricsV2      *cache.Cache
+	counterEvents           *cache.Cache
+	httpStartStops          *cache.Cache
+	valueMetrics            *cache.Cache
+	maxCachedSeries         int
+	dedupWindow             *dedupWindow
+	joinWindow              *joinWindow
+	discardStaleEnvelopes   bool
+	staleEnvelopesTolerance time.Duration
+	ingestionStats          IngestionStats
+	ingestionStatsMutex     sync.Mutex
+	interner                *interner
+	httpStartStopObservers  []HttpStartStopObserver
+	counterEventObservers   []CounterEventObserver
+	valueMetricObservers    []ValueMetricObserver
+	writeMutex              sync.RWMutex
+}
+
+// HttpStartStopObserver is notified every time one of the peers of an http
+// start stop event is added to the store. It receives a copy of the event
+// merged with the peers already received for the same request.
+type HttpStartStopObserver interface {
+	ObserveHttpStartStop(httpStartStop HttpStartStop, peerType events.PeerType)
+}
+
+// CounterEventObserver is notified with a copy of every counter event added
+// to the store.
+type CounterEventObserver interface {
+	ObserveCounterEvent(counterEvent CounterEvent)
+}
+
+// ValueMetricObserver is notified with a copy of every value metric added to
+// the store.
+type ValueMetricObserver interface {
+	ObserveValueMetric(valueMetric ValueMetric)
 }
 
 func NewStore(
@@ -32,6 +65,7 @@ func NewStore(
 ) *Store {
 	internalMetrics := cache.New(metricsExpiration, metricsCleanupInterval)
 	containerMetrics := cache.New(metricsExpiration, metricsCleanupInterval)
+	containerMetricsV2 := cache.New(metricsExpiration, metricsCleanupInterval)
 	counterEvents := cache.New(metricsExpiration, metricsCleanupInterval)
 	httpStartStops := cache.New(metricsExpiration, metricsCleanupInterval)
 	valueMetrics := cache.New(metricsExpiration, metricsCleanupInterval)
@@ -43,11 +77,15 @@ func NewStore(
 		eventFilter:            eventFilter,
 		internalMetrics:        internalMetrics,
 		containerMetrics:       containerMetrics,
+		containerMetricsV2:     containerMetricsV2,
 		counterEvents:          counterEvents,
 		httpStartStops:         httpStartStops,
 		valueMetrics:           valueMetrics,
+		ingestionStats:         IngestionStats{},
+		interner:               newInterner(),
 	}
 	store.SetInternalMetrics(InternalMetrics{})
+	httpStartStops.OnEvicted(store.evictHttpStartStop)
 
 	return store
 }
@@ -101,6 +139,18 @@ func (s *Store) GetInternalMetrics() InternalMetrics {
 	if lastHttpStartStopReceivedTimestamp, ok := s.internalMetrics.Get(LastHttpStartStopReceivedTimestampKey); ok {
 		internalMetrics.LastHttpStartStopReceivedTimestamp = lastHttpStartStopReceivedTimestamp.(int64)
 	}
+	if totalHttpStartStopMatched, ok := s.internalMetrics.Get(TotalHttpStartStopMatchedKey); ok {
+		internalMetrics.TotalHttpStartStopMatched = totalHttpStartStopMatched.(int64)
+	}
+	if totalHttpStartStopUnmatchedClient, ok := s.internalMetrics.Get(TotalHttpStartStopUnmatchedClientKey); ok {
+		internalMetrics.TotalHttpStartStopUnmatchedClient = totalHttpStartStopUnmatchedClient.(int64)
+	}
+	if totalHttpStartStopUnmatchedServer, ok := s.internalMetrics.Get(TotalHttpStartStopUnmatchedServerKey); ok {
+		internalMetrics.TotalHttpStartStopUnmatchedServer = totalHttpStartStopUnmatchedServer.(int64)
+	}
+	if totalHttpStartStopUnknownPeer, ok := s.internalMetrics.Get(TotalHttpStartStopUnknownPeerKey); ok {
+		internalMetrics.TotalHttpStartStopUnknownPeer = totalHttpStartStopUnknownPeer.(int64)
+	}
 
 	if totalValueMetricsReceived, ok := s.internalMetrics.Get(TotalValueMetricsReceivedKey); ok {
 		internalMetrics.TotalValueMetricsReceived = totalValueMetricsReceived.(int64)
@@ -122,6 +172,20 @@ func (s *Store) GetInternalMetrics() InternalMetrics {
 		internalMetrics.LastSlowConsumerAlertTimestamp = lastSlowConsumerAlertTimestamp.(int64)
 	}
 
+	if totalDuplicateEnvelopesSuppressed, ok := s.internalMetrics.Get(TotalDuplicateEnvelopesSuppressedKey); ok {
+		internalMetrics.TotalDuplicateEnvelopesSuppressed = totalDuplicateEnvelopesSuppressed.(int64)
+	}
+	if totalStaleEnvelopesDiscarded, ok := s.internalMetrics.Get(TotalStaleEnvelopesDiscardedKey); ok {
+		internalMetrics.TotalStaleEnvelopesDiscarded = totalStaleEnvelopesDiscarded.(int64)
+	}
+
+	if totalCounterEventsTagLabelCollisions, ok := s.internalMetrics.Get(TotalCounterEventsTagLabelCollisionsKey); ok {
+		internalMetrics.TotalCounterEventsTagLabelCollisions = totalCounterEventsTagLabelCollisions.(int64)
+	}
+	if totalValueMetricsTagLabelCollisions, ok := s.internalMetrics.Get(TotalValueMetricsTagLabelCollisionsKey); ok {
+		internalMetrics.TotalValueMetricsTagLabelCollisions = totalValueMetricsTagLabelCollisions.(int64)
+	}
+
 	return internalMetrics
 }
 
@@ -139,11 +203,42 @@ func (s *Store) SetInternalMetrics(internalMetrics InternalMetrics) {
 	s.internalMetrics.Set(TotalHttpStartStopReceivedKey, int64(internalMetrics.TotalHttpStartStopReceived), cache.NoExpiration)
 	s.internalMetrics.Set(TotalHttpStartStopProcessedKey, int64(internalMetrics.TotalHttpStartStopProcessed), cache.NoExpiration)
 	s.internalMetrics.Set(LastHttpStartStopReceivedTimestampKey, int64(internalMetrics.LastHttpStartStopReceivedTimestamp), cache.NoExpiration)
+	s.internalMetrics.Set(TotalHttpStartStopMatchedKey, int64(internalMetrics.TotalHttpStartStopMatched), cache.NoExpiration)
+	s.internalMetrics.Set(TotalHttpStartStopUnmatchedClientKey, int64(internalMetrics.TotalHttpStartStopUnmatchedClient), cache.NoExpiration)
+	s.internalMetrics.Set(TotalHttpStartStopUnmatchedServerKey, int64(internalMetrics.TotalHttpStartStopUnmatchedServer), cache.NoExpiration)
+	s.internalMetrics.Set(TotalHttpStartStopUnknownPeerKey, int64(internalMetrics.TotalHttpStartStopUnknownPeer), cache.NoExpiration)
 	s.internalMetrics.Set(TotalValueMetricsReceivedKey, int64(internalMetrics.TotalValueMetricsReceived), cache.NoExpiration)
 	s.internalMetrics.Set(TotalValueMetricsProcessedKey, int64(internalMetrics.TotalValueMetricsProcessed), cache.NoExpiration)
 	s.internalMetrics.Set(LastValueMetricReceivedTimestampKey, int64(internalMetrics.LastValueMetricReceivedTimestamp), cache.NoExpiration)
 	s.internalMetrics.Set(SlowConsumerAlertKey, internalMetrics.SlowConsumerAlert, cache.DefaultExpiration)
 	s.internalMetrics.Set(LastSlowConsumerAlertTimestampKey, int64(internalMetrics.LastSlowConsumerAlertTimestamp), cache.NoExpiration)
+	s.internalMetrics.Set(TotalDuplicateEnvelopesSuppressedKey, int64(internalMetrics.TotalDuplicateEnvelopesSuppressed), cache.NoExpiration)
+	s.internalMetrics.Set(TotalStaleEnvelopesDiscardedKey, int64(internalMetrics.TotalStaleEnvelopesDiscarded), cache.NoExpiration)
+	s.internalMetrics.Set(TotalCounterEventsTagLabelCollisionsKey, int64(internalMetrics.TotalCounterEventsTagLabelCollisions), cache.NoExpiration)
+	s.internalMetrics.Set(TotalValueMetricsTagLabelCollisionsKey, int64(internalMetrics.TotalValueMetricsTagLabelCollisions), cache.NoExpiration)
+}
+
+// AddTagLabelCollisions counts the collected counter events or value metrics
+// whose tags collided with a built-in label.
+func (s *Store) AddTagLabelCollisions(eventType events.Envelope_EventType, collisions int64) {
+	switch eventType {
+	case events.Envelope_CounterEvent:
+		s.internalMetrics.IncrementInt64(TotalCounterEventsTagLabelCollisionsKey, collisions)
+	case events.Envelope_ValueMetric:
+		s.internalMetrics.IncrementInt64(TotalValueMetricsTagLabelCollisionsKey, collisions)
+	}
+}
+
+func (s *Store) AddHttpStartStopObserver(observer HttpStartStopObserver) {
+	s.httpStartStopObservers = append(s.httpStartStopObservers, observer)
+}
+
+func (s *Store) AddCounterEventObserver(observer CounterEventObserver) {
+	s.counterEventObservers = append(s.counterEventObservers, observer)
+}
+
+func (s *Store) AddValueMetricObserver(observer ValueMetricObserver) {
+	s.valueMetricObservers = append(s.valueMetricObservers, observer)
 }
 
 func (s *Store) AlertSlowConsumerError() {
@@ -151,7 +246,12 @@ func (s *Store) AlertSlowConsumerError() {
 	s.internalMetrics.Set(LastSlowConsumerAlertTimestampKey, time.Now().Unix(), cache.NoExpiration)
 }
 
+// AddMetric adds an envelope to the store. Envelopes are added one at a
+// time, as http start stops are merged in place with their peer.
 func (s *Store) AddMetric(envelope *events.Envelope) {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
 	s.internalMetrics.IncrementInt64(TotalEnvelopesReceivedKey, 1)
 	s.internalMetrics.Set(LastEnvelopReceivedTimestampKey, time.Now().Unix(), cache.NoExpiration)
 
@@ -179,6 +279,7 @@ func (s *Store) GetContainerMetrics() ContainerMetrics {
 
 func (s *Store) FlushContainerMetrics() {
 	s.containerMetrics.Flush()
+	s.containerMetricsV2.Flush()
 }
 
 func (s *Store) GetCounterEvents() CounterEvents {
@@ -229,26 +330,30 @@ func (s *Store) addContainerMetric(envelope *events.Envelope) {
 	s.internalMetrics.IncrementInt64(TotalContainerMetricsReceivedKey, 1)
 	s.internalMetrics.Set(LastContainerMetricReceivedTimestampKey, time.Now().Unix(), cache.NoExpiration)
 
-	if s.deploymentFilter.Enabled(envelope.GetDeployment()) && s.eventFilter.Enabled(envelope) {
+	if metricKey, ok := s.admit(envelope, s.containerMetrics); ok {
 		s.internalMetrics.IncrementInt64(TotalContainerMetricsProcessedKey, 1)
 
 		containerMetric := &ContainerMetric{
-			Origin:           envelope.GetOrigin(),
+			Origin:           s.interner.string(envelope.GetOrigin()),
 			Timestamp:        envelope.GetTimestamp(),
-			Deployment:       envelope.GetDeployment(),
-			Job:              envelope.GetJob(),
-			Index:            envelope.GetIndex(),
-			IP:               envelope.GetIp(),
-			Tags:             envelope.GetTags(),
-			ApplicationId:    envelope.GetContainerMetric().GetApplicationId(),
+			Deployment:       s.interner.string(envelope.GetDeployment()),
+			Job:              s.interner.string(envelope.GetJob()),
+			Index:            s.interner.string(envelope.GetIndex()),
+			IP:               s.interner.string(envelope.GetIp()),
+			Tags:             s.interner.tags(envelope.GetTags()),
+			ApplicationId:    s.interner.string(envelope.GetContainerMetric().GetApplicationId()),
 			InstanceIndex:    envelope.GetContainerMetric().GetInstanceIndex(),
 			CpuPercentage:    envelope.GetContainerMetric().GetCpuPercentage(),
 			MemoryBytes:      envelope.GetContainerMetric().GetMemoryBytes(),
 			DiskBytes:        envelope.GetContainerMetric().GetDiskBytes(),
 			MemoryBytesQuota: envelope.GetContainerMetric().GetMemoryBytesQuota(),
 			DiskBytesQuota:   envelope.GetContainerMetric().GetDiskBytesQuota(),
+			ReceivedAt:       time.Now().UnixNano(),
 		}
-		s.containerMetrics.Set(s.metricKey(envelope), containerMetric, cache.DefaultExpiration)
+		if containerMetricV2, ok := s.containerMetricsV2.Get(metricKey); ok {
+			containerMetric.mergeV2Metrics(containerMetricV2.(*ContainerMetric))
+		}
+		s.containerMetrics.Set(metricKey, containerMetric, cache.DefaultExpiration)
 	}
 }
 
@@ -258,22 +363,59 @@ func (s *Store) addCounterEvent(envelope *events.Envelope) {
 	s.internalMetrics.IncrementInt64(TotalCounterEventsReceivedKey, 1)
 	s.internalMetrics.Set(LastCounterEventReceivedTimestampKey, time.Now().Unix(), cache.NoExpiration)
 
-	if s.deploymentFilter.Enabled(envelope.GetDeployment()) && s.eventFilter.Enabled(envelope) {
+	if metricKey, ok := s.admit(envelope, s.counterEvents); ok {
 		s.internalMetrics.IncrementInt64(TotalCounterEventsProcessedKey, 1)
 
 		counterEvent := &CounterEvent{
-			Origin:     envelope.GetOrigin(),
-			Timestamp:  envelope.GetTimestamp(),
-			Deployment: envelope.GetDeployment(),
-			Job:        envelope.GetJob(),
-			Index:      envelope.GetIndex(),
-			IP:         envelope.GetIp(),
-			Tags:       envelope.GetTags(),
-			Name:       envelope.GetCounterEvent().GetName(),
-			Delta:      envelope.GetCounterEvent().GetDelta(),
-			Total:      envelope.GetCounterEvent().GetTotal(),
+			Origin:         s.interner.string(envelope.GetOrigin()),
+			Timestamp:      envelope.GetTimestamp(),
+			Deployment:     s.interner.string(envelope.GetDeployment()),
+			Job:            s.interner.string(envelope.GetJob()),
+			Index:          s.interner.string(envelope.GetIndex()),
+			IP:             s.interner.string(envelope.GetIp()),
+			Tags:           s.interner.tags(envelope.GetTags()),
+			Name:           s.interner.string(envelope.GetCounterEvent().GetName()),
+			Delta:          envelope.GetCounterEvent().GetDelta(),
+			Total:          envelope.GetCounterEvent().GetTotal(),
+			MonotonicTotal: envelope.GetCounterEvent().GetDelta(),
+			ReceivedAt:     time.Now().UnixNano(),
+		}
+
+		if storeCounterEvent, ok := s.counterEvents.Get(metricKey); ok {
+			previousCounterEvent := storeCounterEvent.(*CounterEvent)
+			counterEvent.MonotonicTotal += previousCounterEvent.MonotonicTotal
+			counterEvent.Resets = previousCounterEvent.Resets
+			if counterEvent.Total < previousCounterEvent.Total {
+				counterEvent.Resets++
+			}
+		}
+
+		s.counterEvents.Set(metricKey, counterEvent, cache.DefaultExpiration)
+
+		for _, observer := range s.counterEventObservers {
+			observer.ObserveCounterEvent(*counterEvent)
 		}
-		s.counterEvents.Set(s.metricKey(envelope), counterEvent, cache.DefaultExpiration)
+	}
+}
+
+// accumulateStaleCounterEvent adds the delta of a stale counter event to the
+// monotonic total of its series, as it was counted by the emitter anyway. Only
+// the stale total and timestamp are discarded: the cached ones are newer, so
+// comparing them does not detect any reset.
+func (s *Store) accumulateStaleCounterEvent(envelope *events.Envelope, metricKey string) {
+	storeCounterEvent, ok := s.counterEvents.Get(metricKey)
+	if !ok {
+		return
+	}
+
+	counterEvent := *storeCounterEvent.(*CounterEvent)
+	counterEvent.Delta = envelope.GetCounterEvent().GetDelta()
+	counterEvent.MonotonicTotal += counterEvent.Delta
+	counterEvent.ReceivedAt = time.Now().UnixNano()
+	s.counterEvents.Set(metricKey, &counterEvent, cache.DefaultExpiration)
+
+	for _, observer := range s.counterEventObservers {
+		observer.ObserveCounterEvent(counterEvent)
 	}
 }
 
@@ -282,23 +424,31 @@ func (s *Store) addHttpStartStop(envelope *events.Envelope) {
 	s.internalMetrics.Set(LastMetricReceivedTimestampKey, time.Now().Unix(), cache.NoExpiration)
 	s.internalMetrics.IncrementInt64(TotalHttpStartStopReceivedKey, 1)
 	s.internalMetrics.Set(LastHttpStartStopReceivedTimestampKey, time.Now().Unix(), cache.NoExpiration)
+	s.flushUnmatchedHttpStartStops()
 
-	if s.deploymentFilter.Enabled(envelope.GetDeployment()) && s.eventFilter.Enabled(envelope) {
+	peerType := envelope.GetHttpStartStop().GetPeerType()
+	if peerType != events.PeerType_Client && peerType != events.PeerType_Server {
+		s.internalMetrics.IncrementInt64(TotalHttpStartStopUnknownPeerKey, 1)
+		s.recordIngestion(envelope, IngestionOutcomeMalformed)
+		return
+	}
+
+	if metricKey, ok := s.admit(envelope, s.httpStartStops); ok {
 		s.internalMetrics.IncrementInt64(TotalHttpStartStopProcessedKey, 1)
 
 		var httpStartStop *HttpStartStop
-		storeHttpStartStop, ok := s.httpStartStops.Get(s.metricKey(envelope))
+		storeHttpStartStop, ok := s.httpStartStops.Get(metricKey)
 		if ok {
 			httpStartStop = storeHttpStartStop.(*HttpStartStop)
 		} else {
 			httpStartStop = &HttpStartStop{
-				Origin:        envelope.GetOrigin(),
+				Origin:        s.interner.string(envelope.GetOrigin()),
 				Timestamp:     envelope.GetTimestamp(),
-				Deployment:    envelope.GetDeployment(),
-				Job:           envelope.GetJob(),
-				Index:         envelope.GetIndex(),
-				IP:            envelope.GetIp(),
-				Tags:          envelope.GetTags(),
+				Deployment:    s.interner.string(envelope.GetDeployment()),
+				Job:           s.interner.string(envelope.GetJob()),
+				Index:         s.interner.string(envelope.GetIndex()),
+				IP:            s.interner.string(envelope.GetIp()),
+				Tags:          s.interner.tags(envelope.GetTags()),
 				RequestId:     utils.UUIDToString(envelope.GetHttpStartStop().GetRequestId()),
 				Method:        envelope.GetHttpStartStop().GetMethod().String(),
 				Uri:           envelope.GetHttpStartStop().GetUri(),
@@ -309,7 +459,9 @@ func (s *Store) addHttpStartStop(envelope *events.Envelope) {
 			}
 		}
 
-		switch envelope.GetHttpStartStop().GetPeerType() {
+		s.joinHttpStartStop(metricKey, httpStartStop, peerType)
+
+		switch peerType {
 		case events.PeerType_Client:
 			httpStartStop.ApplicationId = utils.UUIDToString(envelope.GetHttpStartStop().GetApplicationId())
 			httpStartStop.InstanceIndex = envelope.GetHttpStartStop().GetInstanceIndex()
@@ -319,36 +471,48 @@ func (s *Store) addHttpStartStop(envelope *events.Envelope) {
 		case events.PeerType_Server:
 			httpStartStop.ServerStartTimestamp = envelope.GetHttpStartStop().GetStartTimestamp()
 			httpStartStop.ServerStopTimestamp = envelope.GetHttpStartStop().GetStopTimestamp()
-		default:
-			return
 		}
 
-		s.httpStartStops.Set(s.metricKey(envelope), httpStartStop, cache.DefaultExpiration)
+		httpStartStop.ReceivedAt = time.Now().UnixNano()
+		s.httpStartStops.Set(metricKey, httpStartStop, cache.DefaultExpiration)
+
+		for _, observer := range s.httpStartStopObservers {
+			observer.ObserveHttpStartStop(*httpStartStop, peerType)
+		}
 	}
 }
 
 func (s *Store) addValueMetric(envelope *events.Envelope) {
+	if s.addContainerMetricV2(envelope) {
+		return
+	}
+
 	s.internalMetrics.IncrementInt64(TotalMetricsReceivedKey, 1)
 	s.internalMetrics.Set(LastMetricReceivedTimestampKey, time.Now().Unix(), cache.NoExpiration)
 	s.internalMetrics.IncrementInt64(TotalValueMetricsReceivedKey, 1)
 	s.internalMetrics.Set(LastValueMetricReceivedTimestampKey, time.Now().Unix(), cache.NoExpiration)
 
-	if s.deploymentFilter.Enabled(envelope.GetDeployment()) && s.eventFilter.Enabled(envelope) {
+	if metricKey, ok := s.admit(envelope, s.valueMetrics); ok {
 		s.internalMetrics.IncrementInt64(TotalValueMetricsProcessedKey, 1)
 
 		valueMetric := &ValueMetric{
-			Origin:     envelope.GetOrigin(),
+			Origin:     s.interner.string(envelope.GetOrigin()),
 			Timestamp:  envelope.GetTimestamp(),
-			Deployment: envelope.GetDeployment(),
-			Job:        envelope.GetJob(),
-			Index:      envelope.GetIndex(),
-			IP:         envelope.GetIp(),
-			Tags:       envelope.GetTags(),
-			Name:       envelope.GetValueMetric().GetName(),
+			Deployment: s.interner.string(envelope.GetDeployment()),
+			Job:        s.interner.string(envelope.GetJob()),
+			Index:      s.interner.string(envelope.GetIndex()),
+			IP:         s.interner.string(envelope.GetIp()),
+			Tags:       s.interner.tags(envelope.GetTags()),
+			Name:       s.interner.string(envelope.GetValueMetric().GetName()),
 			Value:      envelope.GetValueMetric().GetValue(),
-			Unit:       envelope.GetValueMetric().GetUnit(),
+			Unit:       s.interner.string(envelope.GetValueMetric().GetUnit()),
+			ReceivedAt: time.Now().UnixNano(),
+		}
+		s.valueMetrics.Set(metricKey, valueMetric, cache.DefaultExpiration)
+
+		for _, observer := range s.valueMetricObservers {
+			observer.ObserveValueMetric(*valueMetric)
 		}
-		s.valueMetrics.Set(s.metricKey(envelope), valueMetric, cache.DefaultExpiration)
 	}
 }
 
@@ -0,0 +1,91 @@
+package metrics_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bosh-prometheus/firehose_exporter/filters"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/bosh-prometheus/firehose_exporter/metrics"
+)
+
+const benchmarkSeries = 1000
+
+func benchmarkStore() *Store {
+	deploymentFilter := filters.NewDeploymentFilter([]string{})
+	eventFilter, _ := filters.NewEventFilter([]string{})
+	return NewStore(time.Minute, time.Minute, deploymentFilter, eventFilter)
+}
+
+func benchmarkTags() map[string]string {
+	return map[string]string{
+		"source_id":     "fake-source-id",
+		"product":       "Pivotal Application Service",
+		"system_domain": "sys.example.com",
+	}
+}
+
+func BenchmarkAddValueMetric(b *testing.B) {
+	metricsStore := benchmarkStore()
+
+	envelopes := make([]*events.Envelope, benchmarkSeries)
+	for i := range envelopes {
+		envelopes[i] = &events.Envelope{
+			Origin:     proto.String("fake-origin"),
+			EventType:  events.Envelope_ValueMetric.Enum(),
+			Timestamp:  proto.Int64(time.Now().UnixNano()),
+			Deployment: proto.String("fake-deployment-name"),
+			Job:        proto.String("fake-job-name"),
+			Index:      proto.String(strconv.Itoa(i % 10)),
+			Ip:         proto.String("1.2.3.4"),
+			Tags:       benchmarkTags(),
+			ValueMetric: &events.ValueMetric{
+				Name:  proto.String("FakeValueMetric" + strconv.Itoa(i)),
+				Value: proto.Float64(float64(i)),
+				Unit:  proto.String("count"),
+			},
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		metricsStore.AddMetric(envelopes[i%benchmarkSeries])
+	}
+}
+
+func BenchmarkAddContainerMetric(b *testing.B) {
+	metricsStore := benchmarkStore()
+
+	envelopes := make([]*events.Envelope, benchmarkSeries)
+	for i := range envelopes {
+		envelopes[i] = &events.Envelope{
+			Origin:     proto.String("rep"),
+			EventType:  events.Envelope_ContainerMetric.Enum(),
+			Timestamp:  proto.Int64(time.Now().UnixNano()),
+			Deployment: proto.String("fake-deployment-name"),
+			Job:        proto.String("diego_cell"),
+			Index:      proto.String(strconv.Itoa(i % 10)),
+			Ip:         proto.String("1.2.3.4"),
+			Tags:       benchmarkTags(),
+			ContainerMetric: &events.ContainerMetric{
+				ApplicationId:    proto.String("fake-application-id-" + strconv.Itoa(i/4)),
+				InstanceIndex:    proto.Int32(int32(i % 4)),
+				CpuPercentage:    proto.Float64(0.5),
+				MemoryBytes:      proto.Uint64(1000),
+				DiskBytes:        proto.Uint64(1500),
+				MemoryBytesQuota: proto.Uint64(2000),
+				DiskBytesQuota:   proto.Uint64(3000),
+			},
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		metricsStore.AddMetric(envelopes[i%benchmarkSeries])
+	}
+}
@@ -150,6 +150,22 @@ var _ = Describe("Store", func() {
 			Expect(internalMetrics.LastHttpStartStopReceivedTimestamp).To(Equal(int64(0)))
 		})
 
+		It("returns the TotalHttpStartStopMatched", func() {
+			Expect(internalMetrics.TotalHttpStartStopMatched).To(Equal(int64(0)))
+		})
+
+		It("returns the TotalHttpStartStopUnmatchedClient", func() {
+			Expect(internalMetrics.TotalHttpStartStopUnmatchedClient).To(Equal(int64(0)))
+		})
+
+		It("returns the TotalHttpStartStopUnmatchedServer", func() {
+			Expect(internalMetrics.TotalHttpStartStopUnmatchedServer).To(Equal(int64(0)))
+		})
+
+		It("returns the TotalHttpStartStopUnknownPeer", func() {
+			Expect(internalMetrics.TotalHttpStartStopUnknownPeer).To(Equal(int64(0)))
+		})
+
 		It("returns the TotalValueMetricsReceived", func() {
 			Expect(internalMetrics.TotalValueMetricsReceived).To(Equal(int64(0)))
 		})
@@ -173,6 +189,22 @@ var _ = Describe("Store", func() {
 		It("returns the LastSlowConsumerAlertTimestamp", func() {
 			Expect(internalMetrics.LastSlowConsumerAlertTimestamp).To(Equal(int64(0)))
 		})
+
+		It("returns the TotalDuplicateEnvelopesSuppressed", func() {
+			Expect(internalMetrics.TotalDuplicateEnvelopesSuppressed).To(Equal(int64(0)))
+		})
+
+		It("returns the TotalStaleEnvelopesDiscarded", func() {
+			Expect(internalMetrics.TotalStaleEnvelopesDiscarded).To(Equal(int64(0)))
+		})
+
+		It("returns the TotalCounterEventsTagLabelCollisions", func() {
+			Expect(internalMetrics.TotalCounterEventsTagLabelCollisions).To(Equal(int64(0)))
+		})
+
+		It("returns the TotalValueMetricsTagLabelCollisions", func() {
+			Expect(internalMetrics.TotalValueMetricsTagLabelCollisions).To(Equal(int64(0)))
+		})
 	})
 
 	Describe("SetInternalMetrics", func() {
@@ -190,11 +222,19 @@ var _ = Describe("Store", func() {
 			totalHttpStartStopReceived           = int64(300)
 			totalHttpStartStopProcessed          = int64(150)
 			lastHttpStartStopReceivedTimestamp   = time.Now().Unix()
+			totalHttpStartStopMatched            = int64(120)
+			totalHttpStartStopUnmatchedClient    = int64(10)
+			totalHttpStartStopUnmatchedServer    = int64(5)
+			totalHttpStartStopUnknownPeer        = int64(2)
 			totalValueMetricsReceived            = int64(300)
 			totalValueMetricsProcessed           = int64(150)
 			lastValueMetricReceivedTimestamp     = time.Now().Unix()
 			slowConsumerAlert                    = true
 			lastSlowConsumerAlertTimestamp       = time.Now().Unix()
+			totalDuplicateEnvelopesSuppressed    = int64(25)
+			totalStaleEnvelopesDiscarded         = int64(35)
+			totalCounterEventsTagLabelCollisions = int64(3)
+			totalValueMetricsTagLabelCollisions  = int64(4)
 		)
 
 		BeforeEach(func() {
@@ -212,11 +252,19 @@ var _ = Describe("Store", func() {
 				TotalHttpStartStopReceived:           totalHttpStartStopReceived,
 				TotalHttpStartStopProcessed:          totalHttpStartStopProcessed,
 				LastHttpStartStopReceivedTimestamp:   lastHttpStartStopReceivedTimestamp,
+				TotalHttpStartStopMatched:            totalHttpStartStopMatched,
+				TotalHttpStartStopUnmatchedClient:    totalHttpStartStopUnmatchedClient,
+				TotalHttpStartStopUnmatchedServer:    totalHttpStartStopUnmatchedServer,
+				TotalHttpStartStopUnknownPeer:        totalHttpStartStopUnknownPeer,
 				TotalValueMetricsReceived:            totalValueMetricsReceived,
 				TotalValueMetricsProcessed:           totalValueMetricsProcessed,
 				LastValueMetricReceivedTimestamp:     lastValueMetricReceivedTimestamp,
 				SlowConsumerAlert:                    slowConsumerAlert,
 				LastSlowConsumerAlertTimestamp:       lastSlowConsumerAlertTimestamp,
+				TotalDuplicateEnvelopesSuppressed:    totalDuplicateEnvelopesSuppressed,
+				TotalStaleEnvelopesDiscarded:         totalStaleEnvelopesDiscarded,
+				TotalCounterEventsTagLabelCollisions: totalCounterEventsTagLabelCollisions,
+				TotalValueMetricsTagLabelCollisions:  totalValueMetricsTagLabelCollisions,
 			})
 
 			internalMetrics = metricsStore.GetInternalMetrics()
@@ -274,6 +322,22 @@ var _ = Describe("Store", func() {
 			Expect(internalMetrics.LastHttpStartStopReceivedTimestamp).To(Equal(lastHttpStartStopReceivedTimestamp))
 		})
 
+		It("sets the TotalHttpStartStopMatched", func() {
+			Expect(internalMetrics.TotalHttpStartStopMatched).To(Equal(totalHttpStartStopMatched))
+		})
+
+		It("sets the TotalHttpStartStopUnmatchedClient", func() {
+			Expect(internalMetrics.TotalHttpStartStopUnmatchedClient).To(Equal(totalHttpStartStopUnmatchedClient))
+		})
+
+		It("sets the TotalHttpStartStopUnmatchedServer", func() {
+			Expect(internalMetrics.TotalHttpStartStopUnmatchedServer).To(Equal(totalHttpStartStopUnmatchedServer))
+		})
+
+		It("sets the TotalHttpStartStopUnknownPeer", func() {
+			Expect(internalMetrics.TotalHttpStartStopUnknownPeer).To(Equal(totalHttpStartStopUnknownPeer))
+		})
+
 		It("sets the TotalValueMetricsReceived", func() {
 			Expect(internalMetrics.TotalValueMetricsReceived).To(Equal(totalValueMetricsReceived))
 		})
@@ -293,6 +357,40 @@ var _ = Describe("Store", func() {
 		It("sets the LastSlowConsumerAlertTimestamp", func() {
 			Expect(internalMetrics.LastSlowConsumerAlertTimestamp).To(Equal(lastSlowConsumerAlertTimestamp))
 		})
+
+		It("sets the TotalDuplicateEnvelopesSuppressed", func() {
+			Expect(internalMetrics.TotalDuplicateEnvelopesSuppressed).To(Equal(totalDuplicateEnvelopesSuppressed))
+		})
+
+		It("sets the TotalStaleEnvelopesDiscarded", func() {
+			Expect(internalMetrics.TotalStaleEnvelopesDiscarded).To(Equal(totalStaleEnvelopesDiscarded))
+		})
+
+		It("sets the TotalCounterEventsTagLabelCollisions", func() {
+			Expect(internalMetrics.TotalCounterEventsTagLabelCollisions).To(Equal(totalCounterEventsTagLabelCollisions))
+		})
+
+		It("sets the TotalValueMetricsTagLabelCollisions", func() {
+			Expect(internalMetrics.TotalValueMetricsTagLabelCollisions).To(Equal(totalValueMetricsTagLabelCollisions))
+		})
+	})
+
+	Describe("AddTagLabelCollisions", func() {
+		BeforeEach(func() {
+			metricsStore.AddTagLabelCollisions(events.Envelope_CounterEvent, 2)
+			metricsStore.AddTagLabelCollisions(events.Envelope_CounterEvent, 1)
+			metricsStore.AddTagLabelCollisions(events.Envelope_ValueMetric, 4)
+
+			internalMetrics = metricsStore.GetInternalMetrics()
+		})
+
+		It("accumulates the TotalCounterEventsTagLabelCollisions", func() {
+			Expect(internalMetrics.TotalCounterEventsTagLabelCollisions).To(Equal(int64(3)))
+		})
+
+		It("accumulates the TotalValueMetricsTagLabelCollisions", func() {
+			Expect(internalMetrics.TotalValueMetricsTagLabelCollisions).To(Equal(int64(4)))
+		})
 	})
 
 	Describe("AlertSlowConsumerError", func() {
@@ -389,16 +487,17 @@ var _ = Describe("Store", func() {
 			)
 
 			counterEvent = &CounterEvent{
-				Origin:     origin,
-				Timestamp:  metricTimestamp,
-				Deployment: boshDeployment,
-				Job:        boshJob,
-				Index:      boshIndex0,
-				IP:         boshIP,
-				Tags:       map[string]string{},
-				Name:       counterEventName,
-				Delta:      counterEventDelta,
-				Total:      counterEventTotal,
+				Origin:         origin,
+				Timestamp:      metricTimestamp,
+				Deployment:     boshDeployment,
+				Job:            boshJob,
+				Index:          boshIndex0,
+				IP:             boshIP,
+				Tags:           map[string]string{},
+				Name:           counterEventName,
+				Delta:          counterEventDelta,
+				Total:          counterEventTotal,
+				MonotonicTotal: counterEventDelta,
 			}
 
 			metricsStore.AddMetric(
@@ -512,10 +611,10 @@ var _ = Describe("Store", func() {
 
 		JustBeforeEach(func() {
 			internalMetrics = metricsStore.GetInternalMetrics()
-			containerMetrics = metricsStore.GetContainerMetrics()
-			counterEvents = metricsStore.GetCounterEvents()
-			httpStartStops = metricsStore.GetHttpStartStops()
-			valueMetrics = metricsStore.GetValueMetrics()
+			containerMetrics = receivedContainerMetrics(metricsStore.GetContainerMetrics())
+			counterEvents = receivedCounterEvents(metricsStore.GetCounterEvents())
+			httpStartStops = receivedHttpStartStops(metricsStore.GetHttpStartStops())
+			valueMetrics = receivedValueMetrics(metricsStore.GetValueMetrics())
 		})
 
 		It("increments the TotalEnvelopesReceived", func() {
@@ -730,6 +829,8 @@ var _ = Describe("Store", func() {
 						},
 					},
 				)
+
+				counterEvent.MonotonicTotal = 2 * counterEventDelta
 			})
 
 			It("increments the TotalEnvelopesReceived", func() {
@@ -809,6 +910,38 @@ var _ = Describe("Store", func() {
 			})
 		})
 
+		Context("when adding a counter event whose total went backwards", func() {
+			BeforeEach(func() {
+				metricsStore.AddMetric(
+					&events.Envelope{
+						Origin:     proto.String(origin),
+						EventType:  events.Envelope_CounterEvent.Enum(),
+						Timestamp:  proto.Int64(metricTimestamp),
+						Deployment: proto.String(boshDeployment),
+						Job:        proto.String(boshJob),
+						Index:      proto.String(boshIndex0),
+						Ip:         proto.String(boshIP),
+						Tags:       map[string]string{},
+						CounterEvent: &events.CounterEvent{
+							Name:  proto.String(counterEventName),
+							Delta: proto.Uint64(counterEventDelta),
+							Total: proto.Uint64(counterEventDelta),
+						},
+					},
+				)
+			})
+
+			It("keeps increasing the MonotonicTotal", func() {
+				Expect(len(counterEvents)).To(Equal(1))
+				Expect(counterEvents[0].Total).To(Equal(counterEventDelta))
+				Expect(counterEvents[0].MonotonicTotal).To(Equal(2 * counterEventDelta))
+			})
+
+			It("counts the reset", func() {
+				Expect(counterEvents[0].Resets).To(Equal(uint64(1)))
+			})
+		})
+
 		Context("when adding the same metric with different labels", func() {
 			BeforeEach(func() {
 				metricsStore.AddMetric(
@@ -1049,7 +1182,7 @@ var _ = Describe("Store", func() {
 
 		Describe("GetContainerMetrics", func() {
 			BeforeEach(func() {
-				containerMetrics = metricsStore.GetContainerMetrics()
+				containerMetrics = receivedContainerMetrics(metricsStore.GetContainerMetrics())
 			})
 
 			It("returns the container metrics", func() {
@@ -1058,10 +1191,103 @@ var _ = Describe("Store", func() {
 			})
 		})
 
+		Context("when v2 container metrics are received", func() {
+			v2ContainerMetric := func(name string, value float64) *events.Envelope {
+				return &events.Envelope{
+					Origin:     proto.String(origin),
+					EventType:  events.Envelope_ValueMetric.Enum(),
+					Timestamp:  proto.Int64(metricTimestamp),
+					Deployment: proto.String(boshDeployment),
+					Job:        proto.String(boshJob),
+					Index:      proto.String(boshIndex0),
+					Ip:         proto.String(boshIP),
+					Tags: map[string]string{
+						"source_id":   containerMetricApplicationId,
+						"instance_id": "1",
+					},
+					ValueMetric: &events.ValueMetric{
+						Name:  proto.String(name),
+						Value: proto.Float64(value),
+						Unit:  proto.String("B/s"),
+					},
+				}
+			}
+
+			BeforeEach(func() {
+				metricsStore.AddMetric(v2ContainerMetric(ContainerMetricLogRate, 100))
+				metricsStore.AddMetric(v2ContainerMetric(ContainerMetricLogRateLimit, 1000))
+				metricsStore.AddMetric(v2ContainerMetric("unknown", 1))
+				containerMetrics = receivedContainerMetrics(metricsStore.GetContainerMetrics())
+			})
+
+			It("merges them into the container metric", func() {
+				Expect(len(containerMetrics)).To(Equal(1))
+				Expect(*containerMetrics[0].LogRate).To(Equal(float64(100)))
+				Expect(*containerMetrics[0].LogRateLimit).To(Equal(float64(1000)))
+				Expect(containerMetrics[0].CpuEntitlement).To(BeNil())
+				Expect(containerMetrics[0].CpuPercentage).To(Equal(containerMetricCpuPercentage))
+			})
+
+			It("counts them as container metrics", func() {
+				internalMetrics = metricsStore.GetInternalMetrics()
+				Expect(internalMetrics.TotalContainerMetricsReceived).To(Equal(int64(3)))
+				Expect(internalMetrics.TotalContainerMetricsProcessed).To(Equal(int64(3)))
+				Expect(internalMetrics.TotalValueMetricsReceived).To(Equal(int64(1)))
+				Expect(internalMetrics.TotalValueMetricsProcessed).To(Equal(int64(1)))
+			})
+
+			It("discards them when older than the v2 metrics of the same container", func() {
+				metricsStore.DiscardStaleEnvelopes(0)
+				staleContainerMetric := v2ContainerMetric(ContainerMetricLogRate, 50)
+				staleContainerMetric.Timestamp = proto.Int64(metricTimestamp - 1)
+				metricsStore.AddMetric(staleContainerMetric)
+
+				containerMetrics = receivedContainerMetrics(metricsStore.GetContainerMetrics())
+				Expect(*containerMetrics[0].LogRate).To(Equal(float64(100)))
+				Expect(metricsStore.GetInternalMetrics().TotalStaleEnvelopesDiscarded).To(Equal(int64(1)))
+				Expect(metricsStore.GetInternalMetrics().TotalContainerMetricsProcessed).To(Equal(int64(3)))
+			})
+
+			It("does not return them as value metrics", func() {
+				var names []string
+				for _, valueMetric := range metricsStore.GetValueMetrics() {
+					names = append(names, valueMetric.Name)
+				}
+				Expect(names).ToNot(ContainElement(ContainerMetricLogRate))
+				Expect(names).ToNot(ContainElement(ContainerMetricLogRateLimit))
+				Expect(names).To(ContainElement("unknown"))
+			})
+
+			It("keeps them for the container metrics received later", func() {
+				metricsStore.FlushContainerMetrics()
+				metricsStore.AddMetric(v2ContainerMetric(ContainerMetricCpuEntitlement, 50))
+				metricsStore.AddMetric(
+					&events.Envelope{
+						Origin:     proto.String(origin),
+						EventType:  events.Envelope_ContainerMetric.Enum(),
+						Timestamp:  proto.Int64(metricTimestamp),
+						Deployment: proto.String(boshDeployment),
+						Job:        proto.String(boshJob),
+						Index:      proto.String(boshIndex0),
+						Ip:         proto.String(boshIP),
+						ContainerMetric: &events.ContainerMetric{
+							ApplicationId: proto.String(containerMetricApplicationId),
+							InstanceIndex: proto.Int32(containerMetricInstanceIndex),
+						},
+					},
+				)
+
+				containerMetrics = receivedContainerMetrics(metricsStore.GetContainerMetrics())
+				Expect(len(containerMetrics)).To(Equal(1))
+				Expect(*containerMetrics[0].CpuEntitlement).To(Equal(float64(50)))
+				Expect(containerMetrics[0].LogRate).To(BeNil())
+			})
+		})
+
 		Describe("FlushContainerMetrics", func() {
 			BeforeEach(func() {
 				metricsStore.FlushContainerMetrics()
-				containerMetrics = metricsStore.GetContainerMetrics()
+				containerMetrics = receivedContainerMetrics(metricsStore.GetContainerMetrics())
 			})
 
 			It("returns empty container metrics", func() {
@@ -1095,16 +1321,17 @@ var _ = Describe("Store", func() {
 			)
 
 			counterEvent = &CounterEvent{
-				Origin:     origin,
-				Timestamp:  metricTimestamp,
-				Deployment: boshDeployment,
-				Job:        boshJob,
-				Index:      boshIndex0,
-				IP:         boshIP,
-				Tags:       map[string]string{},
-				Name:       counterEventName,
-				Delta:      counterEventDelta,
-				Total:      counterEventTotal,
+				Origin:         origin,
+				Timestamp:      metricTimestamp,
+				Deployment:     boshDeployment,
+				Job:            boshJob,
+				Index:          boshIndex0,
+				IP:             boshIP,
+				Tags:           map[string]string{},
+				Name:           counterEventName,
+				Delta:          counterEventDelta,
+				Total:          counterEventTotal,
+				MonotonicTotal: counterEventDelta,
 			}
 		})
 
@@ -1114,7 +1341,7 @@ var _ = Describe("Store", func() {
 
 		Describe("GetCounterEvents", func() {
 			BeforeEach(func() {
-				counterEvents = metricsStore.GetCounterEvents()
+				counterEvents = receivedCounterEvents(metricsStore.GetCounterEvents())
 			})
 
 			It("returns the counter events", func() {
@@ -1126,7 +1353,7 @@ var _ = Describe("Store", func() {
 		Describe("FlushCounterEvents", func() {
 			BeforeEach(func() {
 				metricsStore.FlushCounterEvents()
-				counterEvents = metricsStore.GetCounterEvents()
+				counterEvents = receivedCounterEvents(metricsStore.GetCounterEvents())
 			})
 
 			It("returns empty counter events", func() {
@@ -1225,7 +1452,7 @@ var _ = Describe("Store", func() {
 
 		Describe("GetHttpStartStops", func() {
 			BeforeEach(func() {
-				httpStartStops = metricsStore.GetHttpStartStops()
+				httpStartStops = receivedHttpStartStops(metricsStore.GetHttpStartStops())
 			})
 
 			It("returns the value metrics", func() {
@@ -1237,7 +1464,7 @@ var _ = Describe("Store", func() {
 		Describe("FlushHttpStartStops", func() {
 			BeforeEach(func() {
 				metricsStore.FlushHttpStartStops()
-				httpStartStops = metricsStore.GetHttpStartStops()
+				httpStartStops = receivedHttpStartStops(metricsStore.GetHttpStartStops())
 			})
 
 			It("returns empty value metrics", func() {
@@ -1290,7 +1517,7 @@ var _ = Describe("Store", func() {
 
 		Describe("GetValueMetrics", func() {
 			BeforeEach(func() {
-				valueMetrics = metricsStore.GetValueMetrics()
+				valueMetrics = receivedValueMetrics(metricsStore.GetValueMetrics())
 			})
 
 			It("returns the value metrics", func() {
@@ -1302,7 +1529,7 @@ var _ = Describe("Store", func() {
 		Describe("FlushValueMetrics", func() {
 			BeforeEach(func() {
 				metricsStore.FlushValueMetrics()
-				valueMetrics = metricsStore.GetValueMetrics()
+				valueMetrics = receivedValueMetrics(metricsStore.GetValueMetrics())
 			})
 
 			It("returns empty value metrics", func() {
@@ -1315,3 +1542,54 @@ var _ = Describe("Store", func() {
 		})
 	})
 })
+
+// The received* helpers check that the entries record when they were
+// received, and drop that time so the entries can be compared.
+
+func receivedContainerMetrics(containerMetrics ContainerMetrics) ContainerMetrics {
+	var received ContainerMetrics
+	for _, containerMetric := range containerMetrics {
+		ExpectWithOffset(1, containerMetric.ReceivedAt).ToNot(BeZero())
+		copied := *containerMetric
+		copied.ReceivedAt = 0
+		received = append(received, &copied)
+	}
+
+	return received
+}
+
+func receivedCounterEvents(counterEvents CounterEvents) CounterEvents {
+	var received CounterEvents
+	for _, counterEvent := range counterEvents {
+		ExpectWithOffset(1, counterEvent.ReceivedAt).ToNot(BeZero())
+		copied := *counterEvent
+		copied.ReceivedAt = 0
+		received = append(received, &copied)
+	}
+
+	return received
+}
+
+func receivedHttpStartStops(httpStartStops HttpStartStops) HttpStartStops {
+	var received HttpStartStops
+	for _, httpStartStop := range httpStartStops {
+		ExpectWithOffset(1, httpStartStop.ReceivedAt).ToNot(BeZero())
+		copied := *httpStartStop
+		copied.ReceivedAt = 0
+		received = append(received, &copied)
+	}
+
+	return received
+}
+
+func receivedValueMetrics(valueMetrics ValueMetrics) ValueMetrics {
+	var received ValueMetrics
+	for _, valueMetric := range valueMetrics {
+		ExpectWithOffset(1, valueMetric.ReceivedAt).ToNot(BeZero())
+		copied := *valueMetric
+		copied.ReceivedAt = 0
+		received = append(received, &copied)
+	}
+
+	return received
+}
@@ -0,0 +1,279 @@
+package naming
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+
+	"github.com/bosh-prometheus/firehose_exporter/mappings"
+	"github.com/bosh-prometheus/firehose_exporter/utils"
+)
+
+const (
+	PlaceholderNamespace = "{namespace}"
+	PlaceholderSubsystem = "{subsystem}"
+	PlaceholderOrigin    = "{origin}"
+	PlaceholderName      = "{name}"
+)
+
+var placeholderRE = regexp.MustCompile(`\{[^{}]*\}`)
+
+type Config struct {
+	NamingTemplates []Template `yaml:"naming_templates"`
+}
+
+// Template names the counter events and value metrics matching its origin
+// and name regular expressions. The `{namespace}`, `{subsystem}`, `{origin}`
+// and `{name}` placeholders are replaced by the exporter namespace, the event
+// type subsystem and the normalized origin and name of the metric.
+type Template struct {
+	EventType string `yaml:"event_type"`
+	Origin    string `yaml:"origin"`
+	Name      string `yaml:"name"`
+	Template  string `yaml:"template"`
+
+	originRegex   *regexp.Regexp
+	nameRegex     *regexp.Regexp
+	literalOrigin string
+}
+
+type Namer struct {
+	templates []*Template
+}
+
+func LoadConfig(path string) (*Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(content, config); err != nil {
+		return nil, fmt.Errorf("Error parsing naming config `%s`: %v", path, err)
+	}
+
+	return config, nil
+}
+
+// NewNamer validates the templates and checks that no two of them, nor a
+// template and the default naming, can name metrics from different origins
+// alike. The namespace is the one the metrics are named with.
+func NewNamer(namespace string, templates []Template) (*Namer, error) {
+	namer := &Namer{}
+	schemes := defaultSchemes(namespace)
+
+	for i := range templates {
+		template := templates[i]
+		if err := template.compile(); err != nil {
+			return nil, fmt.Errorf("Naming template %d: %v", i, err)
+		}
+		if !strings.Contains(template.Template, PlaceholderOrigin) && template.literalOrigin == "" {
+			return nil, fmt.Errorf("Naming template %d: template `%s` must include `%s` unless its origin is a literal", i, template.Template, PlaceholderOrigin)
+		}
+
+		for _, scheme := range template.schemes(namespace, i) {
+			for _, previous := range schemes {
+				if scheme.conflicts(previous) {
+					return nil, fmt.Errorf("Naming template %d: template `%s` conflicts with %s, include `%s` or match the same origin", i, template.Template, previous.description(templates), PlaceholderOrigin)
+				}
+			}
+			schemes = append(schemes, scheme)
+		}
+
+		namer.templates = append(namer.templates, &template)
+	}
+
+	return namer, nil
+}
+
+func (t *Template) compile() error {
+	switch t.EventType {
+	case "", mappings.EventTypeCounterEvent, mappings.EventTypeValueMetric:
+	default:
+		return fmt.Errorf("event type `%s` is not supported", t.EventType)
+	}
+
+	if !strings.Contains(t.Template, PlaceholderName) {
+		return fmt.Errorf("template `%s` must include `%s`", t.Template, PlaceholderName)
+	}
+
+	for _, placeholder := range placeholderRE.FindAllString(t.Template, -1) {
+		switch placeholder {
+		case PlaceholderNamespace, PlaceholderSubsystem, PlaceholderOrigin, PlaceholderName:
+		default:
+			return fmt.Errorf("template `%s` placeholder `%s` is not supported", t.Template, placeholder)
+		}
+	}
+
+	if sample := t.render("namespace", "subsystem", "origin", "name"); !model.IsValidMetricName(model.LabelValue(sample)) {
+		return fmt.Errorf("template `%s` does not render a valid metric name", t.Template)
+	}
+
+	var err error
+	if t.originRegex, err = compileAnchored(t.Origin); err != nil {
+		return fmt.Errorf("invalid origin regex: %v", err)
+	}
+	if t.nameRegex, err = compileAnchored(t.Name); err != nil {
+		return fmt.Errorf("invalid name regex: %v", err)
+	}
+
+	t.literalOrigin = ""
+	if t.originRegex != nil {
+		if origin, complete := regexp.MustCompile(t.Origin).LiteralPrefix(); complete && origin != "" {
+			t.literalOrigin = origin
+		}
+	}
+
+	return nil
+}
+
+// eventTypeSubsystems are the subsystems the metrics of each event type are
+// named with.
+var eventTypeSubsystems = map[string]string{
+	mappings.EventTypeCounterEvent: "counter_event",
+	mappings.EventTypeValueMetric:  "value_metric",
+}
+
+// scheme is the skeleton of the names a template, or the default naming,
+// gives to the metrics of an event type: the literal prefix and suffix
+// around the origin and name placeholders. Two schemes can name metrics
+// alike when their prefixes, and their suffixes, overlap.
+type scheme struct {
+	template      int
+	prefix        string
+	suffix        string
+	literalOrigin string
+	anyOrigin     bool
+}
+
+// defaultSchemes returns the schemes of the default naming, which renders
+// `<namespace>_<subsystem>_<origin>_<name>` for any origin.
+func defaultSchemes(namespace string) []scheme {
+	var schemes []scheme
+	for _, eventType := range []string{mappings.EventTypeCounterEvent, mappings.EventTypeValueMetric} {
+		schemes = append(schemes, scheme{
+			template:  -1,
+			prefix:    strings.TrimSuffix(prometheus.BuildFQName(namespace, eventTypeSubsystems[eventType], PlaceholderOrigin), PlaceholderOrigin),
+			anyOrigin: true,
+		})
+	}
+
+	return schemes
+}
+
+// schemes returns the schemes of the template for each event type it
+// applies to. A literal origin is rendered in place.
+func (t *Template) schemes(namespace string, index int) []scheme {
+	eventTypes := []string{mappings.EventTypeCounterEvent, mappings.EventTypeValueMetric}
+	if t.EventType != "" {
+		eventTypes = []string{t.EventType}
+	}
+
+	origin := PlaceholderOrigin
+	if t.literalOrigin != "" {
+		origin = utils.NormalizeName(t.literalOrigin)
+	}
+
+	var schemes []scheme
+	for _, eventType := range eventTypes {
+		skeleton := t.render(namespace, eventTypeSubsystems[eventType], origin, PlaceholderName)
+		first := len(skeleton)
+		last := 0
+		for _, placeholder := range []string{PlaceholderOrigin, PlaceholderName} {
+			if i := strings.Index(skeleton, placeholder); i >= 0 && i < first {
+				first = i
+			}
+			if i := strings.LastIndex(skeleton, placeholder); i >= 0 && i+len(placeholder) > last {
+				last = i + len(placeholder)
+			}
+		}
+
+		schemes = append(schemes, scheme{
+			template:      index,
+			prefix:        skeleton[:first],
+			suffix:        skeleton[last:],
+			literalOrigin: t.literalOrigin,
+			anyOrigin:     t.literalOrigin == "",
+		})
+	}
+
+	return schemes
+}
+
+// conflicts reports whether two schemes can name metrics from different
+// origins alike. Schemes including any origin are as ambiguous as the
+// default naming, and are not checked against each other.
+func (s scheme) conflicts(other scheme) bool {
+	if s.anyOrigin && other.anyOrigin {
+		return false
+	}
+	if s.literalOrigin != "" && s.literalOrigin == other.literalOrigin {
+		return false
+	}
+
+	// A template rendering the default name of its own origin only overlaps
+	// with the origins extending it, as the default naming does.
+	for _, pair := range [][2]scheme{{s, other}, {other, s}} {
+		if pair[1].template == -1 && pair[0].literalOrigin != "" && strings.HasPrefix(pair[0].prefix, pair[1].prefix+utils.NormalizeName(pair[0].literalOrigin)+"_") {
+			return false
+		}
+	}
+
+	return overlap(s.prefix, other.prefix, strings.HasPrefix) && overlap(s.suffix, other.suffix, strings.HasSuffix)
+}
+
+func overlap(a string, b string, has func(string, string) bool) bool {
+	return has(a, b) || has(b, a)
+}
+
+func (s scheme) description(templates []Template) string {
+	if s.template == -1 {
+		return "the default naming"
+	}
+
+	return fmt.Sprintf("naming template %d `%s`", s.template, templates[s.template].Template)
+}
+
+func (t *Template) render(namespace string, subsystem string, origin string, name string) string {
+	return strings.NewReplacer(
+		PlaceholderNamespace, namespace,
+		PlaceholderSubsystem, subsystem,
+		PlaceholderOrigin, origin,
+		PlaceholderName, name,
+	).Replace(t.Template)
+}
+
+func compileAnchored(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+// Name returns the metric name of an event, rendered by the first matching
+// template. Events matching no template, or without namer, are named
+// `<namespace>_<subsystem>_<origin>_<name>`.
+func (n *Namer) Name(namespace string, subsystem string, eventType string, origin string, name string) string {
+	if n != nil {
+		for _, template := range n.templates {
+			if template.EventType != "" && template.EventType != eventType {
+				continue
+			}
+			if template.originRegex != nil && !template.originRegex.MatchString(origin) {
+				continue
+			}
+			if template.nameRegex != nil && !template.nameRegex.MatchString(name) {
+				continue
+			}
+			return template.render(namespace, subsystem, utils.NormalizeName(origin), utils.NormalizeName(name))
+		}
+	}
+
+	return prometheus.BuildFQName(namespace, subsystem, utils.NormalizeName(origin)+"_"+utils.NormalizeName(name))
+}
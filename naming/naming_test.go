@@ -0,0 +1,196 @@
+package naming_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/firehose_exporter/naming"
+)
+
+var _ = Describe("Naming", func() {
+	Describe("LoadConfig", func() {
+		var (
+			configDir  string
+			configPath string
+		)
+
+		BeforeEach(func() {
+			var err error
+			configDir, err = ioutil.TempDir("", "naming")
+			Expect(err).ToNot(HaveOccurred())
+			configPath = filepath.Join(configDir, "naming.yml")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(configDir)
+		})
+
+		It("loads the naming templates", func() {
+			Expect(ioutil.WriteFile(configPath, []byte(`
+naming_templates:
+- event_type: ValueMetric
+  origin: gorouter
+  name: total_.*
+  template: cf_router_{name}
+`), 0644)).To(Succeed())
+
+			config, err := LoadConfig(configPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.NamingTemplates).To(HaveLen(1))
+			Expect(config.NamingTemplates[0].EventType).To(Equal("ValueMetric"))
+			Expect(config.NamingTemplates[0].Origin).To(Equal("gorouter"))
+			Expect(config.NamingTemplates[0].Name).To(Equal("total_.*"))
+			Expect(config.NamingTemplates[0].Template).To(Equal("cf_router_{name}"))
+		})
+
+		It("returns an error on unknown fields", func() {
+			Expect(ioutil.WriteFile(configPath, []byte("naming_templates:\n- prefix: foo\n"), 0644)).To(Succeed())
+
+			_, err := LoadConfig(configPath)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error when the file does not exist", func() {
+			_, err := LoadConfig(filepath.Join(configDir, "missing.yml"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("NewNamer", func() {
+		It("returns an error on an unsupported event type", func() {
+			_, err := NewNamer("firehose", []Template{{EventType: "ContainerMetric", Template: "cf_{name}"}})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error when the template does not include the name", func() {
+			_, err := NewNamer("firehose", []Template{{Origin: "gorouter", Template: "cf_router_requests"}})
+			Expect(err).To(MatchError(ContainSubstring("must include `{name}`")))
+		})
+
+		It("returns an error on an unsupported placeholder", func() {
+			_, err := NewNamer("firehose", []Template{{Template: "cf_{job}_{name}"}})
+			Expect(err).To(MatchError(ContainSubstring("placeholder `{job}`")))
+		})
+
+		It("returns an error when the template does not render a valid metric name", func() {
+			_, err := NewNamer("firehose", []Template{{Template: "cf-router-{name}"}})
+			Expect(err).To(MatchError(ContainSubstring("valid metric name")))
+		})
+
+		It("returns an error on an invalid regex", func() {
+			_, err := NewNamer("firehose", []Template{{Origin: "(", Template: "cf_{name}"}})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error when templates can name metrics from different origins alike", func() {
+			_, err := NewNamer("firehose", []Template{
+				{Origin: "gorouter", Template: "cf_{name}"},
+				{Origin: "cc", Template: "cf_{name}"},
+			})
+			Expect(err).To(MatchError(ContainSubstring("conflicts with naming template 0")))
+		})
+
+		It("returns an error when templates without subsystem apply to both event types", func() {
+			_, err := NewNamer("firehose", []Template{
+				{EventType: "ValueMetric", Origin: "gorouter", Template: "cf_{name}"},
+				{Origin: "cc", Template: "cf_{name}"},
+			})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error when the literal parts of templates overlap", func() {
+			_, err := NewNamer("firehose", []Template{
+				{Origin: "a", Template: "cf_{name}"},
+				{Origin: "b", Template: "cf_x_{name}"},
+			})
+			Expect(err).To(MatchError(ContainSubstring("conflicts with naming template 0")))
+		})
+
+		It("returns an error when a template overlaps the default naming", func() {
+			_, err := NewNamer("firehose", []Template{
+				{Origin: "gorouter", Template: "{namespace}_{subsystem}_{name}"},
+			})
+			Expect(err).To(MatchError(ContainSubstring("conflicts with the default naming")))
+		})
+
+		It("returns an error when a template without the origin does not match a literal origin", func() {
+			_, err := NewNamer("firehose", []Template{{Origin: "go.*", Template: "cf_router_{name}"}})
+			Expect(err).To(MatchError(ContainSubstring("unless its origin is a literal")))
+		})
+
+		It("accepts a template rendering the default name of its origin", func() {
+			_, err := NewNamer("firehose", []Template{
+				{Origin: "gorouter", Name: "total_.*", Template: "{namespace}_{subsystem}_{origin}_{name}"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("accepts templates whose literal origins render apart", func() {
+			_, err := NewNamer("firehose", []Template{
+				{Origin: "gorouter", Template: "cf_router_{name}"},
+				{Origin: "uaa", Template: "cf_{origin}_{name}"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("accepts alike templates for different event types with the subsystem", func() {
+			_, err := NewNamer("firehose", []Template{
+				{EventType: "ValueMetric", Origin: "gorouter", Template: "cf_{subsystem}_{name}"},
+				{EventType: "CounterEvent", Origin: "cc", Template: "cf_{subsystem}_{name}"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("accepts alike templates including the origin", func() {
+			_, err := NewNamer("firehose", []Template{
+				{Origin: "gorouter", Template: "cf_{origin}_{name}"},
+				{Origin: "cc", Template: "cf_{origin}_{name}"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("accepts alike templates for the same origin", func() {
+			_, err := NewNamer("firehose", []Template{
+				{Origin: "gorouter", Name: "total_.*", Template: "cf_router_{name}"},
+				{Origin: "gorouter", Name: "latency", Template: "cf_router_{name}"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Describe("Name", func() {
+		var namer *Namer
+
+		BeforeEach(func() {
+			var err error
+			namer, err = NewNamer("firehose", []Template{
+				{EventType: "ValueMetric", Origin: "gorouter", Name: "total_.*", Template: "cf_router_{name}"},
+				{Origin: "go.*", Template: "{namespace}_{origin}_{subsystem}_{name}"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("renders the first matching template with the normalized origin and name", func() {
+			Expect(namer.Name("firehose", "value_metric", "ValueMetric", "gorouter", "total_requests")).To(Equal("cf_router_total_requests"))
+			Expect(namer.Name("firehose", "counter_event", "CounterEvent", "gorouter", "total_requests")).To(Equal("firehose_gorouter_counter_event_total_requests"))
+			Expect(namer.Name("firehose", "value_metric", "ValueMetric", "gorouter", "Latency.Avg")).To(Equal("firehose_gorouter_value_metric_latency_avg"))
+		})
+
+		It("anchors the regular expressions", func() {
+			Expect(namer.Name("firehose", "value_metric", "ValueMetric", "cc.gorouter", "total_requests")).To(Equal("firehose_value_metric_cc_gorouter_total_requests"))
+		})
+
+		It("returns the default name when no template matches", func() {
+			Expect(namer.Name("firehose", "value_metric", "ValueMetric", "cc", "requests.completed")).To(Equal("firehose_value_metric_cc_requests_completed"))
+		})
+
+		It("returns the default name with a nil namer", func() {
+			namer = nil
+			Expect(namer.Name("firehose", "value_metric", "ValueMetric", "gorouter", "total_requests")).To(Equal("firehose_value_metric_gorouter_total_requests"))
+		})
+	})
+})
@@ -0,0 +1,152 @@
+package relabel
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Gatherer relabels the series gathered by another gatherer. Series are
+// regrouped by their relabeled name, and series conflicting with an already
+// gathered one (same name and labels, or same name with a different type) are
+// discarded and reported as errors.
+type Gatherer struct {
+	gatherer  prometheus.Gatherer
+	relabeler *Relabeler
+}
+
+func NewGatherer(gatherer prometheus.Gatherer, relabeler *Relabeler) *Gatherer {
+	return &Gatherer{
+		gatherer:  gatherer,
+		relabeler: relabeler,
+	}
+}
+
+func (g *Gatherer) Gather() ([]*dto.MetricFamily, error) {
+	metricFamilies, err := g.gatherer.Gather()
+	errs := prometheus.MultiError{}
+	if err != nil {
+		if multiErr, ok := err.(prometheus.MultiError); ok {
+			errs = append(errs, multiErr...)
+		} else {
+			errs = append(errs, err)
+		}
+	}
+
+	relabeledFamilies := map[string]*dto.MetricFamily{}
+	seenSeries := map[string]struct{}{}
+
+	for _, metricFamily := range metricFamilies {
+		for _, metric := range metricFamily.Metric {
+			labels := make(map[string]string, len(metric.Label)+1)
+			for _, labelPair := range metric.Label {
+				labels[labelPair.GetName()] = labelPair.GetValue()
+			}
+			labels[MetricNameLabel] = metricFamily.GetName()
+
+			labels = g.relabeler.Process(labels)
+			if labels == nil {
+				continue
+			}
+
+			name := labels[MetricNameLabel]
+			if !model.IsValidMetricName(model.LabelValue(name)) {
+				errs = append(errs, fmt.Errorf("series of metric %q relabeled with invalid metric name %q", metricFamily.GetName(), name))
+				continue
+			}
+			delete(labels, MetricNameLabel)
+
+			relabeledFamily, ok := relabeledFamilies[name]
+			if !ok {
+				relabeledFamily = &dto.MetricFamily{
+					Name: proto.String(name),
+					Help: metricFamily.Help,
+					Type: metricFamily.Type,
+				}
+				relabeledFamilies[name] = relabeledFamily
+			}
+			if relabeledFamily.GetType() != metricFamily.GetType() {
+				errs = append(errs, fmt.Errorf("series of metric %q relabeled to metric %q of a different type", metricFamily.GetName(), name))
+				continue
+			}
+
+			relabeledMetric := *metric
+			relabeledMetric.Label = labelPairs(labels)
+
+			seriesKey := seriesKey(name, relabeledMetric.Label)
+			if _, ok := seenSeries[seriesKey]; ok {
+				errs = append(errs, fmt.Errorf("series of metric %q relabeled to duplicated series %s", metricFamily.GetName(), seriesKey))
+				continue
+			}
+			seenSeries[seriesKey] = struct{}{}
+
+			relabeledFamily.Metric = append(relabeledFamily.Metric, &relabeledMetric)
+		}
+	}
+
+	result := make([]*dto.MetricFamily, 0, len(relabeledFamilies))
+	for _, relabeledFamily := range relabeledFamilies {
+		if len(relabeledFamily.Metric) == 0 {
+			continue
+		}
+		sort.Sort(metricSorter(relabeledFamily.Metric))
+		result = append(result, relabeledFamily)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].GetName() < result[j].GetName() })
+
+	return result, errs.MaybeUnwrap()
+}
+
+func labelPairs(labels map[string]string) []*dto.LabelPair {
+	pairs := make([]*dto.LabelPair, 0, len(labels))
+	for _, name := range sortedNames(labels) {
+		pairs = append(pairs, &dto.LabelPair{
+			Name:  proto.String(name),
+			Value: proto.String(labels[name]),
+		})
+	}
+
+	return pairs
+}
+
+func seriesKey(name string, labelPairs []*dto.LabelPair) string {
+	parts := make([]string, 0, len(labelPairs))
+	for _, labelPair := range labelPairs {
+		parts = append(parts, fmt.Sprintf("%s=%q", labelPair.GetName(), labelPair.GetValue()))
+	}
+
+	return name + "{" + strings.Join(parts, ",") + "}"
+}
+
+// metricSorter sorts metrics by their (sorted) label values.
+type metricSorter []*dto.Metric
+
+func (s metricSorter) Len() int {
+	return len(s)
+}
+
+func (s metricSorter) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s metricSorter) Less(i, j int) bool {
+	if len(s[i].Label) != len(s[j].Label) {
+		return len(s[i].Label) < len(s[j].Label)
+	}
+	for n, labelPair := range s[i].Label {
+		if labelPair.GetName() != s[j].Label[n].GetName() {
+			return labelPair.GetName() < s[j].Label[n].GetName()
+		}
+		if labelPair.GetValue() != s[j].Label[n].GetValue() {
+			return labelPair.GetValue() < s[j].Label[n].GetValue()
+		}
+	}
+
+	return false
+}
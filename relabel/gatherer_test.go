@@ -0,0 +1,184 @@
+package relabel_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	dto "github.com/prometheus/client_model/go"
+
+	. "github.com/bosh-prometheus/firehose_exporter/relabel"
+)
+
+var _ = Describe("Gatherer", func() {
+	var (
+		registry  *prometheus.Registry
+		relabeler *Relabeler
+		gatherer  *Gatherer
+	)
+
+	BeforeEach(func() {
+		registry = prometheus.NewRegistry()
+
+		requests := prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "firehose_requests_total",
+				Help:        "Fake requests.",
+				ConstLabels: prometheus.Labels{"environment": "test_environment"},
+			},
+			[]string{"origin", "bosh_job_id"},
+		)
+		requests.WithLabelValues("gorouter", "0").Add(1)
+		requests.WithLabelValues("gorouter", "1").Add(2)
+		requests.WithLabelValues("doppler", "0").Add(3)
+		registry.MustRegister(requests)
+
+		latency := prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "firehose_latency",
+				Help: "Fake latency.",
+			},
+			[]string{"origin"},
+		)
+		latency.WithLabelValues("gorouter").Set(10)
+		registry.MustRegister(latency)
+	})
+
+	JustBeforeEach(func() {
+		gatherer = NewGatherer(registry, relabeler)
+	})
+
+	newRelabeler := func(config string) *Relabeler {
+		relabelConfig, err := ParseConfig([]byte(config))
+		Expect(err).ToNot(HaveOccurred())
+		relabeler, err := NewRelabeler(relabelConfig.RelabelConfigs)
+		Expect(err).ToNot(HaveOccurred())
+		return relabeler
+	}
+
+	labels := func(metric *dto.Metric) map[string]string {
+		result := map[string]string{}
+		for _, labelPair := range metric.Label {
+			result[labelPair.GetName()] = labelPair.GetValue()
+		}
+		return result
+	}
+
+	Context("without rules", func() {
+		BeforeEach(func() {
+			relabeler = nil
+		})
+
+		It("returns the gathered metric families", func() {
+			metricFamilies, err := gatherer.Gather()
+			Expect(err).ToNot(HaveOccurred())
+
+			expectedFamilies, err := registry.Gather()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metricFamilies).To(Equal(expectedFamilies))
+		})
+	})
+
+	Context("when series are renamed", func() {
+		BeforeEach(func() {
+			relabeler = newRelabeler(`
+relabel_configs:
+- source_labels: [__name__, origin]
+  regex: firehose_requests_total;(.*)
+  target_label: __name__
+  replacement: ${1}_requests_total
+`)
+		})
+
+		It("regroups the series by metric name", func() {
+			metricFamilies, err := gatherer.Gather()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metricFamilies).To(HaveLen(3))
+
+			Expect(metricFamilies[0].GetName()).To(Equal("doppler_requests_total"))
+			Expect(metricFamilies[0].GetType()).To(Equal(dto.MetricType_COUNTER))
+			Expect(metricFamilies[0].GetHelp()).To(Equal("Fake requests."))
+			Expect(metricFamilies[0].Metric).To(HaveLen(1))
+
+			Expect(metricFamilies[1].GetName()).To(Equal("firehose_latency"))
+
+			Expect(metricFamilies[2].GetName()).To(Equal("gorouter_requests_total"))
+			Expect(metricFamilies[2].Metric).To(HaveLen(2))
+			Expect(labels(metricFamilies[2].Metric[0])).To(Equal(map[string]string{"environment": "test_environment", "origin": "gorouter", "bosh_job_id": "0"}))
+			Expect(metricFamilies[2].Metric[0].GetCounter().GetValue()).To(Equal(float64(1)))
+		})
+	})
+
+	Context("when series are dropped", func() {
+		BeforeEach(func() {
+			relabeler = newRelabeler(`
+relabel_configs:
+- source_labels: [origin]
+  regex: doppler
+  action: drop
+`)
+		})
+
+		It("does not return the dropped series", func() {
+			metricFamilies, err := gatherer.Gather()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metricFamilies).To(HaveLen(2))
+			Expect(metricFamilies[1].GetName()).To(Equal("firehose_requests_total"))
+			Expect(metricFamilies[1].Metric).To(HaveLen(2))
+		})
+	})
+
+	Context("when relabeled series collide", func() {
+		BeforeEach(func() {
+			relabeler = newRelabeler(`
+relabel_configs:
+- regex: bosh_job_id
+  action: labeldrop
+`)
+		})
+
+		It("keeps the first series and returns an error", func() {
+			metricFamilies, err := gatherer.Gather()
+			Expect(err).To(HaveOccurred())
+			Expect(metricFamilies).To(HaveLen(2))
+			Expect(metricFamilies[1].Metric).To(HaveLen(2))
+		})
+	})
+
+	Context("when series are renamed to a metric of a different type", func() {
+		BeforeEach(func() {
+			relabeler = newRelabeler(`
+relabel_configs:
+- target_label: __name__
+  replacement: firehose_metric
+`)
+		})
+
+		It("discards the conflicting series and returns an error", func() {
+			metricFamilies, err := gatherer.Gather()
+			Expect(err).To(HaveOccurred())
+			Expect(metricFamilies).To(HaveLen(1))
+			Expect(metricFamilies[0].GetType()).To(Equal(dto.MetricType_GAUGE))
+		})
+	})
+
+	Context("when series are renamed to an invalid metric name", func() {
+		BeforeEach(func() {
+			relabeler = newRelabeler(`
+relabel_configs:
+- source_labels: [origin]
+  regex: doppler
+  target_label: __name__
+  replacement: doppler-requests
+`)
+		})
+
+		It("discards the invalid series and returns an error", func() {
+			metricFamilies, err := gatherer.Gather()
+			Expect(err).To(HaveOccurred())
+			Expect(metricFamilies).To(HaveLen(2))
+			Expect(metricFamilies[1].Metric).To(HaveLen(2))
+		})
+	})
+})
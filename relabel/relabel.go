@@ -0,0 +1,239 @@
+package relabel
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+type Action string
+
+const (
+	Replace   Action = "replace"
+	Keep      Action = "keep"
+	Drop      Action = "drop"
+	HashMod   Action = "hashmod"
+	LabelMap  Action = "labelmap"
+	LabelDrop Action = "labeldrop"
+	LabelKeep Action = "labelkeep"
+)
+
+// MetricNameLabel is the pseudo-label holding the metric name.
+const MetricNameLabel = model.MetricNameLabel
+
+// reservedLabelPrefix is the prefix of the temporary labels, removed once all
+// rules have been applied.
+const reservedLabelPrefix = "__"
+
+// DefaultRelabelConfig holds the values of the fields left unset in a rule,
+// as in Prometheus.
+var DefaultRelabelConfig = RelabelConfig{
+	Action:      Replace,
+	Separator:   ";",
+	Regex:       "(.*)",
+	Replacement: "$1",
+}
+
+type RelabelConfig struct {
+	SourceLabels []string `yaml:"source_labels,flow"`
+	Separator    string   `yaml:"separator"`
+	Regex        string   `yaml:"regex"`
+	Modulus      uint64   `yaml:"modulus"`
+	TargetLabel  string   `yaml:"target_label"`
+	Replacement  string   `yaml:"replacement"`
+	Action       Action   `yaml:"action"`
+
+	regex *regexp.Regexp
+}
+
+func (c *RelabelConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultRelabelConfig
+	type plain RelabelConfig
+	return unmarshal((*plain)(c))
+}
+
+type Config struct {
+	RelabelConfigs []RelabelConfig `yaml:"relabel_configs"`
+}
+
+// Relabeler applies relabel rules, in order, to the label sets of series.
+type Relabeler struct {
+	configs []RelabelConfig
+}
+
+func LoadConfig(path string) (*Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := ParseConfig(content)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing relabel config `%s`: %v", path, err)
+	}
+
+	return config, nil
+}
+
+func ParseConfig(content []byte) (*Config, error) {
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(content, config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func NewRelabeler(configs []RelabelConfig) (*Relabeler, error) {
+	relabeler := &Relabeler{}
+
+	for i, config := range configs {
+		if err := config.compile(); err != nil {
+			return nil, fmt.Errorf("Relabel config %d: %v", i, err)
+		}
+		relabeler.configs = append(relabeler.configs, config)
+	}
+
+	return relabeler, nil
+}
+
+func (c *RelabelConfig) compile() error {
+	switch c.Action {
+	case Replace, HashMod:
+		if c.TargetLabel == "" {
+			return fmt.Errorf("`target_label` is required for action `%s`", c.Action)
+		}
+	case Keep, Drop:
+	case LabelMap, LabelDrop, LabelKeep:
+		if len(c.SourceLabels) > 0 || c.TargetLabel != "" {
+			return fmt.Errorf("`source_labels` and `target_label` are not supported for action `%s`", c.Action)
+		}
+	default:
+		return fmt.Errorf("action `%s` is not supported", c.Action)
+	}
+
+	if c.Action == HashMod && c.Modulus == 0 {
+		return fmt.Errorf("`modulus` is required for action `%s`", c.Action)
+	}
+	if c.Action == HashMod && !model.LabelName(c.TargetLabel).IsValid() {
+		return fmt.Errorf("`target_label` `%s` is not a valid label name", c.TargetLabel)
+	}
+
+	regex, err := regexp.Compile("^(?:" + c.Regex + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid regex: %v", err)
+	}
+	c.regex = regex
+
+	return nil
+}
+
+// Process applies the relabel rules to a label set, the metric name being in
+// the `__name__` label. It returns nil if the series is dropped. Labels left
+// with an empty value and temporary `__` prefixed labels (other than
+// `__name__`) are removed from the result.
+func (r *Relabeler) Process(labels map[string]string) map[string]string {
+	if r == nil {
+		return labels
+	}
+
+	result := make(map[string]string, len(labels))
+	for name, value := range labels {
+		result[name] = value
+	}
+
+	for _, config := range r.configs {
+		if !config.process(result) {
+			return nil
+		}
+	}
+
+	for name, value := range result {
+		if value == "" || (name != MetricNameLabel && strings.HasPrefix(name, reservedLabelPrefix)) {
+			delete(result, name)
+		}
+	}
+
+	return result
+}
+
+func (c *RelabelConfig) process(labels map[string]string) bool {
+	values := make([]string, len(c.SourceLabels))
+	for i, name := range c.SourceLabels {
+		values[i] = labels[name]
+	}
+	value := strings.Join(values, c.Separator)
+
+	switch c.Action {
+	case Keep:
+		return c.regex.MatchString(value)
+	case Drop:
+		return !c.regex.MatchString(value)
+	case Replace:
+		indexes := c.regex.FindStringSubmatchIndex(value)
+		if indexes == nil {
+			return true
+		}
+		target := string(c.regex.ExpandString(nil, c.TargetLabel, value, indexes))
+		if !model.LabelName(target).IsValid() {
+			return true
+		}
+		replacement := string(c.regex.ExpandString(nil, c.Replacement, value, indexes))
+		if replacement == "" {
+			delete(labels, target)
+		} else {
+			labels[target] = replacement
+		}
+	case HashMod:
+		labels[c.TargetLabel] = fmt.Sprintf("%d", sum64(md5.Sum([]byte(value)))%c.Modulus)
+	case LabelMap:
+		for _, name := range sortedNames(labels) {
+			if c.regex.MatchString(name) {
+				labels[c.regex.ReplaceAllString(name, c.Replacement)] = labels[name]
+			}
+		}
+	case LabelDrop:
+		for _, name := range sortedNames(labels) {
+			if c.regex.MatchString(name) {
+				delete(labels, name)
+			}
+		}
+	case LabelKeep:
+		for _, name := range sortedNames(labels) {
+			if !c.regex.MatchString(name) {
+				delete(labels, name)
+			}
+		}
+	}
+
+	return true
+}
+
+// sum64 folds a md5 hash the way Prometheus does, so that hashmod shards
+// series the same way.
+func sum64(hash [md5.Size]byte) uint64 {
+	var s uint64
+
+	for i, b := range hash {
+		shift := uint64((md5.Size - 1 - i) * 8)
+		s |= uint64(b) << shift
+	}
+
+	return s
+}
+
+func sortedNames(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
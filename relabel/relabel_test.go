@@ -0,0 +1,258 @@
+package relabel_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/firehose_exporter/relabel"
+)
+
+var _ = Describe("Relabel", func() {
+	var (
+		series = map[string]string{
+			"__name__":        "firehose_value_metric_gorouter_latency",
+			"environment":     "test_environment",
+			"origin":          "gorouter",
+			"bosh_deployment": "cf",
+			"bosh_job_name":   "router",
+			"bosh_job_id":     "0",
+			"unit":            "ms",
+		}
+	)
+
+	newRelabeler := func(config string) *Relabeler {
+		relabelConfig, err := ParseConfig([]byte(config))
+		Expect(err).ToNot(HaveOccurred())
+		relabeler, err := NewRelabeler(relabelConfig.RelabelConfigs)
+		Expect(err).ToNot(HaveOccurred())
+		return relabeler
+	}
+
+	Describe("LoadConfig", func() {
+		var (
+			configDir  string
+			configPath string
+		)
+
+		BeforeEach(func() {
+			var err error
+			configDir, err = ioutil.TempDir("", "relabel")
+			Expect(err).ToNot(HaveOccurred())
+			configPath = filepath.Join(configDir, "relabel.yml")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(configDir)
+		})
+
+		It("loads the rules with Prometheus defaults", func() {
+			Expect(ioutil.WriteFile(configPath, []byte(`
+relabel_configs:
+- source_labels: [bosh_deployment]
+  target_label: deployment
+- action: labeldrop
+  regex: unit
+`), 0644)).To(Succeed())
+
+			config, err := LoadConfig(configPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.RelabelConfigs).To(HaveLen(2))
+			Expect(config.RelabelConfigs[0].Action).To(Equal(Replace))
+			Expect(config.RelabelConfigs[0].SourceLabels).To(Equal([]string{"bosh_deployment"}))
+			Expect(config.RelabelConfigs[0].Separator).To(Equal(";"))
+			Expect(config.RelabelConfigs[0].Regex).To(Equal("(.*)"))
+			Expect(config.RelabelConfigs[0].Replacement).To(Equal("$1"))
+			Expect(config.RelabelConfigs[1].Action).To(Equal(LabelDrop))
+			Expect(config.RelabelConfigs[1].Regex).To(Equal("unit"))
+		})
+
+		It("returns an error on unknown fields", func() {
+			Expect(ioutil.WriteFile(configPath, []byte("relabel_configs:\n- source: foo\n"), 0644)).To(Succeed())
+
+			_, err := LoadConfig(configPath)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error when the file does not exist", func() {
+			_, err := LoadConfig(filepath.Join(configDir, "missing.yml"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("NewRelabeler", func() {
+		invalidConfigs := map[string]string{
+			"an unknown action":               "- action: rename\n",
+			"an invalid regex":                "- action: drop\n  regex: (\n",
+			"a replace without target label":  "- action: replace\n  source_labels: [origin]\n",
+			"a hashmod without modulus":       "- action: hashmod\n  source_labels: [origin]\n  target_label: shard\n",
+			"a hashmod with an invalid label": "- action: hashmod\n  source_labels: [origin]\n  target_label: 0shard\n  modulus: 2\n",
+			"a labelmap with source labels":   "- action: labelmap\n  source_labels: [origin]\n",
+			"a labeldrop with a target label": "- action: labeldrop\n  target_label: origin\n",
+		}
+
+		for description, config := range invalidConfigs {
+			config := config
+			It("returns an error on "+description, func() {
+				relabelConfig, err := ParseConfig([]byte("relabel_configs:\n" + config))
+				Expect(err).ToNot(HaveOccurred())
+				_, err = NewRelabeler(relabelConfig.RelabelConfigs)
+				Expect(err).To(HaveOccurred())
+			})
+		}
+	})
+
+	Describe("Process", func() {
+		It("does not modify the series without rules", func() {
+			Expect(newRelabeler("").Process(series)).To(Equal(series))
+		})
+
+		It("does not modify the series with a nil relabeler", func() {
+			var relabeler *Relabeler
+			Expect(relabeler.Process(series)).To(Equal(series))
+		})
+
+		It("does not modify the input series", func() {
+			newRelabeler("relabel_configs:\n- action: labeldrop\n  regex: unit\n").Process(series)
+			Expect(series).To(HaveKeyWithValue("unit", "ms"))
+		})
+
+		Context("replace", func() {
+			It("sets the target label from the source labels", func() {
+				relabeled := newRelabeler(`
+relabel_configs:
+- source_labels: [bosh_job_name, bosh_job_id]
+  separator: /
+  target_label: instance
+`).Process(series)
+				Expect(relabeled).To(HaveKeyWithValue("instance", "router/0"))
+			})
+
+			It("expands the regex capture groups", func() {
+				relabeled := newRelabeler(`
+relabel_configs:
+- source_labels: [__name__]
+  regex: firehose_value_metric_(.*)
+  target_label: __name__
+  replacement: cf_${1}_milliseconds
+`).Process(series)
+				Expect(relabeled).To(HaveKeyWithValue("__name__", "cf_gorouter_latency_milliseconds"))
+			})
+
+			It("does nothing when the regex does not match", func() {
+				relabeled := newRelabeler(`
+relabel_configs:
+- source_labels: [origin]
+  regex: doppler
+  target_label: origin
+  replacement: loggregator
+`).Process(series)
+				Expect(relabeled).To(Equal(series))
+			})
+
+			It("removes the target label on an empty replacement", func() {
+				relabeled := newRelabeler(`
+relabel_configs:
+- target_label: unit
+  replacement: ""
+`).Process(series)
+				Expect(relabeled).ToNot(HaveKey("unit"))
+			})
+		})
+
+		Context("keep", func() {
+			It("keeps the series matching the regex", func() {
+				relabeler := newRelabeler(`
+relabel_configs:
+- source_labels: [origin]
+  regex: gorouter|doppler
+  action: keep
+`)
+				Expect(relabeler.Process(series)).ToNot(BeNil())
+				Expect(relabeler.Process(map[string]string{"__name__": "foo", "origin": "uaa"})).To(BeNil())
+			})
+		})
+
+		Context("drop", func() {
+			It("drops the series matching the regex", func() {
+				relabeler := newRelabeler(`
+relabel_configs:
+- source_labels: [__name__]
+  regex: .*_latency
+  action: drop
+`)
+				Expect(relabeler.Process(series)).To(BeNil())
+				Expect(relabeler.Process(map[string]string{"__name__": "foo"})).ToNot(BeNil())
+			})
+		})
+
+		Context("hashmod", func() {
+			It("sets the target label to the hash modulus of the source labels", func() {
+				relabeled := newRelabeler(`
+relabel_configs:
+- source_labels: [bosh_job_name, bosh_job_id]
+  target_label: shard
+  modulus: 8
+  action: hashmod
+`).Process(series)
+				Expect(relabeled).To(HaveKeyWithValue("shard", "5"))
+			})
+		})
+
+		Context("labelmap", func() {
+			It("copies the labels matching the regex", func() {
+				relabeled := newRelabeler(`
+relabel_configs:
+- regex: bosh_(.*)
+  action: labelmap
+`).Process(series)
+				Expect(relabeled).To(HaveKeyWithValue("deployment", "cf"))
+				Expect(relabeled).To(HaveKeyWithValue("job_name", "router"))
+				Expect(relabeled).To(HaveKeyWithValue("bosh_deployment", "cf"))
+			})
+		})
+
+		Context("labeldrop", func() {
+			It("removes the labels matching the regex", func() {
+				relabeled := newRelabeler(`
+relabel_configs:
+- regex: bosh_job_.*
+  action: labeldrop
+`).Process(series)
+				Expect(relabeled).ToNot(HaveKey("bosh_job_name"))
+				Expect(relabeled).ToNot(HaveKey("bosh_job_id"))
+				Expect(relabeled).To(HaveKey("bosh_deployment"))
+			})
+		})
+
+		Context("labelkeep", func() {
+			It("removes the labels not matching the regex", func() {
+				relabeled := newRelabeler(`
+relabel_configs:
+- regex: __name__|environment|origin
+  action: labelkeep
+`).Process(series)
+				Expect(relabeled).To(Equal(map[string]string{
+					"__name__":    "firehose_value_metric_gorouter_latency",
+					"environment": "test_environment",
+					"origin":      "gorouter",
+				}))
+			})
+		})
+
+		It("removes temporary labels", func() {
+			relabeled := newRelabeler(`
+relabel_configs:
+- source_labels: [origin]
+  target_label: __tmp_origin
+- source_labels: [__tmp_origin]
+  target_label: source
+`).Process(series)
+			Expect(relabeled).To(HaveKeyWithValue("source", "gorouter"))
+			Expect(relabeled).ToNot(HaveKey("__tmp_origin"))
+		})
+	})
+})
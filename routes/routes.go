@@ -0,0 +1,155 @@
+package routes
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// OtherRoute is reported for the requests of an application once it reached
+// its maximum number of routes.
+const OtherRoute = "other"
+
+type Rule struct {
+	ApplicationID string `yaml:"application_id"`
+	Regex         string `yaml:"regex"`
+	Prefix        string `yaml:"prefix"`
+	Template      string `yaml:"template"`
+
+	regex *regexp.Regexp
+}
+
+type Config struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Matcher maps request paths to route templates. The first matching rule
+// wins, and paths not matching any rule have an empty route.
+type Matcher struct {
+	rules                   []Rule
+	maxRoutesPerApplication int
+
+	mutex             sync.Mutex
+	applicationRoutes map[string]map[string]struct{}
+}
+
+func LoadConfig(path string) (*Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(content, config); err != nil {
+		return nil, fmt.Errorf("Error parsing routes config `%s`: %v", path, err)
+	}
+
+	return config, nil
+}
+
+func NewMatcher(rules []Rule, maxRoutesPerApplication int) (*Matcher, error) {
+	compiledRules := make([]Rule, 0, len(rules))
+
+	for i, rule := range rules {
+		switch {
+		case rule.Regex != "" && rule.Prefix != "":
+			return nil, fmt.Errorf("Route rule %d must set either `regex` or `prefix`, not both", i)
+		case rule.Regex != "":
+			regex, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("Route rule %d has an invalid regex: %v", i, err)
+			}
+			if rule.Template == "" {
+				return nil, fmt.Errorf("Route rule %d must set a `template`", i)
+			}
+			rule.regex = regex
+		case rule.Prefix != "":
+			if rule.Template == "" {
+				rule.Template = rule.Prefix
+			}
+		default:
+			return nil, fmt.Errorf("Route rule %d must set a `regex` or a `prefix`", i)
+		}
+
+		compiledRules = append(compiledRules, rule)
+	}
+
+	return &Matcher{
+		rules:                   compiledRules,
+		maxRoutesPerApplication: maxRoutesPerApplication,
+		applicationRoutes:       map[string]map[string]struct{}{},
+	}, nil
+}
+
+// Route returns the route template for a request path of an application.
+// Regex templates can reference capture groups (e.g. `$1`). Once an
+// application reached the maximum number of routes, new routes are reported
+// as OtherRoute.
+func (m *Matcher) Route(applicationID string, path string) string {
+	if m == nil {
+		return ""
+	}
+
+	route, ok := m.match(applicationID, path)
+	if !ok || m.maxRoutesPerApplication <= 0 {
+		return route
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	routes, ok := m.applicationRoutes[applicationID]
+	if !ok {
+		routes = map[string]struct{}{}
+		m.applicationRoutes[applicationID] = routes
+	}
+
+	if _, ok := routes[route]; ok {
+		return route
+	}
+
+	if len(routes) >= m.maxRoutesPerApplication {
+		return OtherRoute
+	}
+	routes[route] = struct{}{}
+
+	return route
+}
+
+// Forget drops the routes tracked for an application.
+func (m *Matcher) Forget(applicationID string) {
+	if m == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.applicationRoutes, applicationID)
+}
+
+func (m *Matcher) match(applicationID string, path string) (string, bool) {
+	for _, rule := range m.rules {
+		if rule.ApplicationID != "" && rule.ApplicationID != applicationID {
+			continue
+		}
+
+		if rule.regex != nil {
+			match := rule.regex.FindStringSubmatchIndex(path)
+			if match == nil {
+				continue
+			}
+			return string(rule.regex.ExpandString(nil, rule.Template, path, match)), true
+		}
+
+		if strings.HasPrefix(path, rule.Prefix) {
+			return rule.Template, true
+		}
+	}
+
+	return "", false
+}
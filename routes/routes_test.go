@@ -0,0 +1,158 @@
+package routes_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/firehose_exporter/routes"
+)
+
+var _ = Describe("Routes", func() {
+	Describe("LoadConfig", func() {
+		var (
+			configDir  string
+			configPath string
+		)
+
+		BeforeEach(func() {
+			var err error
+			configDir, err = ioutil.TempDir("", "routes")
+			Expect(err).ToNot(HaveOccurred())
+			configPath = filepath.Join(configDir, "routes.yml")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(configDir)
+		})
+
+		It("loads the rules", func() {
+			Expect(ioutil.WriteFile(configPath, []byte(`
+rules:
+- regex: ^/api/orders/[0-9]+$
+  template: /api/orders/{id}
+- application_id: fake-application-id
+  prefix: /static/
+`), 0644)).To(Succeed())
+
+			config, err := LoadConfig(configPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.Rules).To(HaveLen(2))
+			Expect(config.Rules[0].Regex).To(Equal("^/api/orders/[0-9]+$"))
+			Expect(config.Rules[0].Template).To(Equal("/api/orders/{id}"))
+			Expect(config.Rules[1].ApplicationID).To(Equal("fake-application-id"))
+			Expect(config.Rules[1].Prefix).To(Equal("/static/"))
+		})
+
+		It("returns an error on unknown fields", func() {
+			Expect(ioutil.WriteFile(configPath, []byte("rules:\n- path: /foo\n"), 0644)).To(Succeed())
+
+			_, err := LoadConfig(configPath)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error when the file does not exist", func() {
+			_, err := LoadConfig(filepath.Join(configDir, "missing.yml"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("NewMatcher", func() {
+		It("returns an error when a rule sets both regex and prefix", func() {
+			_, err := NewMatcher([]Rule{{Regex: "^/foo", Prefix: "/foo", Template: "/foo"}}, 0)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error when a rule sets neither regex nor prefix", func() {
+			_, err := NewMatcher([]Rule{{Template: "/foo"}}, 0)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error when a regex is invalid", func() {
+			_, err := NewMatcher([]Rule{{Regex: "(", Template: "/foo"}}, 0)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error when a regex rule has no template", func() {
+			_, err := NewMatcher([]Rule{{Regex: "^/foo"}}, 0)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Route", func() {
+		var (
+			rules     []Rule
+			maxRoutes int
+			matcher   *Matcher
+		)
+
+		BeforeEach(func() {
+			rules = []Rule{
+				{Regex: `^/api/orders/[0-9]+$`, Template: "/api/orders/{id}"},
+				{Regex: `^/api/(v[0-9]+)/users/[^/]+$`, Template: "/api/$1/users/{id}"},
+				{ApplicationID: "fake-application-id-1", Prefix: "/static/", Template: "/static/*"},
+				{Prefix: "/assets/"},
+			}
+			maxRoutes = 0
+		})
+
+		JustBeforeEach(func() {
+			var err error
+			matcher, err = NewMatcher(rules, maxRoutes)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("maps paths matching a regex to its template", func() {
+			Expect(matcher.Route("fake-application-id-1", "/api/orders/123")).To(Equal("/api/orders/{id}"))
+		})
+
+		It("expands regex capture groups", func() {
+			Expect(matcher.Route("fake-application-id-1", "/api/v2/users/john")).To(Equal("/api/v2/users/{id}"))
+		})
+
+		It("maps paths matching a prefix to its template", func() {
+			Expect(matcher.Route("fake-application-id-1", "/static/app.js")).To(Equal("/static/*"))
+		})
+
+		It("defaults prefix templates to the prefix", func() {
+			Expect(matcher.Route("fake-application-id-1", "/assets/logo.png")).To(Equal("/assets/"))
+		})
+
+		It("only applies application rules to that application", func() {
+			Expect(matcher.Route("fake-application-id-2", "/static/app.js")).To(Equal(""))
+		})
+
+		It("returns an empty route when no rule matches", func() {
+			Expect(matcher.Route("fake-application-id-1", "/unknown")).To(Equal(""))
+		})
+
+		Context("when the maximum number of routes per application is reached", func() {
+			BeforeEach(func() {
+				maxRoutes = 1
+			})
+
+			It("reports new routes as other", func() {
+				Expect(matcher.Route("fake-application-id-1", "/api/orders/1")).To(Equal("/api/orders/{id}"))
+				Expect(matcher.Route("fake-application-id-1", "/api/orders/2")).To(Equal("/api/orders/{id}"))
+				Expect(matcher.Route("fake-application-id-1", "/assets/logo.png")).To(Equal(OtherRoute))
+				Expect(matcher.Route("fake-application-id-2", "/assets/logo.png")).To(Equal("/assets/"))
+			})
+
+			It("tracks routes again once the application is forgotten", func() {
+				Expect(matcher.Route("fake-application-id-1", "/api/orders/1")).To(Equal("/api/orders/{id}"))
+				matcher.Forget("fake-application-id-1")
+				Expect(matcher.Route("fake-application-id-1", "/assets/logo.png")).To(Equal("/assets/"))
+			})
+		})
+
+		Context("when there is no matcher", func() {
+			It("returns an empty route", func() {
+				var noMatcher *Matcher
+				Expect(noMatcher.Route("fake-application-id-1", "/api/orders/1")).To(Equal(""))
+			})
+		})
+	})
+})
@@ -0,0 +1,202 @@
+package tagpolicy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/bosh-prometheus/firehose_exporter/utils"
+)
+
+// Policy decides which envelope tags are reported as labels, and how. Tags
+// are filtered by name (before renaming), renamed or sanitized into label
+// names, and their values truncated. Tags with an empty name or value are
+// never reported.
+type Policy struct {
+	allowlist      map[string]bool
+	denylist       map[string]bool
+	renames        map[string]string
+	maxValueLength int
+
+	fixedTagNames   []string
+	fixedLabelNames []string
+}
+
+func NewPolicy(allowlist []string, denylist []string, renames map[string]string, maxValueLength int) (*Policy, error) {
+	if len(allowlist) > 0 && len(denylist) > 0 {
+		return nil, fmt.Errorf("Tags can be either allowed or denied, not both")
+	}
+
+	if maxValueLength < 0 {
+		return nil, fmt.Errorf("Tags max value length must not be negative")
+	}
+
+	for tag, label := range renames {
+		if !model.LabelName(label).IsValid() || strings.HasPrefix(label, model.ReservedLabelPrefix) {
+			return nil, fmt.Errorf("Tag `%s` can not be renamed to invalid label name `%s`", tag, label)
+		}
+	}
+
+	policy := &Policy{
+		allowlist:      toSet(allowlist),
+		denylist:       toSet(denylist),
+		renames:        renames,
+		maxValueLength: maxValueLength,
+	}
+
+	fixedTagNames := make([]string, 0, len(allowlist))
+	for tag := range policy.allowlist {
+		fixedTagNames = append(fixedTagNames, tag)
+	}
+	sort.Strings(fixedTagNames)
+
+	seenLabelNames := map[string]bool{}
+	for _, tag := range fixedTagNames {
+		label, ok := policy.labelName(tag)
+		if !ok {
+			return nil, fmt.Errorf("Tag `%s` does not map to a valid label name", tag)
+		}
+		if seenLabelNames[label] {
+			return nil, fmt.Errorf("Tag `%s` maps to the already used label name `%s`", tag, label)
+		}
+		seenLabelNames[label] = true
+
+		policy.fixedTagNames = append(policy.fixedTagNames, tag)
+		policy.fixedLabelNames = append(policy.fixedLabelNames, label)
+	}
+
+	return policy, nil
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+
+	return set
+}
+
+func (p *Policy) allowed(tag string) bool {
+	if p == nil {
+		return true
+	}
+	if len(p.allowlist) > 0 {
+		return p.allowlist[tag]
+	}
+
+	return !p.denylist[tag]
+}
+
+func (p *Policy) labelName(tag string) (string, bool) {
+	if p != nil {
+		if label, ok := p.renames[tag]; ok {
+			return label, true
+		}
+	}
+
+	label := utils.NormalizeName(tag)
+	if !model.LabelName(label).IsValid() || strings.HasPrefix(label, model.ReservedLabelPrefix) {
+		return "", false
+	}
+
+	return label, true
+}
+
+func (p *Policy) labelValue(value string) string {
+	if p == nil || p.maxValueLength == 0 || len(value) <= p.maxValueLength {
+		return value
+	}
+
+	value = value[:p.maxValueLength]
+	for len(value) > 0 {
+		if r, size := utf8.DecodeLastRuneInString(value); r != utf8.RuneError || size != 1 {
+			break
+		}
+		value = value[:len(value)-1]
+	}
+
+	return value
+}
+
+// CollisionPrefix is prepended to the label name of a tag colliding with a
+// built-in label, so that the built-in label wins and the tag is preserved.
+const CollisionPrefix = "tag_"
+
+// Labels returns the label names and values for the tags of an envelope,
+// sorted by label name. When several tags map to the same label name, the
+// first one in tag name order wins. Tags colliding with one of the builtin
+// labels are reported prefixed with CollisionPrefix, or dropped if the
+// prefixed name is taken too; collided reports whether it happened.
+func (p *Policy) Labels(tags map[string]string, builtinLabels []string) (labelNames []string, labelValues []string, collided bool) {
+	tagNames := make([]string, 0, len(tags))
+	for tag, value := range tags {
+		if tag != "" && value != "" && p.allowed(tag) {
+			tagNames = append(tagNames, tag)
+		}
+	}
+	sort.Strings(tagNames)
+
+	reserved := make(map[string]bool, len(builtinLabels))
+	for _, label := range builtinLabels {
+		reserved[label] = true
+	}
+
+	labels := make(map[string]string, len(tagNames))
+	labelNames = make([]string, 0, len(tagNames))
+	for _, tag := range tagNames {
+		label, ok := p.labelName(tag)
+		if !ok {
+			continue
+		}
+		if reserved[label] {
+			collided = true
+			label = CollisionPrefix + label
+			if reserved[label] {
+				continue
+			}
+		}
+		if _, ok := labels[label]; ok {
+			continue
+		}
+		labels[label] = p.labelValue(tags[tag])
+		labelNames = append(labelNames, label)
+	}
+	sort.Strings(labelNames)
+
+	labelValues = make([]string, len(labelNames))
+	for i, label := range labelNames {
+		labelValues[i] = labels[label]
+	}
+
+	return labelNames, labelValues, collided
+}
+
+// FixedLabelNames returns the label names of the allowed tags, sorted by tag
+// name, for the collectors with a fixed set of labels. Without an allowlist,
+// these collectors do not report any tag.
+func (p *Policy) FixedLabelNames() []string {
+	if p == nil {
+		return nil
+	}
+
+	return p.fixedLabelNames
+}
+
+// FixedLabelValues returns the label values of the allowed tags, in the
+// FixedLabelNames order. Missing tags have empty values.
+func (p *Policy) FixedLabelValues(tags map[string]string) []string {
+	if p == nil {
+		return nil
+	}
+
+	labelValues := make([]string, len(p.fixedTagNames))
+	for i, tag := range p.fixedTagNames {
+		labelValues[i] = p.labelValue(tags[tag])
+	}
+
+	return labelValues
+}
@@ -0,0 +1,13 @@
+package tagpolicy_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTagPolicy(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "TagPolicy Suite")
+}
@@ -0,0 +1,155 @@
+package tagpolicy_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/firehose_exporter/tagpolicy"
+)
+
+var _ = Describe("Policy", func() {
+	var (
+		tags = map[string]string{
+			"source_id":   "fake-source-id",
+			"instanceId":  "fake-instance-id",
+			"deployment":  "cf",
+			"empty":       "",
+			"":            "no-name",
+			"description": "a very long description",
+		}
+	)
+
+	Describe("NewPolicy", func() {
+		It("returns an error when both an allowlist and a denylist are set", func() {
+			_, err := NewPolicy([]string{"source_id"}, []string{"deployment"}, nil, 0)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error on a negative max value length", func() {
+			_, err := NewPolicy(nil, nil, nil, -1)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error on a rename to an invalid label name", func() {
+			_, err := NewPolicy(nil, nil, map[string]string{"source_id": "source-id"}, 0)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error on a rename to a reserved label name", func() {
+			_, err := NewPolicy(nil, nil, map[string]string{"source_id": "__name__"}, 0)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error when allowed tags map to the same label name", func() {
+			_, err := NewPolicy([]string{"instanceId", "instance_id"}, nil, nil, 0)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Labels", func() {
+		It("sanitizes tag names and skips empty tags with a nil policy", func() {
+			var policy *Policy
+			labelNames, labelValues, _ := policy.Labels(tags, nil)
+			Expect(labelNames).To(Equal([]string{"deployment", "description", "instance_id", "source_id"}))
+			Expect(labelValues).To(Equal([]string{"cf", "a very long description", "fake-instance-id", "fake-source-id"}))
+		})
+
+		It("only reports the allowed tags", func() {
+			policy, err := NewPolicy([]string{"source_id", "instanceId"}, nil, nil, 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			labelNames, labelValues, _ := policy.Labels(tags, nil)
+			Expect(labelNames).To(Equal([]string{"instance_id", "source_id"}))
+			Expect(labelValues).To(Equal([]string{"fake-instance-id", "fake-source-id"}))
+		})
+
+		It("does not report the denied tags", func() {
+			policy, err := NewPolicy(nil, []string{"source_id", "description"}, nil, 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			labelNames, _, _ := policy.Labels(tags, nil)
+			Expect(labelNames).To(Equal([]string{"deployment", "instance_id"}))
+		})
+
+		It("renames tags", func() {
+			policy, err := NewPolicy(nil, nil, map[string]string{"deployment": "cf_deployment"}, 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			labelNames, labelValues, _ := policy.Labels(tags, nil)
+			Expect(labelNames).To(Equal([]string{"cf_deployment", "description", "instance_id", "source_id"}))
+			Expect(labelValues[0]).To(Equal("cf"))
+		})
+
+		It("truncates long values", func() {
+			policy, err := NewPolicy([]string{"description"}, nil, nil, 6)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, labelValues, _ := policy.Labels(tags, nil)
+			Expect(labelValues).To(Equal([]string{"a very"}))
+		})
+
+		It("does not truncate in the middle of a multi-byte character", func() {
+			policy, err := NewPolicy(nil, nil, nil, 2)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, labelValues, _ := policy.Labels(map[string]string{"name": "aé"}, nil)
+			Expect(labelValues).To(Equal([]string{"a"}))
+		})
+
+		It("keeps the first tag in name order when tags map to the same label", func() {
+			policy, err := NewPolicy(nil, nil, nil, 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			labelNames, labelValues, _ := policy.Labels(map[string]string{"instanceId": "a", "instance_id": "b"}, nil)
+			Expect(labelNames).To(Equal([]string{"instance_id"}))
+			Expect(labelValues).To(Equal([]string{"a"}))
+		})
+	})
+
+	Describe("Labels with builtin labels", func() {
+		It("reports whether tags collided", func() {
+			_, _, collided := (*Policy)(nil).Labels(tags, []string{"origin", "environment"})
+			Expect(collided).To(BeFalse())
+		})
+
+		It("prefixes the tags colliding with a builtin label", func() {
+			labelNames, labelValues, collided := (*Policy)(nil).Labels(map[string]string{"origin": "fake-origin", "unit": "ms", "source_id": "fake-source-id"}, []string{"origin", "unit"})
+			Expect(collided).To(BeTrue())
+			Expect(labelNames).To(Equal([]string{"source_id", "tag_origin", "tag_unit"}))
+			Expect(labelValues).To(Equal([]string{"fake-source-id", "fake-origin", "ms"}))
+		})
+
+		It("drops the tags whose prefixed name collides too", func() {
+			labelNames, _, collided := (*Policy)(nil).Labels(map[string]string{"origin": "fake-origin"}, []string{"origin", "tag_origin"})
+			Expect(collided).To(BeTrue())
+			Expect(labelNames).To(BeEmpty())
+		})
+
+		It("prefers the prefixed tag over a tag with the prefixed name", func() {
+			labelNames, labelValues, _ := (*Policy)(nil).Labels(map[string]string{"origin": "a", "tag_origin": "b"}, []string{"origin"})
+			Expect(labelNames).To(Equal([]string{"tag_origin"}))
+			Expect(labelValues).To(Equal([]string{"a"}))
+		})
+	})
+
+	Describe("FixedLabelNames", func() {
+		It("returns no labels with a nil policy", func() {
+			var policy *Policy
+			Expect(policy.FixedLabelNames()).To(BeEmpty())
+			Expect(policy.FixedLabelValues(tags)).To(BeEmpty())
+		})
+
+		It("returns no labels without an allowlist", func() {
+			policy, err := NewPolicy(nil, []string{"source_id"}, nil, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(policy.FixedLabelNames()).To(BeEmpty())
+		})
+
+		It("returns the allowed tags labels", func() {
+			policy, err := NewPolicy([]string{"source_id", "instanceId", "missing"}, nil, map[string]string{"source_id": "source"}, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(policy.FixedLabelNames()).To(Equal([]string{"instance_id", "missing", "source"}))
+			Expect(policy.FixedLabelValues(tags)).To(Equal([]string{"fake-instance-id", "", "fake-source-id"}))
+		})
+	})
+})
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseBuckets parses a comma separated list of histogram bucket upper bounds.
+func ParseBuckets(buckets string) ([]float64, error) {
+	var bounds []float64
+
+	for _, bucket := range strings.Split(buckets, ",") {
+		bucket = strings.TrimSpace(bucket)
+		if bucket == "" {
+			continue
+		}
+
+		bound, err := strconv.ParseFloat(bucket, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid bucket `%s`: %v", bucket, err)
+		}
+		bounds = append(bounds, bound)
+	}
+
+	if len(bounds) == 0 {
+		return nil, fmt.Errorf("No buckets defined")
+	}
+
+	if !sort.Float64sAreSorted(bounds) {
+		return nil, fmt.Errorf("Buckets `%s` must be in increasing order", buckets)
+	}
+
+	return bounds, nil
+}
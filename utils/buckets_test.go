@@ -0,0 +1,29 @@
+package utils_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/firehose_exporter/utils"
+)
+
+var _ = Describe("ParseBuckets", func() {
+	It("parses buckets", func() {
+		Expect(ParseBuckets("0.1, 1,10")).To(Equal([]float64{0.1, 1, 10}))
+	})
+
+	It("returns an error when a bucket is not a number", func() {
+		_, err := ParseBuckets("0.1,foo")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error when buckets are not sorted", func() {
+		_, err := ParseBuckets("1,0.1")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error when there are no buckets", func() {
+		_, err := ParseBuckets("")
+		Expect(err).To(HaveOccurred())
+	})
+})
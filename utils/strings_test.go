@@ -0,0 +1,19 @@
+package utils_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/firehose_exporter/utils"
+)
+
+var _ = Describe("ContainsString", func() {
+	It("finds a value", func() {
+		Expect(ContainsString([]string{"foo", "bar"}, "bar")).To(BeTrue())
+	})
+
+	It("does not find a missing value", func() {
+		Expect(ContainsString([]string{"foo", "bar"}, "baz")).To(BeFalse())
+		Expect(ContainsString(nil, "foo")).To(BeFalse())
+	})
+})
@@ -14,6 +14,7 @@ import (
 func PrometheusMetric(expected prometheus.Metric) types.GomegaMatcher {
 	expectedMetric := &dto.Metric{}
 	expected.Write(expectedMetric)
+	clearExemplarTimestamps(expectedMetric)
 
 	return &PrometheusMetricMatcher{
 		Desc:   expected.Desc(),
@@ -34,6 +35,7 @@ func (matcher *PrometheusMetricMatcher) Match(actual interface{}) (success bool,
 
 	actualMetric := &dto.Metric{}
 	metric.Write(actualMetric)
+	clearExemplarTimestamps(actualMetric)
 
 	if !reflect.DeepEqual(metric.Desc().String(), matcher.Desc.String()) {
 		return false, nil
@@ -60,3 +62,16 @@ func (matcher *PrometheusMetricMatcher) FailureMessage(actual interface{}) (mess
 func (matcher *PrometheusMetricMatcher) NegatedFailureMessage(actual interface{}) (message string) {
 	return format.Message(actual, "not to equal", matcher)
 }
+
+// clearExemplarTimestamps removes the timestamps of the exemplars of a
+// metric, as they record when the expected and actual metrics were observed.
+func clearExemplarTimestamps(metric *dto.Metric) {
+	for _, bucket := range metric.GetHistogram().GetBucket() {
+		if bucket.Exemplar != nil {
+			bucket.Exemplar.Timestamp = nil
+		}
+	}
+	if metric.GetCounter().GetExemplar() != nil {
+		metric.Counter.Exemplar.Timestamp = nil
+	}
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+// UnitConversion converts a value reported in a given unit to a Prometheus
+// base unit, identified by the metric name suffix.
+type UnitConversion struct {
+	Factor float64 `yaml:"factor"`
+	Suffix string  `yaml:"suffix"`
+}
+
+type UnitConversionsConfig struct {
+	UnitConversions map[string]UnitConversion `yaml:"unit_conversions"`
+}
+
+// UnitConversions maps Cloud Foundry units to their Prometheus base unit.
+// New units can be supported by adding them to this table, or by loading
+// them with LoadUnitConversions.
+var UnitConversions = map[string]UnitConversion{
+	"ns":           {Factor: 1e-9, Suffix: "seconds"},
+	"nanos":        {Factor: 1e-9, Suffix: "seconds"},
+	"nanoseconds":  {Factor: 1e-9, Suffix: "seconds"},
+	"us":           {Factor: 1e-6, Suffix: "seconds"},
+	"µs":           {Factor: 1e-6, Suffix: "seconds"},
+	"micros":       {Factor: 1e-6, Suffix: "seconds"},
+	"microseconds": {Factor: 1e-6, Suffix: "seconds"},
+	"ms":           {Factor: 1e-3, Suffix: "seconds"},
+	"millis":       {Factor: 1e-3, Suffix: "seconds"},
+	"milliseconds": {Factor: 1e-3, Suffix: "seconds"},
+	"s":            {Factor: 1, Suffix: "seconds"},
+	"seconds":      {Factor: 1, Suffix: "seconds"},
+	"min":          {Factor: 60, Suffix: "seconds"},
+	"minutes":      {Factor: 60, Suffix: "seconds"},
+	"h":            {Factor: 3600, Suffix: "seconds"},
+	"hours":        {Factor: 3600, Suffix: "seconds"},
+	"b":            {Factor: 1, Suffix: "bytes"},
+	"bytes":        {Factor: 1, Suffix: "bytes"},
+	"kib":          {Factor: 1 << 10, Suffix: "bytes"},
+	"mib":          {Factor: 1 << 20, Suffix: "bytes"},
+	"gib":          {Factor: 1 << 30, Suffix: "bytes"},
+	"tib":          {Factor: 1 << 40, Suffix: "bytes"},
+	"kb":           {Factor: 1e3, Suffix: "bytes"},
+	"mb":           {Factor: 1e6, Suffix: "bytes"},
+	"gb":           {Factor: 1e9, Suffix: "bytes"},
+	"tb":           {Factor: 1e12, Suffix: "bytes"},
+	"%":            {Factor: 0.01, Suffix: "ratio"},
+	"percent":      {Factor: 0.01, Suffix: "ratio"},
+	"percentage":   {Factor: 0.01, Suffix: "ratio"},
+}
+
+// LoadUnitConversions returns the UnitConversions table extended, or
+// overridden, with the conversions declared in the YAML file at path.
+func LoadUnitConversions(path string) (map[string]UnitConversion, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &UnitConversionsConfig{}
+	if err := yaml.UnmarshalStrict(content, config); err != nil {
+		return nil, fmt.Errorf("Error parsing unit conversions config `%s`: %v", path, err)
+	}
+
+	conversions := map[string]UnitConversion{}
+	for unit, conversion := range UnitConversions {
+		conversions[unit] = conversion
+	}
+	for unit, conversion := range config.UnitConversions {
+		unit = strings.ToLower(strings.TrimSpace(unit))
+		if unit == "" {
+			return nil, fmt.Errorf("Unit conversions config `%s`: unit must not be empty", path)
+		}
+		if conversion.Factor == 0 {
+			return nil, fmt.Errorf("Unit conversions config `%s`: unit `%s` must have a non zero factor", path, unit)
+		}
+		if !model.IsValidMetricName(model.LabelValue(conversion.Suffix)) {
+			return nil, fmt.Errorf("Unit conversions config `%s`: unit `%s` suffix `%s` is not a valid metric name suffix", path, unit, conversion.Suffix)
+		}
+		conversions[unit] = conversion
+	}
+
+	return conversions, nil
+}
+
+// ConvertUnit converts a value of a metric reported in unit using the
+// conversions table (units are matched case insensitively). It returns the
+// metric name with the base unit suffix appended, the converted value, and
+// whether the unit is known.
+func ConvertUnit(name string, value float64, unit string, conversions map[string]UnitConversion) (string, float64, bool) {
+	conversion, ok := conversions[strings.ToLower(strings.TrimSpace(unit))]
+	if !ok {
+		return name, value, false
+	}
+
+	if !strings.HasSuffix(name, "_"+conversion.Suffix) {
+		name = name + "_" + conversion.Suffix
+	}
+
+	return name, value * conversion.Factor, true
+}
@@ -0,0 +1,119 @@
+package utils_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/bosh-prometheus/firehose_exporter/utils"
+)
+
+var _ = Describe("ConvertUnit", func() {
+	It("converts durations to seconds", func() {
+		name, value, ok := ConvertUnit("latency", 250, "ms", UnitConversions)
+		Expect(ok).To(BeTrue())
+		Expect(name).To(Equal("latency_seconds"))
+		Expect(value).To(BeNumerically("~", 0.25))
+	})
+
+	It("converts sizes to bytes", func() {
+		name, value, ok := ConvertUnit("memory", 2, "KiB", UnitConversions)
+		Expect(ok).To(BeTrue())
+		Expect(name).To(Equal("memory_bytes"))
+		Expect(value).To(Equal(float64(2048)))
+	})
+
+	It("converts percentages to ratios", func() {
+		name, value, ok := ConvertUnit("cpu", 50, "percentage", UnitConversions)
+		Expect(ok).To(BeTrue())
+		Expect(name).To(Equal("cpu_ratio"))
+		Expect(value).To(Equal(0.5))
+	})
+
+	It("does not append the suffix twice", func() {
+		name, _, ok := ConvertUnit("memory_bytes", 2, "MB", UnitConversions)
+		Expect(ok).To(BeTrue())
+		Expect(name).To(Equal("memory_bytes"))
+	})
+
+	It("leaves unknown units untouched", func() {
+		name, value, ok := ConvertUnit("requests", 3, "count", UnitConversions)
+		Expect(ok).To(BeFalse())
+		Expect(name).To(Equal("requests"))
+		Expect(value).To(Equal(float64(3)))
+	})
+
+	It("supports additional conversions", func() {
+		conversions := map[string]UnitConversion{"req/min": {Factor: 1.0 / 60, Suffix: "per_second"}}
+		name, value, ok := ConvertUnit("requests", 120, "req/min", conversions)
+		Expect(ok).To(BeTrue())
+		Expect(name).To(Equal("requests_per_second"))
+		Expect(value).To(Equal(float64(2)))
+	})
+})
+
+var _ = Describe("LoadUnitConversions", func() {
+	var (
+		configDir  string
+		configPath string
+	)
+
+	BeforeEach(func() {
+		var err error
+		configDir, err = ioutil.TempDir("", "units")
+		Expect(err).ToNot(HaveOccurred())
+		configPath = filepath.Join(configDir, "units.yml")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(configDir)
+	})
+
+	It("extends and overrides the unit conversions", func() {
+		Expect(ioutil.WriteFile(configPath, []byte(`
+unit_conversions:
+  Req/Min:
+    factor: 0.016666666666666666
+    suffix: per_second
+  percent:
+    factor: 1
+    suffix: percent
+`), 0644)).To(Succeed())
+
+		conversions, err := LoadUnitConversions(configPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conversions).To(HaveKeyWithValue("req/min", UnitConversion{Factor: 1.0 / 60, Suffix: "per_second"}))
+		Expect(conversions).To(HaveKeyWithValue("percent", UnitConversion{Factor: 1, Suffix: "percent"}))
+		Expect(conversions).To(HaveKeyWithValue("ms", UnitConversions["ms"]))
+		Expect(UnitConversions).To(HaveKeyWithValue("percent", UnitConversion{Factor: 0.01, Suffix: "ratio"}))
+	})
+
+	It("returns an error on unknown fields", func() {
+		Expect(ioutil.WriteFile(configPath, []byte("unit_conversions:\n  req/min:\n    scale: 2\n"), 0644)).To(Succeed())
+
+		_, err := LoadUnitConversions(configPath)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error on a zero factor", func() {
+		Expect(ioutil.WriteFile(configPath, []byte("unit_conversions:\n  req/min:\n    suffix: per_second\n"), 0644)).To(Succeed())
+
+		_, err := LoadUnitConversions(configPath)
+		Expect(err).To(MatchError(ContainSubstring("non zero factor")))
+	})
+
+	It("returns an error on an invalid suffix", func() {
+		Expect(ioutil.WriteFile(configPath, []byte("unit_conversions:\n  req/min:\n    factor: 2\n    suffix: per-second\n"), 0644)).To(Succeed())
+
+		_, err := LoadUnitConversions(configPath)
+		Expect(err).To(MatchError(ContainSubstring("not a valid metric name suffix")))
+	})
+
+	It("returns an error when the file does not exist", func() {
+		_, err := LoadUnitConversions(filepath.Join(configDir, "missing.yml"))
+		Expect(err).To(HaveOccurred())
+	})
+})